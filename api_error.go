@@ -0,0 +1,66 @@
+package hetzner_dns
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// APIError is returned when the API answers with a non-2xx status code.
+type APIError struct {
+	Method     string
+	Endpoint   string
+	StatusCode int
+	// Message is the error message reported by the API, if any.
+	Message string
+	// Body is the raw response body.
+	Body []byte
+}
+
+func (apiError *APIError) Error() string {
+	message := apiError.Message
+	if message == "" {
+		message = http.StatusText(apiError.StatusCode)
+	}
+	return fmt.Sprintf("hetzner_dns: %s %s: %d %s", apiError.Method, apiError.Endpoint, apiError.StatusCode, message)
+}
+
+// NotFound returns true if the resource does not exist.
+func (apiError *APIError) NotFound() bool {
+	return apiError.StatusCode == http.StatusNotFound
+}
+
+// isNotFound returns true if err is an APIError for a missing resource.
+func isNotFound(err error) bool {
+	var apiError *APIError
+	return errors.As(err, &apiError) && apiError.NotFound()
+}
+
+// newAPIError builds the APIError for a response, extracting the message
+// from the error formats used by the API.
+func newAPIError(method string, endpoint string, statusCode int, body []byte) *APIError {
+	apiError := &APIError{
+		Method:     method,
+		Endpoint:   endpoint,
+		StatusCode: statusCode,
+		Body:       body,
+	}
+	var payload struct {
+		Message string `json:"message"`
+		Error   struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &payload); err == nil {
+		apiError.Message = payload.Error.Message
+		if apiError.Message == "" {
+			apiError.Message = payload.Message
+		}
+	} else {
+		apiError.Message = strings.TrimSpace(string(body))
+	}
+	return apiError
+}
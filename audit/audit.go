@@ -0,0 +1,268 @@
+// Package audit records the mutating API calls of a Client: who changed
+// which record or zone, when, from what to what, and with which result.
+package audit
+
+import (
+	"context"
+	"log"
+	"os/user"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+)
+
+const (
+	RESULT_OK    = "ok"
+	RESULT_ERROR = "error"
+)
+
+// Entry is an audited change. Bulk operations produce an entry per record.
+type Entry struct {
+	Time      time.Time `json:"time"`
+	Actor     string    `json:"actor"`
+	Operation string    `json:"operation"`
+	ZoneID    string    `json:"zone_id,omitempty"`
+	ZoneName  string    `json:"zone_name,omitempty"`
+	RecordID  string    `json:"record_id,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	Type      string    `json:"type,omitempty"`
+	// Before and After are the state of the record before and after the
+	// change (nil for creations and deletions respectively).
+	Before *hetzner_dns.Record `json:"before,omitempty"`
+	After  *hetzner_dns.Record `json:"after,omitempty"`
+	Result string              `json:"result"`
+	Error  string              `json:"error,omitempty"`
+	DryRun bool                `json:"dry_run,omitempty"`
+}
+
+// Sink stores audit entries.
+type Sink interface {
+	Write(entry *Entry) error
+}
+
+type actorKey struct{}
+
+// WithActor returns a context whose calls are attributed to actor, e.g. the
+// user of a portal acting through a shared token.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// Actor returns the actor set with WithActor, if any.
+func Actor(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// Auditor writes an entry to Sink for each mutating call of the clients it
+// is attached to.
+type Auditor struct {
+	Sink Sink
+	// Actor is used for calls without an actor in their context (the name
+	// of the current OS user if empty).
+	Actor string
+	// OnError is called when the audit fails. If nil, errors are logged.
+	OnError func(error)
+
+	zoneNames sync.Map // zone ID -> name
+}
+
+// NewAuditor creates an Auditor writing to sink.
+func NewAuditor(sink Sink) *Auditor {
+	return &Auditor{Sink: sink}
+}
+
+// Attach makes client audit its mutating calls. The records changed by
+// updates and deletions are fetched beforehand, to record their previous
+// state.
+func (auditor *Auditor) Attach(client *hetzner_dns.Client) {
+	client.Use(func(next hetzner_dns.Handler) hetzner_dns.Handler {
+		return func(ctx context.Context, op *hetzner_dns.Operation) error {
+			if !op.Mutating() {
+				return next(ctx, op)
+			}
+			before := auditor.before(ctx, client, op)
+			err := next(ctx, op)
+			auditor.write(ctx, client, op, before, err)
+			return err
+		}
+	})
+}
+
+// before returns the state of the records changed by op, by ID.
+func (auditor *Auditor) before(ctx context.Context, client *hetzner_dns.Client, op *hetzner_dns.Operation) map[string]hetzner_dns.Record {
+	var ids []string
+	switch op.Name {
+	case hetzner_dns.OP_UPDATE_RECORD, hetzner_dns.OP_DELETE_RECORD:
+		ids = append(ids, op.ID)
+	case hetzner_dns.OP_BULK_UPDATE_RECORDS:
+		for _, record := range op.Request.(*hetzner_dns.BulkRecordRequest).Records {
+			ids = append(ids, record.ID)
+		}
+	}
+	records := map[string]hetzner_dns.Record{}
+	for _, id := range ids {
+		recordResponse, err := client.GetRecord(ctx, id)
+		if err != nil {
+			auditor.error(errors.Wrapf(err, "audit: can't get record %s", id))
+			continue
+		}
+		records[id] = recordResponse.Record
+	}
+	return records
+}
+
+func (auditor *Auditor) write(ctx context.Context, client *hetzner_dns.Client, op *hetzner_dns.Operation, before map[string]hetzner_dns.Record, err error) {
+	template := Entry{
+		Time:      time.Now().UTC(),
+		Actor:     auditor.actor(ctx),
+		Operation: op.Name,
+		Result:    RESULT_OK,
+		DryRun:    client.DryRun != nil,
+	}
+	if err != nil {
+		template.Result, template.Error = RESULT_ERROR, err.Error()
+	}
+
+	var entries []Entry
+	recordEntry := func(id string, request *hetzner_dns.RecordRequest, after *hetzner_dns.Record) {
+		entry := template
+		if after == nil && err == nil && op.Name != hetzner_dns.OP_DELETE_RECORD {
+			entry.Result, entry.Error = RESULT_ERROR, "record rejected by the API"
+		}
+		if previous, ok := before[id]; ok {
+			entry.Before = &previous
+			entry.ZoneID, entry.Name, entry.Type = previous.ZoneID, previous.Name, previous.Type
+		}
+		if request != nil {
+			entry.ZoneID, entry.Name, entry.Type = request.ZoneID, request.Name, request.Type
+		}
+		if after != nil && err == nil && op.Name != hetzner_dns.OP_DELETE_RECORD {
+			entry.After = after
+			id = after.ID
+		}
+		entry.RecordID = id
+		entries = append(entries, entry)
+	}
+
+	switch op.Name {
+	case hetzner_dns.OP_CREATE_RECORD, hetzner_dns.OP_UPDATE_RECORD:
+		after := op.Response.(*hetzner_dns.RecordResponse).Record
+		recordEntry(op.ID, op.Request.(*hetzner_dns.RecordRequest), &after)
+	case hetzner_dns.OP_DELETE_RECORD:
+		recordEntry(op.ID, nil, nil)
+	case hetzner_dns.OP_BULK_CREATE_RECORDS, hetzner_dns.OP_BULK_UPDATE_RECORDS:
+		response := op.Response.(*hetzner_dns.BulkRecordResponse)
+		rejected := append(append([]hetzner_dns.RecordRequest{}, response.InvalidRecords...), response.FailedRecords...)
+		rejectedMatched := make([]bool, len(rejected))
+		matched := make([]bool, len(response.Records))
+		for _, request := range op.Request.(*hetzner_dns.BulkRecordRequest).Records {
+			request := request
+			var after *hetzner_dns.Record
+			if !bulkRejected(rejected, rejectedMatched, &request) {
+				after = bulkMatch(response, matched, &request)
+			}
+			recordEntry(request.ID, &request, after)
+		}
+	case hetzner_dns.OP_CREATE_ZONE, hetzner_dns.OP_UPDATE_ZONE:
+		entry := template
+		entry.ZoneID = op.ID
+		entry.ZoneName = op.Request.(*hetzner_dns.ZoneRequest).Name
+		if zone := op.Response.(*hetzner_dns.ZoneResponse).Zone; zone.ID != "" {
+			entry.ZoneID = zone.ID
+		}
+		entries = append(entries, entry)
+	case hetzner_dns.OP_DELETE_ZONE:
+		entry := template
+		entry.ZoneID = op.ID
+		entries = append(entries, entry)
+	}
+
+	for i := range entries {
+		entry := &entries[i]
+		if entry.ZoneName == "" && entry.ZoneID != "" {
+			entry.ZoneName = auditor.zoneName(ctx, client, entry.ZoneID)
+		}
+		if err := auditor.Sink.Write(entry); err != nil {
+			auditor.error(errors.Wrap(err, "audit: can't write entry"))
+		}
+	}
+}
+
+// bulkRejected returns true if the bulk response lists request among the
+// invalid or failed records, marking the one found in matched.
+func bulkRejected(rejected []hetzner_dns.RecordRequest, matched []bool, request *hetzner_dns.RecordRequest) bool {
+	for i := range rejected {
+		failed := &rejected[i]
+		if !matched[i] && sameRecord(failed.ID, failed.Name, failed.Type, failed.Value, request) {
+			matched[i] = true
+			return true
+		}
+	}
+	return false
+}
+
+// bulkMatch returns the record of the bulk response resulting from request,
+// marking it in matched. The records of the response are not in the order of
+// the requests when some of them are rejected.
+func bulkMatch(response *hetzner_dns.BulkRecordResponse, matched []bool, request *hetzner_dns.RecordRequest) *hetzner_dns.Record {
+	for i := range response.Records {
+		record := &response.Records[i]
+		if !matched[i] && sameRecord(record.ID, record.Name, record.Type, record.Value, request) {
+			matched[i] = true
+			return record
+		}
+	}
+	return nil
+}
+
+// sameRecord returns true if a record of a bulk response is the one of
+// request: the record with the same ID for updates, else the record with the
+// same name, type and value, as normalized by the API (e.g. "" for "@", or
+// quoted TXT values).
+func sameRecord(id string, name string, recordType string, value string, request *hetzner_dns.RecordRequest) bool {
+	if request.ID != "" && id != "" {
+		return id == request.ID
+	}
+	return hetzner_dns.RecordKey(name, recordType, strings.Trim(value, `"`)) ==
+		hetzner_dns.RecordKey(request.Name, request.Type, strings.Trim(request.Value, `"`))
+}
+
+func (auditor *Auditor) actor(ctx context.Context) string {
+	if actor := Actor(ctx); actor != "" {
+		return actor
+	}
+	if auditor.Actor != "" {
+		return auditor.Actor
+	}
+	if current, err := user.Current(); err == nil {
+		return current.Username
+	}
+	return ""
+}
+
+// zoneName returns the name of a zone, to allow querying entries by zone
+// name. Names are looked up once per zone.
+func (auditor *Auditor) zoneName(ctx context.Context, client *hetzner_dns.Client, zoneId string) string {
+	if name, ok := auditor.zoneNames.Load(zoneId); ok {
+		return name.(string)
+	}
+	zoneResponse, err := client.GetZone(ctx, zoneId)
+	if err != nil {
+		return ""
+	}
+	auditor.zoneNames.Store(zoneId, zoneResponse.Zone.Name)
+	return zoneResponse.Zone.Name
+}
+
+func (auditor *Auditor) error(err error) {
+	if auditor.OnError != nil {
+		auditor.OnError(err)
+	} else {
+		log.Print(err)
+	}
+}
@@ -0,0 +1,202 @@
+package audit_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+	"github.com/panta/go-hetzner-dns/audit"
+	"github.com/panta/go-hetzner-dns/internal/fakeapi"
+)
+
+func newAPI(t *testing.T) *fakeapi.API {
+	api := fakeapi.New(t)
+	api.AddZone("zone-1", "example.com")
+	api.Insert(hetzner_dns.RecordRequest{ZoneID: "zone-1", Name: "www", Type: "A", Value: "127.0.0.1", TTL: 300})
+	return api
+}
+
+func TestAuditor(t *testing.T) {
+	api := newAPI(t)
+	defer api.Close()
+
+	var buf bytes.Buffer
+	auditor := audit.NewAuditor(audit.NewWriterSink(&buf))
+	auditor.Actor = "automation"
+	client := api.Client()
+	auditor.Attach(client)
+
+	ctx := audit.WithActor(context.Background(), "alice")
+	if _, err := client.UpdateRecord(ctx, hetzner_dns.RecordRequest{ID: "rec-1", ZoneID: "zone-1", Name: "www", Type: "A", Value: "127.0.0.2", TTL: 300}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CreateRecord(context.Background(), hetzner_dns.RecordRequest{ZoneID: "zone-1", Name: "mail", Type: "A", Value: "127.0.0.3"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.DeleteRecord(context.Background(), "rec-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.DeleteRecord(context.Background(), "missing"); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	entries, err := audit.Query(&buf, audit.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("expected 4 entries, got %d", len(entries))
+	}
+
+	update := entries[0]
+	if update.Actor != "alice" || update.Operation != hetzner_dns.OP_UPDATE_RECORD || update.ZoneName != "example.com" {
+		t.Errorf("unexpected entry %+v", update)
+	}
+	if update.Before == nil || update.Before.Value != "127.0.0.1" || update.After == nil || update.After.Value != "127.0.0.2" {
+		t.Errorf("unexpected before/after %+v %+v", update.Before, update.After)
+	}
+	create := entries[1]
+	if create.Actor != "automation" || create.Before != nil || create.After == nil || create.RecordID != "rec-2" {
+		t.Errorf("unexpected entry %+v", create)
+	}
+	deletion := entries[2]
+	if deletion.Before == nil || deletion.Before.Value != "127.0.0.2" || deletion.After != nil || deletion.Name != "www" {
+		t.Errorf("unexpected entry %+v", deletion)
+	}
+	if failed := entries[3]; failed.Result != audit.RESULT_ERROR || failed.Error == "" {
+		t.Errorf("unexpected entry %+v", failed)
+	}
+}
+
+func TestAuditorBulkFailures(t *testing.T) {
+	api := newAPI(t)
+	defer api.Close()
+
+	var buf bytes.Buffer
+	auditor := audit.NewAuditor(audit.NewWriterSink(&buf))
+	auditor.OnError = func(error) {} // the record "missing" can't be fetched
+	client := api.Client()
+	auditor.Attach(client)
+
+	ctx := context.Background()
+	if _, err := client.BulkCreateRecords(ctx, &hetzner_dns.BulkRecordRequest{Records: []hetzner_dns.RecordRequest{
+		{ZoneID: "zone-1", Name: "bad", Type: "A"},
+		{ZoneID: "zone-1", Name: "mail", Type: "A", Value: "127.0.0.3"},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.BulkUpdateRecords(ctx, &hetzner_dns.BulkRecordRequest{Records: []hetzner_dns.RecordRequest{
+		{ID: "missing", ZoneID: "zone-1", Name: "gone", Type: "A", Value: "127.0.0.9"},
+		{ID: "rec-1", ZoneID: "zone-1", Name: "www", Type: "A", Value: "127.0.0.2"},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := audit.Query(&buf, audit.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("expected 4 entries, got %d", len(entries))
+	}
+	for _, i := range []int{0, 2} {
+		if entry := entries[i]; entry.Result != audit.RESULT_ERROR || entry.After != nil {
+			t.Errorf("%d: expected a failed entry, got %+v", i, entry)
+		}
+	}
+	if created := entries[1]; created.Result != audit.RESULT_OK || created.After == nil || created.After.Value != "127.0.0.3" || created.RecordID != created.After.ID {
+		t.Errorf("unexpected entry %+v", created)
+	}
+	if updated := entries[3]; updated.Result != audit.RESULT_OK || updated.After == nil || updated.After.Value != "127.0.0.2" ||
+		updated.Before == nil || updated.Before.Value != "127.0.0.1" {
+		t.Errorf("unexpected entry %+v", updated)
+	}
+}
+
+func TestAuditorBulkNormalized(t *testing.T) {
+	// the API returns the records normalized: lowercase names, quoted TXT
+	// values, default TTLs
+	hs := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/records/bulk":
+			_, _ = io.WriteString(rw, `{
+  "records": [{"id": "rec-9", "zone_id": "zone-1", "name": "", "type": "A", "value": "127.0.0.1", "ttl": 86400}],
+  "invalid_records": [{"zone_id": "zone-1", "name": "www", "type": "TXT", "value": "\"v=spf1 -all\"", "ttl": 86400}]
+}`)
+		case "/zones/zone-1":
+			_, _ = io.WriteString(rw, `{"zone": {"id": "zone-1", "name": "example.com"}}`)
+		default:
+			http.NotFound(rw, req)
+		}
+	}))
+	defer hs.Close()
+
+	var buf bytes.Buffer
+	auditor := audit.NewAuditor(audit.NewWriterSink(&buf))
+	client := &hetzner_dns.Client{BaseURL: hs.URL, ApiKey: "dummy"}
+	auditor.Attach(client)
+
+	if _, err := client.BulkCreateRecords(context.Background(), &hetzner_dns.BulkRecordRequest{Records: []hetzner_dns.RecordRequest{
+		{ZoneID: "zone-1", Name: "WWW", Type: "txt", Value: "v=spf1 -all"},
+		{ZoneID: "zone-1", Name: "@", Type: "A", Value: "127.0.0.1"},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := audit.Query(&buf, audit.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+	if rejected := entries[0]; rejected.Result != audit.RESULT_ERROR || rejected.After != nil {
+		t.Errorf("expected a failed entry, got %+v", rejected)
+	}
+	if created := entries[1]; created.Result != audit.RESULT_OK || created.After == nil || created.RecordID != "rec-9" {
+		t.Errorf("unexpected entry %+v", created)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	var buf bytes.Buffer
+	sink := audit.NewWriterSink(&buf)
+	base := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, entry := range []audit.Entry{
+		{Time: base, ZoneID: "zone-1", ZoneName: "example.com", Name: "www"},
+		{Time: base.Add(time.Hour), ZoneID: "zone-1", ZoneName: "example.com", Name: "mail"},
+		{Time: base.Add(2 * time.Hour), ZoneID: "zone-2", ZoneName: "example.org", Name: "www"},
+	} {
+		entry := entry
+		if err := sink.Write(&entry); err != nil {
+			t.Fatal(i, err)
+		}
+	}
+	data := buf.Bytes()
+
+	tests := []struct {
+		filter   audit.Filter
+		expected int
+	}{
+		{audit.Filter{}, 3},
+		{audit.Filter{Zone: "example.com."}, 2},
+		{audit.Filter{Zone: "zone-2"}, 1},
+		{audit.Filter{Name: "www"}, 2},
+		{audit.Filter{Since: base.Add(time.Minute)}, 2},
+		{audit.Filter{Until: base.Add(time.Hour)}, 1},
+		{audit.Filter{Zone: "example.com", Name: "www", Since: base, Until: base.Add(time.Hour)}, 1},
+	}
+	for i, test := range tests {
+		entries, err := audit.Query(bytes.NewReader(data), test.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != test.expected {
+			t.Errorf("%d: expected %d entries, got %d", i, test.expected, len(entries))
+		}
+	}
+}
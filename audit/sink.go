@@ -0,0 +1,137 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// WriterSink writes entries as JSON lines to a writer.
+type WriterSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterSink creates a sink writing to w.
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+func (sink *WriterSink) Write(entry *Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	_, err = sink.w.Write(append(data, '\n'))
+	return err
+}
+
+// FileSink appends entries as JSON lines to a file, syncing each of them.
+type FileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// OpenFileSink opens path for appending, creating it if needed.
+func OpenFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't open audit log")
+	}
+	return &FileSink{file: file}, nil
+}
+
+func (sink *FileSink) Write(entry *Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if _, err := sink.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return sink.file.Sync()
+}
+
+// Close closes the file.
+func (sink *FileSink) Close() error {
+	return sink.file.Close()
+}
+
+// Filter selects entries. Empty fields match everything.
+type Filter struct {
+	// Zone matches the zone name or ID.
+	Zone string
+	// Name matches the record name.
+	Name  string
+	Since time.Time
+	Until time.Time
+}
+
+// Match returns true if entry is selected by the filter.
+func (filter *Filter) Match(entry *Entry) bool {
+	if filter.Zone != "" && !strings.EqualFold(strings.TrimSuffix(filter.Zone, "."), entry.ZoneName) && filter.Zone != entry.ZoneID {
+		return false
+	}
+	if filter.Name != "" {
+		name := entry.Name
+		if name == "" {
+			name = "@"
+		}
+		if !strings.EqualFold(filter.Name, name) {
+			return false
+		}
+	}
+	if !filter.Since.IsZero() && entry.Time.Before(filter.Since) {
+		return false
+	}
+	if !filter.Until.IsZero() && !entry.Time.Before(filter.Until) {
+		return false
+	}
+	return true
+}
+
+// Query reads JSON lines entries from r and returns the ones selected by
+// filter.
+func Query(r io.Reader, filter Filter) ([]Entry, error) {
+	var entries []Entry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		entry := Entry{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, errors.Wrapf(err, "can't parse audit entry at line %d", line)
+		}
+		if filter.Match(&entry) {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "can't read audit log")
+	}
+	return entries, nil
+}
+
+// QueryFile is like Query, reading the entries from the file at path.
+func QueryFile(path string, filter Filter) ([]Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't open audit log")
+	}
+	defer file.Close()
+	return Query(file, filter)
+}
@@ -0,0 +1,256 @@
+// Package backup dumps the zones of an Hetzner DNS account into versioned
+// on-disk snapshots and restores zones from them.
+//
+// A snapshot is a directory containing a manifest.json file and, for each
+// zone, a JSON dump of the zone and its records plus an equivalent BIND zone
+// file. The manifest records a SHA-256 checksum of every file.
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+	"github.com/panta/go-hetzner-dns/zonefile"
+)
+
+const (
+	FORMAT_VERSION = 1
+	MANIFEST_FILE  = "manifest.json"
+	ZONES_DIR      = "zones"
+
+	snapshotIDFormat = "20060102T150405Z"
+)
+
+var (
+	ErrChecksumMismatch   = errors.New("backup: checksum mismatch")
+	ErrUnsupportedVersion = errors.New("backup: unsupported snapshot format version")
+	ErrZoneNotFound       = errors.New("backup: zone not found in snapshot")
+)
+
+// Manifest describes the content of a snapshot.
+type Manifest struct {
+	FormatVersion int         `json:"format_version"`
+	ID            string      `json:"id"`
+	CreatedAt     time.Time   `json:"created_at"`
+	Zones         []ZoneEntry `json:"zones"`
+}
+
+// ZoneEntry describes a zone stored in a snapshot.
+type ZoneEntry struct {
+	ID               string `json:"id"`
+	Name             string `json:"name"`
+	RecordsCount     int    `json:"records_count"`
+	JSONFile         string `json:"json_file"`
+	JSONChecksum     string `json:"json_checksum"`
+	ZoneFile         string `json:"zone_file"`
+	ZoneFileChecksum string `json:"zone_file_checksum"`
+}
+
+// ZoneSnapshot is the content of a zone at the time of the snapshot.
+type ZoneSnapshot struct {
+	Zone    hetzner_dns.Zone     `json:"zone"`
+	Records []hetzner_dns.Record `json:"records"`
+}
+
+// Snapshot is a snapshot loaded from disk.
+type Snapshot struct {
+	Manifest *Manifest
+	Zones    []ZoneSnapshot
+}
+
+// Zone returns the snapshot of the zone with the given name.
+func (snapshot *Snapshot) Zone(name string) (*ZoneSnapshot, error) {
+	for i := range snapshot.Zones {
+		if strings.EqualFold(snapshot.Zones[i].Zone.Name, name) {
+			return &snapshot.Zones[i], nil
+		}
+	}
+	return nil, errors.Wrap(ErrZoneNotFound, name)
+}
+
+// Backup dumps all the zones of the account, with all their records, into a
+// new snapshot created under dir. It returns the manifest of the snapshot.
+func Backup(ctx context.Context, client *hetzner_dns.Client, dir string) (*Manifest, error) {
+	zones, err := client.GetAllZones(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't get zones")
+	}
+
+	snapshot := &Snapshot{}
+	for _, zone := range zones {
+		records, err := client.GetAllRecords(ctx, zone.ID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "can't get records for zone %s", zone.Name)
+		}
+		snapshot.Zones = append(snapshot.Zones, ZoneSnapshot{Zone: zone, Records: records})
+	}
+	return Write(dir, snapshot, time.Now())
+}
+
+// Write stores snapshot under dir, in a new directory named after createdAt.
+func Write(dir string, snapshot *Snapshot, createdAt time.Time) (*Manifest, error) {
+	createdAt = createdAt.UTC()
+	manifest := &Manifest{
+		FormatVersion: FORMAT_VERSION,
+		ID:            createdAt.Format(snapshotIDFormat),
+		CreatedAt:     createdAt,
+	}
+	snapshotDir := filepath.Join(dir, manifest.ID)
+	if _, err := os.Stat(snapshotDir); err == nil {
+		return nil, errors.Errorf("backup: snapshot %s already exists", manifest.ID)
+	}
+	if err := os.MkdirAll(filepath.Join(snapshotDir, ZONES_DIR), 0o700); err != nil {
+		return nil, errors.Wrap(err, "can't create snapshot directory")
+	}
+
+	for _, zoneSnapshot := range snapshot.Zones {
+		jsonData, err := json.MarshalIndent(&zoneSnapshot, "", "  ")
+		if err != nil {
+			return nil, errors.Wrapf(err, "can't encode zone %s", zoneSnapshot.Zone.Name)
+		}
+		var zoneFile bytes.Buffer
+		if err := zonefile.Write(&zoneFile, zoneSnapshot.Zone.Name, zoneSnapshot.Zone.TTL, zoneSnapshot.Records); err != nil {
+			return nil, errors.Wrapf(err, "can't write zone file for %s", zoneSnapshot.Zone.Name)
+		}
+
+		entry := ZoneEntry{
+			ID:           zoneSnapshot.Zone.ID,
+			Name:         zoneSnapshot.Zone.Name,
+			RecordsCount: len(zoneSnapshot.Records),
+			JSONFile:     filepath.ToSlash(filepath.Join(ZONES_DIR, zoneSnapshot.Zone.Name+".json")),
+			ZoneFile:     filepath.ToSlash(filepath.Join(ZONES_DIR, zoneSnapshot.Zone.Name+".zone")),
+		}
+		if entry.JSONChecksum, err = writeFile(snapshotDir, entry.JSONFile, jsonData); err != nil {
+			return nil, err
+		}
+		if entry.ZoneFileChecksum, err = writeFile(snapshotDir, entry.ZoneFile, zoneFile.Bytes()); err != nil {
+			return nil, err
+		}
+		manifest.Zones = append(manifest.Zones, entry)
+	}
+	sort.Slice(manifest.Zones, func(i, j int) bool {
+		return manifest.Zones[i].Name < manifest.Zones[j].Name
+	})
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "can't encode manifest")
+	}
+	if _, err := writeFile(snapshotDir, MANIFEST_FILE, manifestData); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// List returns the manifests of all the snapshots found under dir, oldest first.
+func List(dir string) ([]*Manifest, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't read backup directory")
+	}
+	var manifests []*Manifest
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		manifest, err := readManifest(filepath.Join(dir, entry.Name()))
+		if os.IsNotExist(errors.Cause(err)) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, manifest)
+	}
+	sort.Slice(manifests, func(i, j int) bool {
+		return manifests[i].CreatedAt.Before(manifests[j].CreatedAt)
+	})
+	return manifests, nil
+}
+
+// Open loads the snapshot with the given ID from dir, verifying checksums.
+// If id is empty, the most recent snapshot is loaded.
+func Open(dir string, id string) (*Snapshot, error) {
+	if id == "" {
+		manifests, err := List(dir)
+		if err != nil {
+			return nil, err
+		}
+		if len(manifests) == 0 {
+			return nil, errors.Errorf("backup: no snapshots in %s", dir)
+		}
+		id = manifests[len(manifests)-1].ID
+	}
+
+	snapshotDir := filepath.Join(dir, id)
+	manifest, err := readManifest(snapshotDir)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &Snapshot{Manifest: manifest}
+	for _, entry := range manifest.Zones {
+		jsonData, err := readFile(snapshotDir, entry.JSONFile, entry.JSONChecksum)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := readFile(snapshotDir, entry.ZoneFile, entry.ZoneFileChecksum); err != nil {
+			return nil, err
+		}
+		zoneSnapshot := ZoneSnapshot{}
+		if err := json.Unmarshal(jsonData, &zoneSnapshot); err != nil {
+			return nil, errors.Wrapf(err, "can't parse %s", entry.JSONFile)
+		}
+		snapshot.Zones = append(snapshot.Zones, zoneSnapshot)
+	}
+	return snapshot, nil
+}
+
+func readManifest(snapshotDir string) (*Manifest, error) {
+	data, err := ioutil.ReadFile(filepath.Join(snapshotDir, MANIFEST_FILE))
+	if err != nil {
+		return nil, errors.Wrap(err, "can't read manifest")
+	}
+	manifest := &Manifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, errors.Wrap(err, "can't parse manifest")
+	}
+	if manifest.FormatVersion != FORMAT_VERSION {
+		return nil, errors.Wrapf(ErrUnsupportedVersion, "version %d", manifest.FormatVersion)
+	}
+	return manifest, nil
+}
+
+func writeFile(snapshotDir string, name string, data []byte) (string, error) {
+	if err := ioutil.WriteFile(filepath.Join(snapshotDir, filepath.FromSlash(name)), data, 0o600); err != nil {
+		return "", errors.Wrapf(err, "can't write %s", name)
+	}
+	return checksum(data), nil
+}
+
+func readFile(snapshotDir string, name string, expectedChecksum string) ([]byte, error) {
+	data, err := ioutil.ReadFile(filepath.Join(snapshotDir, filepath.FromSlash(name)))
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't read %s", name)
+	}
+	if checksum(data) != expectedChecksum {
+		return nil, errors.Wrap(ErrChecksumMismatch, name)
+	}
+	return data, nil
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
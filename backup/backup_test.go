@@ -0,0 +1,101 @@
+package backup_test
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+	"github.com/panta/go-hetzner-dns/backup"
+)
+
+func TestBackupRestore(t *testing.T) {
+	records := `{
+  "records": [
+    {"type": "SOA", "id": "r0", "zone_id": "zone-1", "name": "@", "value": "ns1.example.net. dns.example.net. 1 86400 10800 3600000 3600", "ttl": 0},
+    {"type": "A", "id": "r1", "zone_id": "zone-1", "name": "@", "value": "10.0.0.10", "ttl": 0},
+    {"type": "A", "id": "r2", "zone_id": "zone-1", "name": "www", "value": "10.0.0.1", "ttl": 60},
+    {"type": "TXT", "id": "r3", "zone_id": "zone-1", "name": "@", "value": "v=spf1 mx -all", "ttl": 0}
+  ]
+}`
+	hs := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/zones":
+			_, _ = io.WriteString(rw, `{
+  "zones": [{"id": "zone-1", "name": "example.com", "ttl": 3600}],
+  "meta": {"pagination": {"page": 1, "per_page": 100, "last_page": 1, "total_entries": 1}}
+}`)
+		case "/records":
+			_, _ = io.WriteString(rw, records)
+		default:
+			t.Errorf("Unexpected request: %s %s", req.Method, req.URL.Path)
+		}
+	}))
+	defer hs.Close()
+	client := &hetzner_dns.Client{BaseURL: hs.URL, ApiKey: "dummy"}
+
+	dir, err := ioutil.TempDir("", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	manifest, err := backup.Backup(context.Background(), client, dir)
+	if err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	if len(manifest.Zones) != 1 || manifest.Zones[0].RecordsCount != 4 {
+		t.Fatalf("Wrong manifest: %+v", manifest)
+	}
+	zoneFile, err := ioutil.ReadFile(filepath.Join(dir, manifest.ID, manifest.Zones[0].ZoneFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zoneFile) == 0 {
+		t.Error("Empty zone file")
+	}
+
+	snapshot, err := backup.Open(dir, "")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if snapshot.Manifest.ID != manifest.ID {
+		t.Error("Wrong snapshot opened")
+	}
+
+	// somebody changes www and deletes the TXT record
+	records = `{
+  "records": [
+    {"type": "A", "id": "r1", "zone_id": "zone-1", "name": "@", "value": "10.0.0.10", "ttl": 0},
+    {"type": "A", "id": "r2", "zone_id": "zone-1", "name": "www", "value": "10.0.0.2", "ttl": 60},
+    {"type": "A", "id": "r4", "zone_id": "zone-1", "name": "new", "value": "10.0.0.3", "ttl": 60}
+  ]
+}`
+	restores, err := backup.Restore(context.Background(), client, snapshot, backup.RestoreOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if len(restores) != 1 {
+		t.Fatalf("Wrong # of restored zones: %d", len(restores))
+	}
+	changeSet := restores[0].ChangeSet
+	if changeSet.Count(hetzner_dns.CHANGE_CREATE) != 1 || changeSet.Count(hetzner_dns.CHANGE_UPDATE) != 1 || changeSet.Count(hetzner_dns.CHANGE_DELETE) != 1 {
+		t.Errorf("Wrong changes:\n%s", changeSet)
+	}
+
+	// tampering is detected
+	jsonPath := filepath.Join(dir, manifest.ID, manifest.Zones[0].JSONFile)
+	if err := ioutil.WriteFile(jsonPath, []byte("{}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := backup.Open(dir, manifest.ID); !errors.Is(err, backup.ErrChecksumMismatch) {
+		t.Errorf("Expected ErrChecksumMismatch, got %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package backup
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+)
+
+// RestoreOptions controls the behaviour of Restore.
+type RestoreOptions struct {
+	// Zones restricts the restore to the zones with these names. If empty,
+	// all the zones of the snapshot are restored.
+	Zones []string
+	// DryRun computes the changes without applying them.
+	DryRun bool
+}
+
+// ZoneRestore reports the changes made (or to be made) to a zone.
+type ZoneRestore struct {
+	Name      string
+	Created   bool // the zone didn't exist and has been (or would be) created
+	ChangeSet *hetzner_dns.ChangeSet
+}
+
+// Restore reconciles the zones of the account with their state in snapshot:
+// records not in the snapshot are deleted, missing ones are created and
+// modified ones are updated. Zones missing from the account are created.
+func Restore(ctx context.Context, client *hetzner_dns.Client, snapshot *Snapshot, options RestoreOptions) ([]ZoneRestore, error) {
+	zoneSnapshots := snapshot.Zones
+	if len(options.Zones) > 0 {
+		zoneSnapshots = nil
+		for _, name := range options.Zones {
+			zoneSnapshot, err := snapshot.Zone(name)
+			if err != nil {
+				return nil, err
+			}
+			zoneSnapshots = append(zoneSnapshots, *zoneSnapshot)
+		}
+	}
+
+	liveZones, err := client.GetAllZones(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't get zones")
+	}
+
+	var restores []ZoneRestore
+	for _, zoneSnapshot := range zoneSnapshots {
+		restore, err := restoreZone(ctx, client, zoneSnapshot, liveZones, options.DryRun)
+		if err != nil {
+			return restores, errors.Wrapf(err, "can't restore zone %s", zoneSnapshot.Zone.Name)
+		}
+		restores = append(restores, *restore)
+	}
+	return restores, nil
+}
+
+func restoreZone(ctx context.Context, client *hetzner_dns.Client, zoneSnapshot ZoneSnapshot, liveZones []hetzner_dns.Zone, dryRun bool) (*ZoneRestore, error) {
+	restore := &ZoneRestore{Name: zoneSnapshot.Zone.Name}
+
+	desired := make([]hetzner_dns.RecordRequest, 0, len(zoneSnapshot.Records))
+	for i := range zoneSnapshot.Records {
+		desired = append(desired, zoneSnapshot.Records[i].Request())
+	}
+
+	var liveZone *hetzner_dns.Zone
+	for i := range liveZones {
+		if strings.EqualFold(liveZones[i].Name, zoneSnapshot.Zone.Name) {
+			liveZone = &liveZones[i]
+			break
+		}
+	}
+
+	var current []hetzner_dns.Record
+	zoneId := ""
+	if liveZone == nil {
+		restore.Created = true
+		if !dryRun {
+			zoneResponse, err := client.CreateZone(ctx, hetzner_dns.ZoneRequest{
+				Name: zoneSnapshot.Zone.Name,
+				TTL:  zoneSnapshot.Zone.TTL,
+			})
+			if err != nil {
+				return nil, err
+			}
+			zoneId = zoneResponse.Zone.ID
+			// newly created zones come with default records (SOA, NS)
+			if current, err = client.GetAllRecords(ctx, zoneId); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		zoneId = liveZone.ID
+		var err error
+		if current, err = client.GetAllRecords(ctx, zoneId); err != nil {
+			return nil, err
+		}
+	}
+
+	restore.ChangeSet = hetzner_dns.PlanChanges(zoneId, current, desired)
+	if dryRun || restore.ChangeSet.Empty() {
+		return restore, nil
+	}
+	return restore, client.ApplyChanges(ctx, restore.ChangeSet)
+}
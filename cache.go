@@ -0,0 +1,323 @@
+package hetzner_dns
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const DEFAULT_CACHE_TTL = time.Minute
+
+// CacheResource identifies a kind of cached resource.
+type CacheResource string
+
+const (
+	CACHE_ZONES   CacheResource = "zones"
+	CACHE_RECORDS CacheResource = "records"
+	CACHE_RECORD  CacheResource = "record"
+)
+
+// CacheStats holds the usage statistics of a cached resource. Shared counts
+// the misses served by a fetch already in flight for another caller.
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+	Shared uint64
+}
+
+type cacheEntry struct {
+	value   interface{}
+	expires time.Time
+}
+
+// CachedClient is a read-through cache in front of a Client. Zones and
+// records are cached for a configurable time, concurrent identical fetches
+// are deduplicated, and the mutating methods called through the CachedClient
+// invalidate the affected entries. Changes made by other processes are only
+// seen when the entries expire.
+type CachedClient struct {
+	*Client
+
+	// ZonesTTL is the lifetime of cached zones (DEFAULT_CACHE_TTL if zero).
+	ZonesTTL time.Duration
+	// RecordsTTL is the lifetime of cached record lists (DEFAULT_CACHE_TTL if zero).
+	RecordsTTL time.Duration
+	// RecordTTL is the lifetime of cached single records (DEFAULT_CACHE_TTL if zero).
+	RecordTTL time.Duration
+
+	mu         sync.Mutex
+	entries    map[string]cacheEntry
+	generation uint64
+	stats      map[CacheResource]*CacheStats
+	group      singleflight.Group
+}
+
+// NewCachedClient creates a caching wrapper around client.
+func NewCachedClient(client *Client) *CachedClient {
+	return &CachedClient{Client: client}
+}
+
+// Stats returns the usage statistics of the cache, by resource.
+func (cached *CachedClient) Stats() map[CacheResource]CacheStats {
+	cached.mu.Lock()
+	defer cached.mu.Unlock()
+	stats := map[CacheResource]CacheStats{}
+	for resource, resourceStats := range cached.stats {
+		stats[resource] = *resourceStats
+	}
+	return stats
+}
+
+// Invalidate drops all the cached entries.
+func (cached *CachedClient) Invalidate() {
+	cached.invalidate("")
+}
+
+func (cached *CachedClient) GetZones(ctx context.Context, name string, searchName string, page int, perPage int) (*ZonesResponse, error) {
+	key := fmt.Sprintf("zones:list:%s:%s:%d:%d", name, searchName, page, perPage)
+	value, err := cached.fetch(CACHE_ZONES, key, func() (interface{}, error) {
+		return cached.Client.GetZones(ctx, name, searchName, page, perPage)
+	})
+	if err != nil {
+		return nil, err
+	}
+	zonesResponse := *value.(*ZonesResponse)
+	zonesResponse.Zones = append([]Zone(nil), zonesResponse.Zones...)
+	return &zonesResponse, nil
+}
+
+func (cached *CachedClient) GetAllZones(ctx context.Context) ([]Zone, error) {
+	value, err := cached.fetch(CACHE_ZONES, "zones:all", func() (interface{}, error) {
+		return cached.Client.GetAllZones(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append([]Zone(nil), value.([]Zone)...), nil
+}
+
+func (cached *CachedClient) GetZone(ctx context.Context, zoneId string) (*ZoneResponse, error) {
+	value, err := cached.fetch(CACHE_ZONES, "zones:id:"+zoneId, func() (interface{}, error) {
+		return cached.Client.GetZone(ctx, zoneId)
+	})
+	if err != nil {
+		return nil, err
+	}
+	zoneResponse := *value.(*ZoneResponse)
+	return &zoneResponse, nil
+}
+
+func (cached *CachedClient) GetRecords(ctx context.Context, zoneId string, page int, perPage int) (*RecordsResponse, error) {
+	key := fmt.Sprintf("records:%s:%d:%d", zoneId, page, perPage)
+	value, err := cached.fetch(CACHE_RECORDS, key, func() (interface{}, error) {
+		return cached.Client.GetRecords(ctx, zoneId, page, perPage)
+	})
+	if err != nil {
+		return nil, err
+	}
+	recordsResponse := *value.(*RecordsResponse)
+	recordsResponse.Records = append([]Record(nil), recordsResponse.Records...)
+	return &recordsResponse, nil
+}
+
+func (cached *CachedClient) GetAllRecords(ctx context.Context, zoneId string) ([]Record, error) {
+	value, err := cached.fetch(CACHE_RECORDS, fmt.Sprintf("records:%s:all", zoneId), func() (interface{}, error) {
+		return cached.Client.GetAllRecords(ctx, zoneId)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append([]Record(nil), value.([]Record)...), nil
+}
+
+func (cached *CachedClient) GetRecord(ctx context.Context, recordId string) (*RecordResponse, error) {
+	value, err := cached.fetch(CACHE_RECORD, "record:"+recordId, func() (interface{}, error) {
+		return cached.Client.GetRecord(ctx, recordId)
+	})
+	if err != nil {
+		return nil, err
+	}
+	recordResponse := *value.(*RecordResponse)
+	return &recordResponse, nil
+}
+
+func (cached *CachedClient) CreateRecord(ctx context.Context, record RecordRequest) (*RecordResponse, error) {
+	defer cached.invalidateZone(record.ZoneID)
+	return cached.Client.CreateRecord(ctx, record)
+}
+
+func (cached *CachedClient) UpdateRecord(ctx context.Context, record RecordRequest) (*RecordResponse, error) {
+	defer cached.invalidateRecord(record.ID, record.ZoneID)
+	return cached.Client.UpdateRecord(ctx, record)
+}
+
+func (cached *CachedClient) CreateOrUpdateRecord(ctx context.Context, record RecordRequest) (*RecordResponse, error) {
+	recordResponse, err := cached.Client.CreateOrUpdateRecord(ctx, record)
+	if recordResponse != nil {
+		cached.invalidateRecord(recordResponse.Record.ID, record.ZoneID)
+	} else {
+		cached.invalidateZone(record.ZoneID)
+	}
+	return recordResponse, err
+}
+
+func (cached *CachedClient) DeleteRecord(ctx context.Context, recordId string) error {
+	defer cached.invalidateRecord(recordId, "")
+	return cached.Client.DeleteRecord(ctx, recordId)
+}
+
+func (cached *CachedClient) BulkCreateRecords(ctx context.Context, bulkRecordsRequest *BulkRecordRequest) (*BulkRecordResponse, error) {
+	defer cached.invalidateBulk(bulkRecordsRequest)
+	return cached.Client.BulkCreateRecords(ctx, bulkRecordsRequest)
+}
+
+func (cached *CachedClient) BulkUpdateRecords(ctx context.Context, bulkRecordsRequest *BulkRecordRequest) (*BulkRecordResponse, error) {
+	defer cached.invalidateBulk(bulkRecordsRequest)
+	return cached.Client.BulkUpdateRecords(ctx, bulkRecordsRequest)
+}
+
+func (cached *CachedClient) ApplyChanges(ctx context.Context, changeSet *ChangeSet) error {
+	defer cached.invalidateZone(changeSet.ZoneID)
+	for _, change := range changeSet.Changes {
+		if change.Current != nil {
+			defer cached.invalidate("record:" + change.Current.ID)
+		}
+	}
+	return cached.Client.ApplyChanges(ctx, changeSet)
+}
+
+func (cached *CachedClient) CreateZone(ctx context.Context, zone ZoneRequest) (*ZoneResponse, error) {
+	defer cached.invalidate("zones:")
+	return cached.Client.CreateZone(ctx, zone)
+}
+
+func (cached *CachedClient) UpdateZone(ctx context.Context, zoneId string, zone ZoneRequest) (*ZoneResponse, error) {
+	defer cached.invalidate("zones:")
+	return cached.Client.UpdateZone(ctx, zoneId, zone)
+}
+
+func (cached *CachedClient) DeleteZone(ctx context.Context, zoneId string) error {
+	defer cached.invalidateZone(zoneId)
+	return cached.Client.DeleteZone(ctx, zoneId)
+}
+
+// fetch returns the cached value for key, calling fn on a miss. Concurrent
+// misses for the same key share a single call to fn.
+func (cached *CachedClient) fetch(resource CacheResource, key string, fn func() (interface{}, error)) (interface{}, error) {
+	cached.mu.Lock()
+	if entry, ok := cached.entries[key]; ok && time.Now().Before(entry.expires) {
+		cached.resourceStats(resource).Hits++
+		cached.mu.Unlock()
+		return entry.value, nil
+	}
+	cached.resourceStats(resource).Misses++
+	generation := cached.generation
+	cached.mu.Unlock()
+
+	value, err, shared := cached.group.Do(key, func() (interface{}, error) {
+		value, err := fn()
+		if err != nil {
+			return nil, err
+		}
+		cached.mu.Lock()
+		defer cached.mu.Unlock()
+		// don't store values fetched before an invalidation
+		if cached.generation == generation {
+			if cached.entries == nil {
+				cached.entries = map[string]cacheEntry{}
+			}
+			cached.entries[key] = cacheEntry{value: value, expires: time.Now().Add(cached.ttl(resource))}
+		}
+		return value, nil
+	})
+	if shared {
+		cached.mu.Lock()
+		cached.resourceStats(resource).Shared++
+		cached.mu.Unlock()
+	}
+	return value, err
+}
+
+func (cached *CachedClient) resourceStats(resource CacheResource) *CacheStats {
+	if cached.stats == nil {
+		cached.stats = map[CacheResource]*CacheStats{}
+	}
+	stats, ok := cached.stats[resource]
+	if !ok {
+		stats = &CacheStats{}
+		cached.stats[resource] = stats
+	}
+	return stats
+}
+
+func (cached *CachedClient) ttl(resource CacheResource) time.Duration {
+	var ttl time.Duration
+	switch resource {
+	case CACHE_ZONES:
+		ttl = cached.ZonesTTL
+	case CACHE_RECORDS:
+		ttl = cached.RecordsTTL
+	case CACHE_RECORD:
+		ttl = cached.RecordTTL
+	}
+	if ttl <= 0 {
+		ttl = DEFAULT_CACHE_TTL
+	}
+	return ttl
+}
+
+// invalidate drops the entries whose key starts with any of the prefixes.
+func (cached *CachedClient) invalidate(prefixes ...string) {
+	cached.mu.Lock()
+	defer cached.mu.Unlock()
+	cached.generation++
+	for key := range cached.entries {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(key, prefix) {
+				delete(cached.entries, key)
+				break
+			}
+		}
+	}
+}
+
+// invalidateZone drops the zone listings (record counts change) and the
+// records of the zone, or of all zones if zoneId is unknown.
+func (cached *CachedClient) invalidateZone(zoneId string) {
+	cached.invalidate("zones:", recordsPrefix(zoneId))
+}
+
+// invalidateRecord drops a record and the records of its zone. When zoneId
+// is unknown, the zone is looked up in the cached record.
+func (cached *CachedClient) invalidateRecord(recordId string, zoneId string) {
+	if zoneId == "" {
+		cached.mu.Lock()
+		if entry, ok := cached.entries["record:"+recordId]; ok {
+			zoneId = entry.value.(*RecordResponse).Record.ZoneID
+		}
+		cached.mu.Unlock()
+	}
+	cached.invalidate("record:"+recordId, "zones:", recordsPrefix(zoneId))
+}
+
+func (cached *CachedClient) invalidateBulk(bulkRecordsRequest *BulkRecordRequest) {
+	prefixes := []string{"zones:"}
+	for _, record := range bulkRecordsRequest.Records {
+		prefixes = append(prefixes, recordsPrefix(record.ZoneID))
+		if record.ID != "" {
+			prefixes = append(prefixes, "record:"+record.ID)
+		}
+	}
+	cached.invalidate(prefixes...)
+}
+
+func recordsPrefix(zoneId string) string {
+	if zoneId == "" {
+		return "records:"
+	}
+	return "records:" + zoneId + ":"
+}
@@ -0,0 +1,86 @@
+package hetzner_dns_test
+
+import (
+	"context"
+	"net/http"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+	"github.com/panta/go-hetzner-dns/internal/fakeapi"
+)
+
+func TestCachedClient(t *testing.T) {
+	api := fakeapi.New(t)
+	defer api.Close()
+	api.AddZone("zone-1", "example.com")
+	api.AddRecord("zone-1", "www", "A", "10.0.0.1")
+
+	ctx := context.Background()
+	cached := hetzner_dns.NewCachedClient(api.Client())
+	cached.RecordsTTL = time.Hour
+
+	for i := 0; i < 3; i++ {
+		records, err := cached.GetAllRecords(ctx, "zone-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != 1 {
+			t.Fatalf("Wrong # of records: %d", len(records))
+		}
+	}
+	if n := api.CallCount("GET /records"); n != 1 {
+		t.Errorf("Expected 1 API call, got %d", n)
+	}
+	stats := cached.Stats()[hetzner_dns.CACHE_RECORDS]
+	if stats.Hits != 2 || stats.Misses != 1 {
+		t.Errorf("Wrong stats: %+v", stats)
+	}
+
+	// mutations through the cached client invalidate the zone records
+	if _, err := cached.CreateRecord(ctx, hetzner_dns.RecordRequest{ZoneID: "zone-1", Name: "api", Type: "A", Value: "10.0.0.2"}); err != nil {
+		t.Fatal(err)
+	}
+	records, err := cached.GetAllRecords(ctx, "zone-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Errorf("Stale records after CreateRecord: %d", len(records))
+	}
+
+	// concurrent fetches are deduplicated: the first one is held until all
+	// the callers are waiting for it
+	cached.Invalidate()
+	before := api.CallCount("GET /zones")
+	gate := make(chan struct{})
+	api.Before = func(req *http.Request) {
+		if req.URL.Path == "/zones" {
+			<-gate
+		}
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := cached.GetAllZones(ctx); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	for cached.Stats()[hetzner_dns.CACHE_ZONES].Misses < 10 {
+		runtime.Gosched()
+	}
+	close(gate)
+	wg.Wait()
+	if n := api.CallCount("GET /zones") - before; n != 1 {
+		t.Errorf("Expected 1 zone fetch, got %d", n)
+	}
+	zoneStats := cached.Stats()[hetzner_dns.CACHE_ZONES]
+	if zoneStats.Hits+zoneStats.Misses != 10 {
+		t.Errorf("Wrong zone stats: %+v", zoneStats)
+	}
+}
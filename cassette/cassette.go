@@ -0,0 +1,318 @@
+// Package cassette provides an http.RoundTripper recording the interactions
+// of a Client with the API to a fixture file and replaying them, to write
+// deterministic tests of real API flows without network access.
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Mode is the operating mode of a Recorder.
+type Mode int
+
+const (
+	// MODE_REPLAY serves the requests from the cassette, without network
+	// access.
+	MODE_REPLAY Mode = iota
+	// MODE_RECORD sends the requests and records the interactions.
+	MODE_RECORD
+)
+
+// REDACTED replaces scrubbed values.
+const REDACTED = "REDACTED"
+
+var (
+	// ErrUnmatched is returned in replay mode for requests not matching any
+	// recorded interaction.
+	ErrUnmatched = errors.New("cassette: no recorded interaction matches request")
+
+	// DefaultScrubHeaders are the headers whose values are not recorded.
+	DefaultScrubHeaders = []string{"Auth-API-Token", "Authorization", "Cookie", "Set-Cookie"}
+	// DefaultScrubFields are the JSON fields, at any depth of request and
+	// response bodies, whose values are not recorded (account identifiers).
+	DefaultScrubFields = []string{"owner", "project", "account", "account_id"}
+)
+
+// Request is a recorded request.
+type Request struct {
+	Method  string      `json:"method"`
+	Path    string      `json:"path"`
+	Query   string      `json:"query,omitempty"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body,omitempty"`
+}
+
+// Response is a recorded response.
+type Response struct {
+	StatusCode int         `json:"status_code"`
+	Headers    http.Header `json:"headers,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// Interaction is a recorded request and its response.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Cassette is the content of a fixture file.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Recorder is an http.RoundTripper recording or replaying interactions.
+// In replay mode each recorded interaction is used at most once, in order,
+// so that repeated identical requests get their successive responses.
+type Recorder struct {
+	Path string
+	Mode Mode
+	// Next sends the requests in record mode (http.DefaultTransport if nil).
+	Next http.RoundTripper
+	// Matchers decide whether a request matches a recorded one, all of them
+	// having to agree (DefaultMatchers if nil).
+	Matchers []Matcher
+	// ScrubHeaders and ScrubFields are scrubbed when recording
+	// (DefaultScrubHeaders and DefaultScrubFields if nil).
+	ScrubHeaders []string
+	ScrubFields  []string
+	// OnUnmatched, if set, is called with the error of unmatched requests in
+	// replay mode, e.g. to fail the test immediately.
+	OnUnmatched func(error)
+
+	mu       sync.Mutex
+	cassette Cassette
+	used     []bool
+}
+
+// New creates a Recorder for the cassette at path. In replay mode the
+// cassette must exist.
+func New(path string, mode Mode) (*Recorder, error) {
+	recorder := &Recorder{Path: path, Mode: mode}
+	if mode == MODE_REPLAY {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "can't read cassette")
+		}
+		if err := json.Unmarshal(data, &recorder.cassette); err != nil {
+			return nil, errors.Wrapf(err, "can't parse cassette %s", path)
+		}
+		recorder.used = make([]bool, len(recorder.cassette.Interactions))
+	}
+	return recorder, nil
+}
+
+// ModeFromEnv returns MODE_RECORD if the environment variable name is set to
+// a non-empty value, MODE_REPLAY otherwise.
+func ModeFromEnv(name string) Mode {
+	if os.Getenv(name) != "" {
+		return MODE_RECORD
+	}
+	return MODE_REPLAY
+}
+
+// RoundTrip implements http.RoundTripper.
+func (recorder *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, errors.Wrap(err, "cassette: can't read request body")
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	if recorder.Mode == MODE_RECORD {
+		return recorder.record(req, body)
+	}
+	return recorder.replay(req, body)
+}
+
+func (recorder *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	next := recorder.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, "cassette: can't read response body")
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	interaction := Interaction{
+		Request: Request{
+			Method:  req.Method,
+			Path:    req.URL.Path,
+			Query:   req.URL.RawQuery,
+			Headers: recorder.scrubHeaders(req.Header),
+			Body:    recorder.scrubBody(body),
+		},
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Headers:    recorder.scrubHeaders(resp.Header),
+			Body:       recorder.scrubBody(respBody),
+		},
+	}
+	recorder.mu.Lock()
+	recorder.cassette.Interactions = append(recorder.cassette.Interactions, interaction)
+	recorder.mu.Unlock()
+	return resp, nil
+}
+
+func (recorder *Recorder) replay(req *http.Request, body []byte) (*http.Response, error) {
+	matchers := recorder.Matchers
+	if matchers == nil {
+		matchers = DefaultMatchers
+	}
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	for i := range recorder.cassette.Interactions {
+		if recorder.used[i] {
+			continue
+		}
+		interaction := &recorder.cassette.Interactions[i]
+		if !matchAll(matchers, req, body, &interaction.Request) {
+			continue
+		}
+		recorder.used[i] = true
+		header := http.Header{}
+		for key, values := range interaction.Response.Headers {
+			header[key] = append([]string(nil), values...)
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", interaction.Response.StatusCode, http.StatusText(interaction.Response.StatusCode)),
+			StatusCode:    interaction.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          ioutil.NopCloser(strings.NewReader(interaction.Response.Body)),
+			ContentLength: int64(len(interaction.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	err := errors.Wrapf(ErrUnmatched, "%s %s (body %q) in %s", req.Method, req.URL.RequestURI(), body, recorder.Path)
+	if recorder.OnUnmatched != nil {
+		recorder.OnUnmatched(err)
+	}
+	return nil, err
+}
+
+// Unused returns the recorded interactions not replayed yet, e.g. to check
+// at the end of a test that all the expected requests were sent.
+func (recorder *Recorder) Unused() []Interaction {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	var unused []Interaction
+	for i, interaction := range recorder.cassette.Interactions {
+		if !recorder.used[i] {
+			unused = append(unused, interaction)
+		}
+	}
+	return unused
+}
+
+// Save writes the recorded interactions to the cassette file. It does
+// nothing in replay mode.
+func (recorder *Recorder) Save() error {
+	if recorder.Mode != MODE_RECORD {
+		return nil
+	}
+	recorder.mu.Lock()
+	data, err := json.MarshalIndent(&recorder.cassette, "", "  ")
+	recorder.mu.Unlock()
+	if err != nil {
+		return errors.Wrap(err, "can't encode cassette")
+	}
+	if err := ioutil.WriteFile(recorder.Path, append(data, '\n'), 0o644); err != nil {
+		return errors.Wrap(err, "can't write cassette")
+	}
+	return nil
+}
+
+func (recorder *Recorder) scrubHeaders(header http.Header) http.Header {
+	names := recorder.ScrubHeaders
+	if names == nil {
+		names = DefaultScrubHeaders
+	}
+	scrubbed := http.Header{}
+	for key, values := range header {
+		scrubbed[key] = append([]string(nil), values...)
+	}
+	for _, name := range names {
+		if _, ok := scrubbed[http.CanonicalHeaderKey(name)]; ok {
+			scrubbed.Set(name, REDACTED)
+		}
+	}
+	if len(scrubbed) == 0 {
+		return nil
+	}
+	return scrubbed
+}
+
+// scrubBody replaces the values of the scrubbed fields of JSON bodies.
+// Other bodies are recorded as they are.
+func (recorder *Recorder) scrubBody(body []byte) string {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return ""
+	}
+	fields := recorder.ScrubFields
+	if fields == nil {
+		fields = DefaultScrubFields
+	}
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return string(body)
+	}
+	scrubbed, err := json.Marshal(scrubValue(value, fields))
+	if err != nil {
+		return string(body)
+	}
+	return string(scrubbed)
+}
+
+func scrubValue(value interface{}, fields []string) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			if containsFold(fields, key) {
+				if s, ok := item.(string); ok && s != "" {
+					value[key] = REDACTED
+				}
+				continue
+			}
+			value[key] = scrubValue(item, fields)
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = scrubValue(item, fields)
+		}
+	}
+	return value
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
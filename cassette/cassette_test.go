@@ -0,0 +1,115 @@
+package cassette_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+	"github.com/panta/go-hetzner-dns/cassette"
+)
+
+func TestRecordReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cassette")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "zones.json")
+
+	hs := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch {
+		case req.URL.Path == "/zones":
+			_, _ = io.WriteString(rw, `{"zones": [{"id": "zone-1", "name": "example.com", "owner": "Jane Doe", "project": "secret-project"}]}`)
+		case req.URL.Path == "/records" && req.Method == http.MethodPost:
+			request := hetzner_dns.RecordRequest{}
+			_ = json.NewDecoder(req.Body).Decode(&request)
+			_ = json.NewEncoder(rw).Encode(&hetzner_dns.RecordResponse{Record: hetzner_dns.Record{ID: "rec-1", ZoneID: request.ZoneID, Name: request.Name}})
+		default:
+			http.NotFound(rw, req)
+		}
+	}))
+	baseURL := hs.URL
+
+	// record
+	recorder, err := cassette.New(path, cassette.MODE_RECORD)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &hetzner_dns.Client{BaseURL: baseURL, ApiKey: "very-secret-token", HttpClient: &http.Client{Transport: recorder}}
+	if _, err := client.GetZones(context.Background(), "", "", 1, 100); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CreateRecord(context.Background(), hetzner_dns.RecordRequest{ZoneID: "zone-1", Name: "www", Type: "A", Value: "127.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.Save(); err != nil {
+		t.Fatal(err)
+	}
+	hs.Close()
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"very-secret-token", "Jane Doe", "secret-project"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("expected %q to be scrubbed from the cassette", secret)
+		}
+	}
+
+	// replay, without network
+	recorder, err = cassette.New(path, cassette.MODE_REPLAY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client = &hetzner_dns.Client{BaseURL: baseURL, ApiKey: "another-token", HttpClient: &http.Client{Transport: recorder}}
+	zonesResponse, err := client.GetZones(context.Background(), "", "", 1, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zonesResponse.Zones) != 1 || zonesResponse.Zones[0].Name != "example.com" || zonesResponse.Zones[0].Owner != cassette.REDACTED {
+		t.Errorf("unexpected zones %+v", zonesResponse.Zones)
+	}
+	recordResponse, err := client.CreateRecord(context.Background(), hetzner_dns.RecordRequest{ZoneID: "zone-1", Name: "www", Type: "A", Value: "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recordResponse.Record.ID != "rec-1" {
+		t.Errorf("unexpected record %+v", recordResponse.Record)
+	}
+	if unused := recorder.Unused(); len(unused) != 0 {
+		t.Errorf("expected all interactions to be replayed, got %d unused", len(unused))
+	}
+
+	// the body is part of the match
+	var unmatched error
+	recorder.OnUnmatched = func(err error) { unmatched = err }
+	_, err = client.CreateRecord(context.Background(), hetzner_dns.RecordRequest{ZoneID: "zone-1", Name: "mail", Type: "A", Value: "127.0.0.1"})
+	if errors.Cause(unmatched) != cassette.ErrUnmatched || err == nil {
+		t.Errorf("expected an unmatched request, got %v", err)
+	}
+}
+
+func TestMatchers(t *testing.T) {
+	recorded := &cassette.Request{Method: "GET", Path: "/records", Query: "page=1&zone_id=z", Body: `{"a": 1, "b": [1, 2], "owner": "REDACTED"}`}
+	req := httptest.NewRequest("GET", "/records?zone_id=z&page=1", nil)
+	if !cassette.MatchQuery(req, nil, recorded) {
+		t.Error("expected the query to match regardless of order")
+	}
+	if !cassette.MatchJSONBody(req, []byte(`{"b":[1,2],"owner":"someone","a":1}`), recorded) {
+		t.Error("expected the JSON body to match regardless of key order and scrubbed fields")
+	}
+	if cassette.MatchJSONBody(req, []byte(`{"a": 2, "b": [1, 2], "owner": "x"}`), recorded) {
+		t.Error("expected different JSON bodies not to match")
+	}
+}
@@ -0,0 +1,92 @@
+package cassette
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// Matcher returns true if a request, with its body, matches a recorded one.
+type Matcher func(req *http.Request, body []byte, recorded *Request) bool
+
+// DefaultMatchers match on method, path, query and JSON body.
+var DefaultMatchers = []Matcher{MatchMethod, MatchPath, MatchQuery, MatchJSONBody}
+
+// MatchMethod matches the HTTP method.
+func MatchMethod(req *http.Request, body []byte, recorded *Request) bool {
+	return req.Method == recorded.Method
+}
+
+// MatchPath matches the URL path.
+func MatchPath(req *http.Request, body []byte, recorded *Request) bool {
+	return req.URL.Path == recorded.Path
+}
+
+// MatchQuery matches the query parameters, regardless of their order.
+func MatchQuery(req *http.Request, body []byte, recorded *Request) bool {
+	recordedQuery, err := url.ParseQuery(recorded.Query)
+	if err != nil {
+		return false
+	}
+	query := req.URL.Query()
+	if len(query) == 0 && len(recordedQuery) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(query, recordedQuery)
+}
+
+// MatchJSONBody matches JSON bodies by content, regardless of formatting and
+// key order, and other bodies byte by byte. Scrubbed fields of the recorded
+// body match any value.
+func MatchJSONBody(req *http.Request, body []byte, recorded *Request) bool {
+	if strings.TrimSpace(string(body)) == strings.TrimSpace(recorded.Body) {
+		return true
+	}
+	var value, recordedValue interface{}
+	if json.Unmarshal(body, &value) != nil || json.Unmarshal([]byte(recorded.Body), &recordedValue) != nil {
+		return false
+	}
+	return jsonEqual(value, recordedValue)
+}
+
+func jsonEqual(value interface{}, recorded interface{}) bool {
+	if s, ok := recorded.(string); ok && s == REDACTED {
+		return true
+	}
+	switch recorded := recorded.(type) {
+	case map[string]interface{}:
+		object, ok := value.(map[string]interface{})
+		if !ok || len(object) != len(recorded) {
+			return false
+		}
+		for key, item := range recorded {
+			if !jsonEqual(object[key], item) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		array, ok := value.([]interface{})
+		if !ok || len(array) != len(recorded) {
+			return false
+		}
+		for i := range recorded {
+			if !jsonEqual(array[i], recorded[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(value, recorded)
+}
+
+func matchAll(matchers []Matcher, req *http.Request, body []byte, recorded *Request) bool {
+	for _, matcher := range matchers {
+		if !matcher(req, body, recorded) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,212 @@
+package hetzner_dns
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ChangeAction is the kind of operation needed on a record to reconcile a zone.
+type ChangeAction string
+
+const (
+	CHANGE_CREATE ChangeAction = "create"
+	CHANGE_UPDATE ChangeAction = "update"
+	CHANGE_DELETE ChangeAction = "delete"
+)
+
+// RecordChange is a single operation on a record.
+// Current is the live record (nil for CHANGE_CREATE), Desired the wanted
+// state (nil for CHANGE_DELETE).
+type RecordChange struct {
+	Action  ChangeAction
+	Current *Record
+	Desired *RecordRequest
+}
+
+func (change RecordChange) String() string {
+	switch change.Action {
+	case CHANGE_CREATE:
+		return fmt.Sprintf("+ %s", DescribeRecord(change.Desired.Name, change.Desired.Type, change.Desired.Value, change.Desired.TTL))
+	case CHANGE_DELETE:
+		return fmt.Sprintf("- %s", DescribeRecord(change.Current.Name, change.Current.Type, change.Current.Value, change.Current.TTL))
+	default:
+		return fmt.Sprintf("~ %s -> %s",
+			DescribeRecord(change.Current.Name, change.Current.Type, change.Current.Value, change.Current.TTL),
+			DescribeRecord(change.Desired.Name, change.Desired.Type, change.Desired.Value, change.Desired.TTL))
+	}
+}
+
+// ChangeSet lists the operations needed to bring a zone to a desired state.
+type ChangeSet struct {
+	ZoneID  string
+	Changes []RecordChange
+}
+
+// Empty returns true if the change set contains no changes.
+func (changeSet *ChangeSet) Empty() bool {
+	return len(changeSet.Changes) == 0
+}
+
+// Count returns the number of changes with the given action.
+func (changeSet *ChangeSet) Count(action ChangeAction) int {
+	count := 0
+	for _, change := range changeSet.Changes {
+		if change.Action == action {
+			count++
+		}
+	}
+	return count
+}
+
+func (changeSet *ChangeSet) String() string {
+	var sb strings.Builder
+	for _, change := range changeSet.Changes {
+		sb.WriteString(change.String())
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// PlanChanges computes the changes needed to turn the current records of a
+// zone into the desired ones. Records are matched by name, type and value;
+// records with the same name and type but a different value are updated in
+// place. SOA records are managed by Hetzner and are ignored.
+func PlanChanges(zoneId string, current []Record, desired []RecordRequest) *ChangeSet {
+	changeSet := &ChangeSet{ZoneID: zoneId}
+
+	unmatched := map[string][]*Record{}
+	var order []string
+	for i := range current {
+		record := &current[i]
+		if record.Type == "SOA" {
+			continue
+		}
+		key := RecordKey(record.Name, record.Type, record.Value)
+		if _, ok := unmatched[key]; !ok {
+			order = append(order, key)
+		}
+		unmatched[key] = append(unmatched[key], record)
+	}
+
+	var pending []RecordRequest
+	for i := range desired {
+		request := desired[i]
+		if request.Type == "SOA" {
+			continue
+		}
+		request.ZoneID = zoneId
+		key := RecordKey(request.Name, request.Type, request.Value)
+		if records := unmatched[key]; len(records) > 0 {
+			record := records[0]
+			unmatched[key] = records[1:]
+			if record.TTL != request.TTL {
+				request.ID = record.ID
+				changeSet.Changes = append(changeSet.Changes, RecordChange{Action: CHANGE_UPDATE, Current: record, Desired: &request})
+			}
+			continue
+		}
+		pending = append(pending, request)
+	}
+
+	// pair remaining records having the same name and type
+	var leftover []*Record
+	for _, key := range order {
+		leftover = append(leftover, unmatched[key]...)
+	}
+	for i := range pending {
+		request := pending[i]
+		paired := false
+		for j, record := range leftover {
+			if record != nil && sameNameType(record.Name, record.Type, request.Name, request.Type) {
+				request.ID = record.ID
+				changeSet.Changes = append(changeSet.Changes, RecordChange{Action: CHANGE_UPDATE, Current: record, Desired: &request})
+				leftover[j] = nil
+				paired = true
+				break
+			}
+		}
+		if !paired {
+			changeSet.Changes = append(changeSet.Changes, RecordChange{Action: CHANGE_CREATE, Desired: &request})
+		}
+	}
+	for _, record := range leftover {
+		if record != nil {
+			changeSet.Changes = append(changeSet.Changes, RecordChange{Action: CHANGE_DELETE, Current: record})
+		}
+	}
+	return changeSet
+}
+
+// ApplyChanges performs the changes of a change set: updates and creations
+// first, through the bulk endpoints, then deletions, so that a failure
+// leaves the zone without the replaced records rather than without any.
+func (client *Client) ApplyChanges(ctx context.Context, changeSet *ChangeSet) error {
+	var creates, updates []RecordRequest
+	var deletes []*Record
+	for _, change := range changeSet.Changes {
+		switch change.Action {
+		case CHANGE_DELETE:
+			deletes = append(deletes, change.Current)
+		case CHANGE_UPDATE:
+			updates = append(updates, *change.Desired)
+		case CHANGE_CREATE:
+			creates = append(creates, *change.Desired)
+		}
+	}
+
+	if len(updates) > 0 {
+		bulkRecordResponse, err := client.BulkUpdateRecords(ctx, &BulkRecordRequest{Records: updates})
+		if err != nil {
+			return errors.Wrap(err, "can't update records")
+		}
+		if n := len(bulkRecordResponse.InvalidRecords) + len(bulkRecordResponse.FailedRecords); n > 0 {
+			return errors.Errorf("hetzner_dns: %d records failed to update", n)
+		}
+	}
+	if len(creates) > 0 {
+		bulkRecordResponse, err := client.BulkCreateRecords(ctx, &BulkRecordRequest{Records: creates})
+		if err != nil {
+			return errors.Wrap(err, "can't create records")
+		}
+		if n := len(bulkRecordResponse.InvalidRecords) + len(bulkRecordResponse.FailedRecords); n > 0 {
+			return errors.Errorf("hetzner_dns: %d records failed to create", n)
+		}
+	}
+	for _, record := range deletes {
+		if err := client.DeleteRecord(ctx, record.ID); err != nil {
+			return errors.Wrapf(err, "can't delete record %s", record.ID)
+		}
+	}
+	return nil
+}
+
+// RecordKey returns a key identifying a record by its name (normalized),
+// type and value, e.g. to find the records already existing in a zone.
+func RecordKey(name string, recordType string, value string) string {
+	return normalizeName(name) + "\x00" + strings.ToUpper(recordType) + "\x00" + value
+}
+
+func sameNameType(name1, type1, name2, type2 string) bool {
+	return normalizeName(name1) == normalizeName(name2) && strings.EqualFold(type1, type2)
+}
+
+// normalizeName returns the canonical form of a record name relative to its
+// zone, using "@" for the apex.
+func normalizeName(name string) string {
+	if name == "" {
+		return "@"
+	}
+	return strings.ToLower(name)
+}
+
+// DescribeRecord returns a record as a line of a zone file, e.g.
+// "www 300 A 192.0.2.1", without the TTL if zero.
+func DescribeRecord(name string, recordType string, value string, ttl int) string {
+	if ttl > 0 {
+		return fmt.Sprintf("%s %d %s %s", normalizeName(name), ttl, recordType, value)
+	}
+	return fmt.Sprintf("%s %s %s", normalizeName(name), recordType, value)
+}
@@ -0,0 +1,31 @@
+package hetzner_dns_test
+
+import (
+	"context"
+	"testing"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+	"github.com/panta/go-hetzner-dns/internal/fakeapi"
+)
+
+func TestApplyChangesDeletesLast(t *testing.T) {
+	api := fakeapi.New(t)
+	defer api.Close()
+	api.AddZone("zone-1", "example.com")
+	oldId := api.AddRecord("zone-1", "old", "A", "192.0.2.1")
+	records := api.ZoneRecords("zone-1")
+
+	changeSet := &hetzner_dns.ChangeSet{ZoneID: "zone-1", Changes: []hetzner_dns.RecordChange{
+		{Action: hetzner_dns.CHANGE_DELETE, Current: &records[0]},
+		{Action: hetzner_dns.CHANGE_CREATE, Desired: &hetzner_dns.RecordRequest{ZoneID: "zone-1", Name: "new", Type: "A"}},
+	}}
+	if err := api.Client().ApplyChanges(context.Background(), changeSet); err == nil {
+		t.Fatal("expected the invalid creation to fail")
+	}
+	if records := api.ZoneRecords("zone-1"); len(records) != 1 || records[0].ID != oldId {
+		t.Errorf("expected the record to be kept when the creation fails, got %+v", records)
+	}
+	if api.CallCount("DELETE") != 0 {
+		t.Errorf("expected no deletions")
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+	"github.com/panta/go-hetzner-dns/audit"
+)
+
+// cmdAudit prints the entries of an audit log, filtered by zone, record name
+// and time range, as a table or as JSON lines.
+func cmdAudit(flagSet *flag.FlagSet, logPath string, zone string, name string, since string, until string, asJSON bool) {
+	filter := audit.Filter{Zone: zone, Name: name}
+	var err error
+	if filter.Since, err = parseTimeFlag(since); err != nil {
+		log.Fatalf("invalid -since: %v", err)
+	}
+	if filter.Until, err = parseTimeFlag(until); err != nil {
+		log.Fatalf("invalid -until: %v", err)
+	}
+
+	entries, err := audit.QueryFile(logPath, filter)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		for i := range entries {
+			if err := encoder.Encode(&entries[i]); err != nil {
+				log.Fatal(err)
+			}
+		}
+		return
+	}
+
+	const entryFmt = "%-20s %-12s %-18s %-20s %-24s %-6s %s\n"
+	header := fmt.Sprintf(entryFmt, "Time", "Actor", "Operation", "Zone", "Name", "Result", "Change")
+	fmt.Print(header)
+	fmt.Println(repeatStr("=", len(header)))
+	for _, entry := range entries {
+		recordName := entry.Name
+		if recordName == "" && entry.Type != "" {
+			recordName = "@"
+		}
+		fmt.Printf(entryFmt,
+			entry.Time.Local().Format("2006-01-02 15:04:05"), entry.Actor, entry.Operation,
+			entry.ZoneName, recordName+" "+entry.Type, entry.Result, describeChange(&entry))
+	}
+}
+
+func describeChange(entry *audit.Entry) string {
+	describe := func(record *hetzner_dns.Record) string {
+		return fmt.Sprintf("%q ttl=%d", record.Value, record.TTL)
+	}
+	switch {
+	case entry.Error != "":
+		return entry.Error
+	case entry.Before != nil && entry.After != nil:
+		return describe(entry.Before) + " -> " + describe(entry.After)
+	case entry.After != nil:
+		return "+ " + describe(entry.After)
+	case entry.Before != nil:
+		return "- " + describe(entry.Before)
+	}
+	return ""
+}
+
+// parseTimeFlag parses an RFC 3339 time, or a duration relative to now
+// (e.g. "24h" for the last day). Empty values return the zero time.
+func parseTimeFlag(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if duration, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-duration), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// attachAudit makes client audit its changes to the log at path, if set.
+// The returned function closes the log.
+func attachAudit(client *hetzner_dns.Client, path string) func() {
+	if path == "" {
+		return func() {}
+	}
+	sink, err := audit.OpenFileSink(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	audit.NewAuditor(sink).Attach(client)
+	return func() {
+		_ = sink.Close()
+	}
+}
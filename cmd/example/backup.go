@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+	"github.com/panta/go-hetzner-dns/backup"
+)
+
+func cmdBackup(flagSet *flag.FlagSet, dir string) {
+	client := hetzner_dns.Client{}
+
+	manifest, err := backup.Backup(context.Background(), &client, dir)
+	if err != nil {
+		log.Printf("FAILED: %v", err)
+		os.Exit(1)
+	}
+	fmt.Println("OK.")
+	fmt.Printf("Snapshot %s: %d zones\n", manifest.ID, len(manifest.Zones))
+}
+
+func cmdRestore(flagSet *flag.FlagSet, dir string, snapshotId string, zone string, dryRun bool) {
+	client := hetzner_dns.Client{}
+
+	if snapshotId == "" {
+		manifests, err := backup.List(dir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("Available snapshots:")
+		for _, manifest := range manifests {
+			fmt.Printf("  %s (%d zones)\n", manifest.ID, len(manifest.Zones))
+		}
+		return
+	}
+
+	if snapshotId == "latest" {
+		snapshotId = ""
+	}
+	snapshot, err := backup.Open(dir, snapshotId)
+	if err != nil {
+		log.Fatal(err)
+	}
+	options := backup.RestoreOptions{DryRun: dryRun}
+	if zone != "" {
+		options.Zones = []string{zone}
+	}
+	restores, err := backup.Restore(context.Background(), &client, snapshot, options)
+	for _, restore := range restores {
+		fmt.Printf("\nZone %s", restore.Name)
+		if restore.Created {
+			fmt.Print(" (created)")
+		}
+		fmt.Println()
+		fmt.Print(restore.ChangeSet)
+	}
+	if err != nil {
+		log.Printf("FAILED: %v", err)
+		os.Exit(1)
+	}
+	if dryRun {
+		fmt.Println("Dry run, no changes applied.")
+	} else {
+		fmt.Println("OK.")
+	}
+}
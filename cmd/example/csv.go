@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+	"github.com/panta/go-hetzner-dns/csvfile"
+)
+
+// cmdRecordsExport writes the selected records as CSV or TSV.
+func cmdRecordsExport(args []string) {
+	flagSet := flag.NewFlagSet("records export", flag.ExitOnError)
+	buildQuery := queryFlags(flagSet)
+	output := flagSet.String("o", "", "output file (defaults to stdout)")
+	format := flagSet.String("format", "", "csv or tsv (guessed from the output file name, csv by default)")
+	columns := flagSet.String("columns", strings.Join(csvfile.DefaultColumns, ","),
+		"comma-separated list of columns ("+strings.Join(csvfile.AllColumns, ", ")+")")
+	concurrency := flagSet.Int("concurrency", hetzner_dns.DEFAULT_CONCURRENCY, "number of zones fetched at the same time")
+	_ = flagSet.Parse(args)
+	query := buildQuery()
+
+	comma, err := csvfile.Comma(fileFormat(*format, *output))
+	if err != nil {
+		log.Fatal(err)
+	}
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	client := hetzner_dns.Client{}
+	ctx, cancel := interruptContext()
+	defer cancel()
+	count, err := csvfile.Export(ctx, &client, w, csvfile.ExportOptions{
+		Comma:   comma,
+		Columns: splitList(*columns),
+		Query:   &query,
+		FanOut:  hetzner_dns.NewFanOut(*concurrency, 0),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *output != "" {
+		fmt.Printf("%d records exported to %s\n", count, *output)
+	}
+}
+
+// cmdRecordsImport validates a CSV or TSV file and upserts its records,
+// after showing the changes and asking for confirmation.
+func cmdRecordsImport(args []string) {
+	flagSet := flag.NewFlagSet("records import", flag.ExitOnError)
+	path := flagSet.String("file", "", "CSV or TSV file to import")
+	format := flagSet.String("format", "", "csv or tsv (guessed from the file name, csv by default)")
+	zone := flagSet.String("zone", "", "zone of the rows, for files without a zone column")
+	dryRun := flagSet.Bool("dry-run", false, "only show the changes")
+	autoApprove := flagSet.Bool("auto-approve", false, "apply without asking for confirmation")
+	_ = flagSet.Parse(args)
+	if *path == "" {
+		log.Fatal("missing -file")
+	}
+
+	comma, err := csvfile.Comma(fileFormat(*format, *path))
+	if err != nil {
+		log.Fatal(err)
+	}
+	file, err := os.Open(*path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	rows, err := csvfile.Read(file, csvfile.ReadOptions{Comma: comma, Zone: *zone})
+	file.Close()
+	if err != nil {
+		log.Fatalf("%s: %v", *path, err)
+	}
+
+	client := hetzner_dns.Client{}
+	ctx, cancel := interruptContext()
+	defer cancel()
+	report, err := csvfile.PlanImport(ctx, &client, rows)
+	if err != nil {
+		log.Fatalf("%s: %v", *path, err)
+	}
+	report.DryRun = *dryRun
+	fmt.Print(report.String())
+	if *dryRun || report.Count(csvfile.ACTION_CREATE)+report.Count(csvfile.ACTION_UPDATE) == 0 {
+		return
+	}
+
+	if !*autoApprove {
+		fmt.Print("\nDo you want to apply these changes? Only 'yes' will be accepted: ")
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if strings.TrimSpace(answer) != "yes" {
+			fmt.Println("Import cancelled.")
+			return
+		}
+	}
+	if err := csvfile.ApplyImport(ctx, &client, report); err != nil {
+		log.Printf("FAILED: %v", err)
+		os.Exit(1)
+	}
+	fmt.Println("OK.")
+}
+
+// fileFormat returns format, or the extension of path if empty.
+func fileFormat(format string, path string) string {
+	if format != "" {
+		return format
+	}
+	if strings.EqualFold(filepath.Ext(path), ".tsv") {
+		return "tsv"
+	}
+	return "csv"
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"strings"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+	"github.com/panta/go-hetzner-dns/backup"
+)
+
+// cmdDiff compares a zone between two snapshots, or between a snapshot and
+// the live zone (when to is "live").
+func cmdDiff(flagSet *flag.FlagSet, dir string, from string, to string, zoneName string, format string) {
+	if zoneName == "" {
+		log.Println("ERROR: missing -zone")
+		usage()
+		os.Exit(1)
+	}
+
+	fromRecords := snapshotRecords(dir, from, zoneName)
+	var toRecords []hetzner_dns.Record
+	if to == "live" {
+		toRecords = liveRecords(zoneName)
+	} else {
+		toRecords = snapshotRecords(dir, to, zoneName)
+	}
+
+	diff := hetzner_dns.DiffRecords(zoneName, fromRecords, toRecords)
+	diff.FromLabel = from
+	diff.ToLabel = to
+	if err := diff.Render(os.Stdout, hetzner_dns.DiffFormat(format)); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func snapshotRecords(dir string, snapshotId string, zoneName string) []hetzner_dns.Record {
+	if snapshotId == "latest" {
+		snapshotId = ""
+	}
+	snapshot, err := backup.Open(dir, snapshotId)
+	if err != nil {
+		log.Fatal(err)
+	}
+	zoneSnapshot, err := snapshot.Zone(zoneName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return zoneSnapshot.Records
+}
+
+func liveRecords(zoneName string) []hetzner_dns.Record {
+	client := hetzner_dns.Client{}
+
+	zones, err := client.GetAllZones(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, zone := range zones {
+		if strings.EqualFold(zone.Name, zoneName) {
+			records, err := client.GetAllRecords(context.Background(), zone.ID)
+			if err != nil {
+				log.Fatal(err)
+			}
+			return records
+		}
+	}
+	log.Fatalf("zone %s not found", zoneName)
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+	"github.com/panta/go-hetzner-dns/metrics"
+)
+
+// cmdExporter serves Prometheus metrics about the zones of the account and
+// the API calls made to collect them. Zones are cached for cacheTTL, to
+// limit API usage with frequent scrapes.
+func cmdExporter(flagSet *flag.FlagSet, listenAddr string, cacheTTL time.Duration) {
+	client := hetzner_dns.Client{}
+
+	clientMetrics := metrics.NewClientMetrics()
+	clientMetrics.InstrumentClient(&client)
+
+	cached := hetzner_dns.NewCachedClient(&client)
+	cached.ZonesTTL = cacheTTL
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(metrics.NewZoneCollector(cached), clientMetrics)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	server := &http.Server{Addr: listenAddr, Handler: mux}
+
+	ctx, cancel := interruptContext()
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		_ = server.Shutdown(context.Background())
+	}()
+
+	log.Printf("serving metrics on http://%s/metrics", listenAddr)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+	"github.com/panta/go-hetzner-dns/lint"
+)
+
+// cmdLint checks the zones for common mistakes, printing the findings as a
+// table or as a JSON report. It exits with status 1 if there are findings at
+// least as severe as failOn, for use in CI.
+func cmdLint(flagSet *flag.FlagSet, zones string, enable string, disable string, failOn string, asJSON bool, concurrency int) {
+	threshold, ok := lint.ParseSeverity(failOn)
+	if !ok {
+		log.Fatalf("invalid -fail-on %q (expected info, warning or error)", failOn)
+	}
+	linter := lint.NewLinter()
+	if unknown := linter.Select(splitList(enable), splitList(disable)); len(unknown) > 0 {
+		log.Fatalf("unknown rules: %s", strings.Join(unknown, ", "))
+	}
+
+	client := hetzner_dns.Client{}
+	ctx, cancel := interruptContext()
+	defer cancel()
+	allZones, err := client.GetAllZones(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// All the zones are linted, to resolve names across them, but only the
+	// findings of the selected ones are reported.
+	recordsByZone, err := hetzner_dns.NewFanOut(concurrency, 0).GetAllRecordsByZone(ctx, &client, allZones)
+	if err != nil {
+		log.Fatal(err)
+	}
+	lintZones := make([]lint.Zone, 0, len(allZones))
+	for _, zone := range allZones {
+		lintZones = append(lintZones, lint.Zone{Zone: zone, Records: recordsByZone[zone.ID]})
+	}
+	query := hetzner_dns.RecordQuery{Zones: splitList(zones)}
+	var findings []lint.Finding
+	for _, finding := range linter.Lint(lintZones) {
+		if query.MatchZone(&hetzner_dns.Zone{Name: finding.Zone}) {
+			findings = append(findings, finding)
+		}
+	}
+	report := lint.NewReport(findings)
+
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		const findingFmt = "%-8s %-20s %-24s %-18s %s\n"
+		header := fmt.Sprintf(findingFmt, "Severity", "Zone", "Name", "Rule", "Message")
+		fmt.Print(header)
+		fmt.Println(repeatStr("=", len(header)))
+		for _, finding := range report.Findings {
+			fmt.Printf(findingFmt, finding.Severity, finding.Zone, finding.Name+" "+finding.Type, finding.Rule, finding.Message)
+			if finding.Suggestion != "" {
+				fmt.Printf("%-8s %s\n", "", "fix: "+finding.Suggestion)
+			}
+		}
+		fmt.Printf("\n%d errors, %d warnings, %d infos.\n",
+			report.Summary[lint.SEVERITY_ERROR], report.Summary[lint.SEVERITY_WARNING], report.Summary[lint.SEVERITY_INFO])
+	}
+
+	if report.Fails(threshold) {
+		os.Exit(1)
+	}
+}
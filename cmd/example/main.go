@@ -8,27 +8,128 @@ import (
 	"os"
 
 	hetzner_dns "github.com/panta/go-hetzner-dns"
+	"github.com/panta/go-hetzner-dns/mirror"
 )
 
 func usage() {
 	fmt.Printf("usage: %s SUBCOMMAND [FLAGS] [ARGS]...\n", os.Args[0])
 	fmt.Println("SUBCOMMANDS:")
-	fmt.Println("  list")
-	fmt.Println("  add-record -zone ZONE-ID NAME TYPE VALUE")
-	fmt.Println("  update-record -zone ZONE-ID NAME TYPE VALUE")
+	fmt.Println("  list [-concurrency N] [-rate CALLS-PER-SECOND]")
+	fmt.Println("  add-record -zone ZONE-ID [-dry-run] [-audit-log FILE] NAME TYPE VALUE")
+	fmt.Println("  update-record -zone ZONE-ID [-dry-run] [-audit-log FILE] NAME TYPE VALUE")
+	fmt.Println("  backup [-dir DIR]")
+	fmt.Println("  restore [-dir DIR] [-snapshot ID] [-zone NAME] [-dry-run]")
+	fmt.Println("  diff [-dir DIR] -zone NAME [-from ID] [-to ID|live] [-format unified|color|json|markdown]")
+	fmt.Println("  plan -config FILE [-format unified|color|json|markdown]")
+	fmt.Println("  apply -config FILE [-lock FILE] [-auto-approve]")
+	fmt.Println("  watch [-interval DURATION] [-state FILE]")
+	fmt.Println("  mirror [-listen ADDR] [-interval DURATION] [-notify ADDR,...] [-allow-transfer CIDR,...]")
+	fmt.Println("  exporter [-listen ADDR] [-cache-ttl DURATION]")
+	fmt.Println("  records search [-zone GLOB,...] [-name GLOB] [-name-regex RE] [-type TYPE,...] [-value SUBSTRING] [-value-regex RE]")
+	fmt.Println("                 [-min-ttl N] [-max-ttl N] [-created-after|-created-before|-modified-after|-modified-before TIME] [-json]")
+	fmt.Println("  records replace SELECTION (-old VALUE|-regex RE) -new VALUE [-rollback FILE] [-dry-run] [-auto-approve]")
+	fmt.Println("  records rollback -file FILE")
+	fmt.Println("  records export SELECTION [-o FILE] [-format csv|tsv] [-columns COLUMN,...]")
+	fmt.Println("  records import -file FILE [-format csv|tsv] [-zone ZONE] [-dry-run] [-auto-approve]")
+	fmt.Println("  lint [-zone GLOB,...] [-rules RULE,...] [-disable RULE,...] [-fail-on SEVERITY] [-json]")
+	fmt.Println("  provision -zone ZONE -preset PRESET,... [-var NAME=VALUE ...] [-presets-dir DIR] [-ttl N] [-dry-run]")
+	fmt.Println("  provision -list [-presets-dir DIR]")
+	fmt.Println("  migrate -zone ZONE (-from-token-env VAR|-from-zonefile FILE|-from-file FILE.json|.csv) [-to ZONE] [-ttl N] [-dry-run] [-report FILE]")
+	fmt.Println("  audit -log FILE [-zone ZONE] [-name NAME] [-since TIME|DURATION] [-until TIME|DURATION] [-json]")
 }
 
 func main() {
 	listCmd := flag.NewFlagSet("list", flag.ExitOnError)
+	listConcurrency := listCmd.Int("concurrency", hetzner_dns.DEFAULT_CONCURRENCY, "number of zones fetched at the same time")
+	listRate := listCmd.Float64("rate", 0, "maximum API calls per second (0 for unlimited)")
 
 	addRecordCmd := flag.NewFlagSet("add-record", flag.ExitOnError)
 	addRecordZone := addRecordCmd.String("zone", "", "zone id")
+	addRecordDryRun := addRecordCmd.Bool("dry-run", false, "show the requests without sending them")
+	addRecordAuditLog := addRecordCmd.String("audit-log", "", "append the changes to this audit log")
 	// addRecordName := addRecordCmd.String("name", "", "record name")
 	// addRecordType := addRecordCmd.String("type", "", "record type")
 	// addRecordValue := addRecordCmd.String("value", "", "record value")
 
 	updateRecordCmd := flag.NewFlagSet("update-record", flag.ExitOnError)
 	updateRecordZone := updateRecordCmd.String("zone", "", "zone id")
+	updateRecordDryRun := updateRecordCmd.Bool("dry-run", false, "show the requests without sending them")
+	updateRecordAuditLog := updateRecordCmd.String("audit-log", "", "append the changes to this audit log")
+
+	backupCmd := flag.NewFlagSet("backup", flag.ExitOnError)
+	backupDir := backupCmd.String("dir", "backups", "backup directory")
+
+	restoreCmd := flag.NewFlagSet("restore", flag.ExitOnError)
+	restoreDir := restoreCmd.String("dir", "backups", "backup directory")
+	restoreSnapshot := restoreCmd.String("snapshot", "", "snapshot id or \"latest\" (empty lists available snapshots)")
+	restoreZone := restoreCmd.String("zone", "", "restore only this zone (name)")
+	restoreDryRun := restoreCmd.Bool("dry-run", false, "show changes without applying them")
+
+	diffCmd := flag.NewFlagSet("diff", flag.ExitOnError)
+	diffDir := diffCmd.String("dir", "backups", "backup directory")
+	diffFrom := diffCmd.String("from", "latest", "snapshot id to compare from")
+	diffTo := diffCmd.String("to", "live", "snapshot id to compare to, or \"live\"")
+	diffZone := diffCmd.String("zone", "", "zone name")
+	diffFormat := diffCmd.String("format", "color", "output format (unified, color, json, markdown)")
+
+	planCmd := flag.NewFlagSet("plan", flag.ExitOnError)
+	planConfig := planCmd.String("config", "dns.yaml", "zones configuration file (YAML or JSON)")
+	planFormat := planCmd.String("format", "color", "output format (unified, color, json, markdown)")
+
+	applyCmd := flag.NewFlagSet("apply", flag.ExitOnError)
+	applyConfig := applyCmd.String("config", "dns.yaml", "zones configuration file (YAML or JSON)")
+	applyLock := applyCmd.String("lock", "", "lock file (defaults to the config file name plus \".lock\")")
+	applyAutoApprove := applyCmd.Bool("auto-approve", false, "apply without asking for confirmation")
+
+	watchCmd := flag.NewFlagSet("watch", flag.ExitOnError)
+	watchInterval := watchCmd.Duration("interval", hetzner_dns.DEFAULT_WATCH_INTERVAL, "interval between polls")
+	watchState := watchCmd.String("state", "", "file to save the watcher state to, to resume after restarts")
+
+	mirrorCmd := flag.NewFlagSet("mirror", flag.ExitOnError)
+	mirrorListen := mirrorCmd.String("listen", "127.0.0.1:5353", "address to serve DNS on")
+	mirrorInterval := mirrorCmd.Duration("interval", mirror.DEFAULT_INTERVAL, "interval between syncs")
+	mirrorNotify := mirrorCmd.String("notify", "", "comma-separated list of secondaries to NOTIFY (host:port)")
+	mirrorAllowTransfer := mirrorCmd.String("allow-transfer", "", "comma-separated list of addresses or networks allowed to transfer zones")
+
+	exporterCmd := flag.NewFlagSet("exporter", flag.ExitOnError)
+	exporterListen := exporterCmd.String("listen", "127.0.0.1:9430", "address to serve metrics on")
+	exporterCacheTTL := exporterCmd.Duration("cache-ttl", hetzner_dns.DEFAULT_CACHE_TTL, "how long zones are cached between scrapes")
+
+	lintCmd := flag.NewFlagSet("lint", flag.ExitOnError)
+	lintZone := lintCmd.String("zone", "", "comma-separated list of zone name globs to report on")
+	lintRules := lintCmd.String("rules", "", "comma-separated list of rules to run (all if empty)")
+	lintDisable := lintCmd.String("disable", "", "comma-separated list of rules to skip")
+	lintFailOn := lintCmd.String("fail-on", "error", "exit with status 1 on findings of this severity or worse (info, warning, error)")
+	lintJSON := lintCmd.Bool("json", false, "print the report as JSON")
+	lintConcurrency := lintCmd.Int("concurrency", hetzner_dns.DEFAULT_CONCURRENCY, "number of zones fetched at the same time")
+
+	provisionCmd := flag.NewFlagSet("provision", flag.ExitOnError)
+	provisionZone := provisionCmd.String("zone", "", "zone name, created if it doesn't exist")
+	provisionPresets := provisionCmd.String("preset", "", "comma-separated list of presets to apply")
+	provisionPresetsDir := provisionCmd.String("presets-dir", "", "directory with user-defined presets (YAML or JSON)")
+	provisionVars := variablesFlag{}
+	provisionCmd.Var(provisionVars, "var", "preset variable as NAME=VALUE (can be repeated)")
+	provisionTTL := provisionCmd.Int("ttl", 86400, "default TTL of the zone, when created")
+	provisionDryRun := provisionCmd.Bool("dry-run", false, "show the requests without sending them")
+	provisionList := provisionCmd.Bool("list", false, "list the available presets and their variables")
+
+	migrateCmd := flag.NewFlagSet("migrate", flag.ExitOnError)
+	migrateZone := migrateCmd.String("zone", "", "name of the zone to migrate")
+	migrateTo := migrateCmd.String("to", "", "name of the destination zone (defaults to -zone)")
+	migrateFromTokenEnv := migrateCmd.String("from-token-env", "", "environment variable with the API token of the source Hetzner account")
+	migrateFromZoneFile := migrateCmd.String("from-zonefile", "", "BIND zone file to migrate from")
+	migrateFromFile := migrateCmd.String("from-file", "", "JSON or CSV export to migrate from")
+	migrateTTL := migrateCmd.Int("ttl", 0, "default TTL of the destination zone, when created (defaults to the source one)")
+	migrateDryRun := migrateCmd.Bool("dry-run", false, "only report the changes")
+	migrateReport := migrateCmd.String("report", "", "file to save the JSON migration report to")
+
+	auditCmd := flag.NewFlagSet("audit", flag.ExitOnError)
+	auditLog := auditCmd.String("log", "audit.jsonl", "audit log file")
+	auditZone := auditCmd.String("zone", "", "only changes to this zone (name or id)")
+	auditName := auditCmd.String("name", "", "only changes to records with this name")
+	auditSince := auditCmd.String("since", "", "only changes after this time (RFC 3339, or duration before now)")
+	auditUntil := auditCmd.String("until", "", "only changes before this time (RFC 3339, or duration before now)")
+	auditJSON := auditCmd.Bool("json", false, "print entries as JSON lines")
 
 	if len(os.Args) < 2 {
 		fmt.Println("ERROR: expected a subcommand")
@@ -39,19 +140,70 @@ func main() {
 	switch os.Args[1] {
 	case "list":
 		_ = listCmd.Parse(os.Args[2:])
-		cmdList(listCmd)
+		cmdList(listCmd, *listConcurrency, *listRate)
 
 	case "add":
 		fallthrough
 	case "add-record":
 		_ = addRecordCmd.Parse(os.Args[2:])
-		cmdAddRecord(addRecordCmd, *addRecordZone)
+		cmdAddRecord(addRecordCmd, *addRecordZone, *addRecordDryRun, *addRecordAuditLog)
 
 	case "update":
 		fallthrough
 	case "update-record":
 		_ = updateRecordCmd.Parse(os.Args[2:])
-		cmdUpdateRecord(updateRecordCmd, *updateRecordZone)
+		cmdUpdateRecord(updateRecordCmd, *updateRecordZone, *updateRecordDryRun, *updateRecordAuditLog)
+
+	case "backup":
+		_ = backupCmd.Parse(os.Args[2:])
+		cmdBackup(backupCmd, *backupDir)
+
+	case "restore":
+		_ = restoreCmd.Parse(os.Args[2:])
+		cmdRestore(restoreCmd, *restoreDir, *restoreSnapshot, *restoreZone, *restoreDryRun)
+
+	case "diff":
+		_ = diffCmd.Parse(os.Args[2:])
+		cmdDiff(diffCmd, *diffDir, *diffFrom, *diffTo, *diffZone, *diffFormat)
+
+	case "plan":
+		_ = planCmd.Parse(os.Args[2:])
+		cmdPlan(planCmd, *planConfig, *planFormat)
+
+	case "apply":
+		_ = applyCmd.Parse(os.Args[2:])
+		cmdApply(applyCmd, *applyConfig, *applyLock, *applyAutoApprove)
+
+	case "watch":
+		_ = watchCmd.Parse(os.Args[2:])
+		cmdWatch(watchCmd, *watchInterval, *watchState)
+
+	case "mirror":
+		_ = mirrorCmd.Parse(os.Args[2:])
+		cmdMirror(mirrorCmd, *mirrorListen, *mirrorInterval, *mirrorNotify, *mirrorAllowTransfer)
+
+	case "exporter":
+		_ = exporterCmd.Parse(os.Args[2:])
+		cmdExporter(exporterCmd, *exporterListen, *exporterCacheTTL)
+
+	case "records":
+		cmdRecords(os.Args[2:])
+
+	case "lint":
+		_ = lintCmd.Parse(os.Args[2:])
+		cmdLint(lintCmd, *lintZone, *lintRules, *lintDisable, *lintFailOn, *lintJSON, *lintConcurrency)
+
+	case "provision":
+		_ = provisionCmd.Parse(os.Args[2:])
+		cmdProvision(provisionCmd, *provisionZone, *provisionPresets, *provisionPresetsDir, provisionVars, *provisionTTL, *provisionDryRun, *provisionList)
+
+	case "migrate":
+		_ = migrateCmd.Parse(os.Args[2:])
+		cmdMigrate(migrateCmd, *migrateZone, *migrateTo, *migrateFromTokenEnv, *migrateFromZoneFile, *migrateFromFile, *migrateTTL, *migrateDryRun, *migrateReport)
+
+	case "audit":
+		_ = auditCmd.Parse(os.Args[2:])
+		cmdAudit(auditCmd, *auditLog, *auditZone, *auditName, *auditSince, *auditUntil, *auditJSON)
 
 	default:
 		fmt.Println("ERROR: expected a subcommand")
@@ -60,11 +212,12 @@ func main() {
 	}
 }
 
-func cmdList(flagSet *flag.FlagSet) {
+func cmdList(flagSet *flag.FlagSet, concurrency int, callsPerSecond float64) {
 	client := hetzner_dns.Client{}
+	ctx, cancel := interruptContext()
+	defer cancel()
 
-	zonesResponse, err := client.GetZones(context.Background(),
-		"", "", 1, 100)
+	zones, err := client.GetAllZones(ctx)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -73,35 +226,54 @@ func cmdList(flagSet *flag.FlagSet) {
 	header := fmt.Sprintf(zoneFmt, "ID", "Name", "Status", "Project", "# Records")
 	fmt.Print(header)
 	fmt.Println(repeatStr("=", len(header)))
-	for _, zone := range zonesResponse.Zones {
+	for _, zone := range zones {
 		fmt.Printf(zoneFmt,
 			zone.ID, zone.Name, zone.Status,
 			zone.Project, zone.RecordsCount)
 	}
 
-	for _, zone := range zonesResponse.Zones {
-		fmt.Printf("\nRecords for zone %s (ID:%v)\n", zone.Name, zone.ID)
+	fanOut := hetzner_dns.NewFanOut(concurrency, callsPerSecond)
+	fanOut.Progress = func(done int, total int, zone hetzner_dns.Zone, err error) {
+		fmt.Fprintf(os.Stderr, "\rfetched records of %d/%d zones", done, total)
+		if done == total {
+			fmt.Fprintln(os.Stderr)
+		}
+	}
+	recordsByZone, err := fanOut.GetAllRecordsByZone(ctx, &client, zones)
+	if err != nil {
+		log.Println(err)
+	}
 
-		recordsResponse, err := client.GetRecords(context.Background(), zone.ID, 0, 0)
-		if err != nil {
-			log.Fatal(err)
+	for _, zone := range zones {
+		records, ok := recordsByZone[zone.ID]
+		if !ok {
+			continue
 		}
+		fmt.Printf("\nRecords for zone %s (ID:%v)\n", zone.Name, zone.ID)
 
 		const recordFmt = "%32s %20s %10s %32s %10v %32s\n"
 		header := fmt.Sprintf(recordFmt, "ID", "Name", "Type", "Value", "TTL", "Created")
 		fmt.Print(header)
 		fmt.Println(repeatStr("=", len(header)))
-		for _, record := range recordsResponse.Records {
+		for _, record := range records {
 			fmt.Printf(recordFmt,
 				record.ID,
 				record.Name, record.Type, record.Value,
 				record.TTL, record.Created.String())
 		}
 	}
+	if err != nil {
+		os.Exit(1)
+	}
 }
 
-func cmdAddRecord(flagSet *flag.FlagSet, zoneId string) {
+func cmdAddRecord(flagSet *flag.FlagSet, zoneId string, dryRun bool, auditLog string) {
 	client := hetzner_dns.Client{}
+	if dryRun {
+		client.DryRun = hetzner_dns.NewDryRun()
+		defer printDryRun(client.DryRun)
+	}
+	defer attachAudit(&client, auditLog)()
 
 	args := flagSet.Args()
 	if len(args) < 3 {
@@ -128,8 +300,13 @@ func cmdAddRecord(flagSet *flag.FlagSet, zoneId string) {
 	fmt.Printf("Created: %v\n", recordResponse.Record)
 }
 
-func cmdUpdateRecord(flagSet *flag.FlagSet, zoneId string) {
+func cmdUpdateRecord(flagSet *flag.FlagSet, zoneId string, dryRun bool, auditLog string) {
 	client := hetzner_dns.Client{}
+	if dryRun {
+		client.DryRun = hetzner_dns.NewDryRun()
+		defer printDryRun(client.DryRun)
+	}
+	defer attachAudit(&client, auditLog)()
 
 	args := flagSet.Args()
 	if len(args) < 3 {
@@ -156,6 +333,12 @@ func cmdUpdateRecord(flagSet *flag.FlagSet, zoneId string) {
 	fmt.Printf("Created/Updated: %v\n", recordResponse.Record)
 }
 
+// printDryRun prints the requests that would have been sent.
+func printDryRun(dryRun *hetzner_dns.DryRun) {
+	fmt.Println("DRY RUN, requests not sent:")
+	fmt.Print(dryRun.String())
+}
+
 func repeatStr(str string, count int) string {
 	dst := ""
 	for i := 0; i < count; i++ {
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+	"github.com/panta/go-hetzner-dns/migrate"
+)
+
+// cmdMigrate copies a zone from another Hetzner account (fromTokenEnv names
+// the environment variable holding its token), a zone file or a JSON/CSV
+// export, printing the migration report and saving it as JSON to
+// reportPath, if set.
+func cmdMigrate(flagSet *flag.FlagSet, zoneName string, to string, fromTokenEnv string, fromZoneFile string, fromFile string, ttl int, dryRun bool, reportPath string) {
+	var source migrate.Source
+	sources := 0
+	if fromTokenEnv != "" {
+		token := os.Getenv(fromTokenEnv)
+		if token == "" {
+			log.Fatalf("environment variable %s is not set", fromTokenEnv)
+		}
+		source = &migrate.HetznerSource{Client: &hetzner_dns.Client{ApiKey: token}}
+		sources++
+	}
+	if fromZoneFile != "" {
+		source = &migrate.ZoneFileSource{Path: fromZoneFile}
+		sources++
+	}
+	if fromFile != "" {
+		source = &migrate.FileSource{Path: fromFile}
+		sources++
+	}
+	if zoneName == "" || sources != 1 {
+		log.Println("ERROR: 'migrate' needs -zone and exactly one of -from-token-env, -from-zonefile and -from-file")
+		usage()
+		os.Exit(1)
+	}
+
+	client := hetzner_dns.Client{}
+	ctx, cancel := interruptContext()
+	defer cancel()
+	report, err := migrate.Migrate(ctx, &client, source, zoneName, migrate.Options{Zone: to, TTL: ttl, DryRun: dryRun})
+	if report != nil {
+		fmt.Print(report.String())
+		if reportPath != "" {
+			data, _ := json.MarshalIndent(report, "", "  ")
+			if err := ioutil.WriteFile(reportPath, append(data, '\n'), 0o600); err != nil {
+				log.Printf("can't write report: %v", err)
+			}
+		}
+	}
+	if err != nil {
+		log.Printf("FAILED: %v", err)
+		os.Exit(1)
+	}
+}
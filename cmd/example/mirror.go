@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+	"github.com/panta/go-hetzner-dns/mirror"
+)
+
+func cmdMirror(flagSet *flag.FlagSet, listenAddr string, interval time.Duration, notify string, allowTransfer string) {
+	client := hetzner_dns.Client{}
+
+	m := mirror.NewMirror(&client)
+	m.Interval = interval
+	m.Notify = splitList(notify)
+	m.AllowTransfer = splitList(allowTransfer)
+
+	ctx, cancel := interruptContext()
+	defer cancel()
+
+	if err := m.Sync(ctx); err != nil {
+		log.Fatal(err)
+	}
+	go func() {
+		_ = m.Run(ctx)
+	}()
+
+	log.Printf("serving %d zones on %s", len(m.Store.Zones()), listenAddr)
+	if err := m.ListenAndServe(ctx, listenAddr); err != nil && err != context.Canceled {
+		log.Fatal(err)
+	}
+}
+
+// interruptContext returns a context cancelled on SIGINT.
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	go func() {
+		select {
+		case <-signals:
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(signals)
+	}()
+	return ctx, cancel
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
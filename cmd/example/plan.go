@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+	"github.com/panta/go-hetzner-dns/config"
+)
+
+// cmdPlan shows the changes needed to reconcile the zones with the config
+// file. It exits with status 2 when there are changes.
+func cmdPlan(flagSet *flag.FlagSet, configPath string, format string) {
+	client := hetzner_dns.Client{}
+
+	plan, err := buildPlan(&client, configPath, format)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if plan.HasChanges() {
+		os.Exit(2)
+	}
+}
+
+// cmdApply applies the changes needed to reconcile the zones with the config
+// file, asking for confirmation unless autoApprove is set.
+func cmdApply(flagSet *flag.FlagSet, configPath string, lockPath string, autoApprove bool) {
+	client := hetzner_dns.Client{}
+
+	if lockPath == "" {
+		lockPath = configPath + ".lock"
+	}
+	lock, err := config.AcquireLock(lockPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() {
+		_ = lock.Release()
+	}()
+
+	plan, err := buildPlan(&client, configPath, string(hetzner_dns.DIFF_FORMAT_COLOR))
+	if err != nil {
+		log.Printf("FAILED: %v", err)
+		_ = lock.Release()
+		os.Exit(1)
+	}
+	if !plan.HasChanges() {
+		fmt.Println("No changes.")
+		return
+	}
+
+	if !autoApprove {
+		fmt.Print("\nDo you want to apply these changes? Only 'yes' will be accepted: ")
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if strings.TrimSpace(answer) != "yes" {
+			fmt.Println("Apply cancelled.")
+			return
+		}
+	}
+
+	if err := config.Apply(context.Background(), &client, plan); err != nil {
+		log.Printf("FAILED: %v", err)
+		_ = lock.Release()
+		os.Exit(1)
+	}
+	fmt.Println("OK.")
+}
+
+// buildPlan loads the config file and prints the plan to reconcile the zones
+// with it. Errors are returned, not fatal, so that callers holding the lock
+// can release it before exiting.
+func buildPlan(client *hetzner_dns.Client, configPath string, format string) (*config.Plan, error) {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := config.BuildPlan(context.Background(), client, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, zonePlan := range plan.Zones {
+		if zonePlan.CreateZone {
+			fmt.Printf("Zone %s will be created\n", zonePlan.Config.Name)
+		}
+		if zonePlan.Diff.Empty() {
+			continue
+		}
+		if err := zonePlan.Diff.Render(os.Stdout, hetzner_dns.DiffFormat(format)); err != nil {
+			return nil, err
+		}
+		fmt.Println(zonePlan.Diff.Summary())
+	}
+	return plan, nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+	"github.com/panta/go-hetzner-dns/preset"
+)
+
+// variablesFlag collects repeated -var NAME=VALUE flags.
+type variablesFlag map[string]string
+
+func (variables variablesFlag) String() string {
+	var pairs []string
+	for name, value := range variables {
+		pairs = append(pairs, name+"="+value)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (variables variablesFlag) Set(value string) error {
+	i := strings.Index(value, "=")
+	if i <= 0 {
+		return fmt.Errorf("expected NAME=VALUE, got %q", value)
+	}
+	variables[value[:i]] = value[i+1:]
+	return nil
+}
+
+// cmdProvision renders presets for a zone and creates their records,
+// creating the zone if needed. With list set, the available presets and
+// their variables are printed instead.
+func cmdProvision(flagSet *flag.FlagSet, zoneName string, presets string, presetsDir string, variables variablesFlag, ttl int, dryRun bool, list bool) {
+	library := preset.Builtin()
+	if presetsDir != "" {
+		if err := library.LoadDir(presetsDir); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if list {
+		for _, name := range library.Names() {
+			fmt.Printf("%s: %s\n", name, library[name].Description)
+			for _, variable := range library[name].Variables {
+				required := ""
+				if variable.Required {
+					required = " (required)"
+				} else if variable.Default != "" {
+					required = fmt.Sprintf(" (default %q)", variable.Default)
+				}
+				fmt.Printf("    %-18s %s%s\n", variable.Name, variable.Description, required)
+			}
+		}
+		return
+	}
+
+	if zoneName == "" || presets == "" {
+		log.Println("ERROR: 'provision' needs -zone and -preset")
+		usage()
+		os.Exit(1)
+	}
+	records, err := library.Render(splitList(presets), zoneName, variables, 0)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	client := hetzner_dns.Client{}
+	if dryRun {
+		client.DryRun = hetzner_dns.NewDryRun()
+		defer printDryRun(client.DryRun)
+	}
+	ctx, cancel := interruptContext()
+	defer cancel()
+	result, err := preset.Provision(ctx, &client, zoneName, ttl, records)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if result.ZoneCreated {
+		fmt.Printf("created zone %s (%s)\n", zoneName, result.ZoneID)
+	}
+	for _, record := range result.Created {
+		fmt.Printf("+ %s %s %s\n", record.Name, record.Type, record.Value)
+	}
+	for _, record := range result.Skipped {
+		fmt.Printf("= %s %s %s (exists)\n", record.Name, record.Type, record.Value)
+	}
+	fmt.Printf("%d records created, %d already existing.\n", len(result.Created), len(result.Skipped))
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+)
+
+// cmdRecords runs the "records" subcommands.
+func cmdRecords(args []string) {
+	if len(args) < 1 {
+		fmt.Println("ERROR: expected a records subcommand")
+		usage()
+		os.Exit(1)
+	}
+	switch args[0] {
+	case "search":
+		cmdRecordsSearch(args[1:])
+	case "replace":
+		cmdRecordsReplace(args[1:])
+	case "rollback":
+		cmdRecordsRollback(args[1:])
+	case "export":
+		cmdRecordsExport(args[1:])
+	case "import":
+		cmdRecordsImport(args[1:])
+	default:
+		fmt.Printf("ERROR: unknown records subcommand %q\n", args[0])
+		usage()
+		os.Exit(1)
+	}
+}
+
+func cmdRecordsSearch(args []string) {
+	flagSet := flag.NewFlagSet("records search", flag.ExitOnError)
+	buildQuery := queryFlags(flagSet)
+	concurrency := flagSet.Int("concurrency", hetzner_dns.DEFAULT_CONCURRENCY, "number of zones fetched at the same time")
+	asJSON := flagSet.Bool("json", false, "print matches as JSON lines")
+	_ = flagSet.Parse(args)
+	query := buildQuery()
+
+	client := hetzner_dns.Client{}
+	ctx, cancel := interruptContext()
+	defer cancel()
+	matches, err := client.SearchRecords(ctx, &query, hetzner_dns.NewFanOut(*concurrency, 0))
+	if err != nil {
+		log.Println(err)
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		for i := range matches {
+			if err := encoder.Encode(&matches[i]); err != nil {
+				log.Fatal(err)
+			}
+		}
+	} else {
+		const matchFmt = "%-24s %-24s %-8s %-8s %s\n"
+		header := fmt.Sprintf(matchFmt, "Zone", "Name", "Type", "TTL", "Value")
+		fmt.Print(header)
+		fmt.Println(repeatStr("=", len(header)))
+		for _, match := range matches {
+			ttl := ""
+			if match.TTL > 0 {
+				ttl = fmt.Sprint(match.TTL)
+			}
+			fmt.Printf(matchFmt, match.ZoneName, match.Name, match.Type, ttl, match.Value)
+		}
+	}
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// cmdRecordsReplace previews the replacement of the values of the selected
+// records and applies it after confirmation, writing a rollback file first.
+func cmdRecordsReplace(args []string) {
+	flagSet := flag.NewFlagSet("records replace", flag.ExitOnError)
+	buildQuery := queryFlags(flagSet)
+	oldValue := flagSet.String("old", "", "value to replace (exact match)")
+	pattern := flagSet.String("regex", "", "regular expression matching the parts of values to replace")
+	template := flagSet.String("new", "", "new value, or replacement template with -regex (e.g. \"$1.example.net.\")")
+	rollbackPath := flagSet.String("rollback", "", "rollback file to write before applying (defaults to rollback-TIMESTAMP.json)")
+	autoApprove := flagSet.Bool("auto-approve", false, "apply without asking for confirmation")
+	dryRun := flagSet.Bool("dry-run", false, "only show the affected records")
+	_ = flagSet.Parse(args)
+
+	replacement := hetzner_dns.Replacement{Query: buildQuery(), OldValue: *oldValue, Template: *template}
+	if *pattern != "" {
+		var err error
+		if replacement.Pattern, err = regexp.Compile(*pattern); err != nil {
+			log.Fatalf("invalid -regex: %v", err)
+		}
+	}
+
+	client := hetzner_dns.Client{}
+	ctx, cancel := interruptContext()
+	defer cancel()
+	plan, err := client.PlanReplaceValues(ctx, &replacement, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if plan.Empty() {
+		fmt.Println("No records affected.")
+		return
+	}
+	fmt.Print(plan.String())
+	fmt.Printf("%d records affected.\n", len(plan.Changes))
+	if *dryRun {
+		return
+	}
+
+	if !*autoApprove {
+		fmt.Print("\nDo you want to apply these changes? Only 'yes' will be accepted: ")
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if strings.TrimSpace(answer) != "yes" {
+			fmt.Println("Replace cancelled.")
+			return
+		}
+	}
+
+	if *rollbackPath == "" {
+		*rollbackPath = fmt.Sprintf("rollback-%s.json", time.Now().UTC().Format("20060102T150405Z"))
+	}
+	if err := client.ApplyReplaceValues(ctx, plan, *rollbackPath); err != nil {
+		log.Printf("FAILED: %v (restore with: records rollback -file %s)", err, *rollbackPath)
+		os.Exit(1)
+	}
+	fmt.Printf("OK. Rollback file: %s\n", *rollbackPath)
+}
+
+// cmdRecordsRollback restores the records saved by "records replace".
+func cmdRecordsRollback(args []string) {
+	flagSet := flag.NewFlagSet("records rollback", flag.ExitOnError)
+	rollbackPath := flagSet.String("file", "", "rollback file")
+	_ = flagSet.Parse(args)
+	if *rollbackPath == "" {
+		log.Fatal("missing -file")
+	}
+
+	client := hetzner_dns.Client{}
+	if err := client.RollbackReplaceValues(context.Background(), *rollbackPath); err != nil {
+		log.Printf("FAILED: %v", err)
+		os.Exit(1)
+	}
+	fmt.Println("OK.")
+}
+
+// queryFlags defines the flags selecting records on flagSet, returning a
+// function building the query once the flags are parsed.
+func queryFlags(flagSet *flag.FlagSet) func() hetzner_dns.RecordQuery {
+	zones := flagSet.String("zone", "", "comma-separated list of zone name globs")
+	name := flagSet.String("name", "", "record name glob (\"@\" for the apex)")
+	nameRegexp := flagSet.String("name-regex", "", "record name regular expression")
+	types := flagSet.String("type", "", "comma-separated list of record types")
+	value := flagSet.String("value", "", "substring of the record value")
+	valueRegexp := flagSet.String("value-regex", "", "record value regular expression")
+	minTTL := flagSet.Int("min-ttl", 0, "minimum TTL")
+	maxTTL := flagSet.Int("max-ttl", 0, "maximum TTL")
+	createdAfter := flagSet.String("created-after", "", "created after this time (RFC 3339, or duration before now)")
+	createdBefore := flagSet.String("created-before", "", "created before this time (RFC 3339, or duration before now)")
+	modifiedAfter := flagSet.String("modified-after", "", "modified after this time (RFC 3339, or duration before now)")
+	modifiedBefore := flagSet.String("modified-before", "", "modified before this time (RFC 3339, or duration before now)")
+
+	return func() hetzner_dns.RecordQuery {
+		query := hetzner_dns.RecordQuery{
+			Zones:         splitList(*zones),
+			Name:          *name,
+			Types:         splitList(strings.ToUpper(*types)),
+			ValueContains: *value,
+			MinTTL:        *minTTL,
+			MaxTTL:        *maxTTL,
+		}
+		var err error
+		if *nameRegexp != "" {
+			if query.NameRegexp, err = regexp.Compile(*nameRegexp); err != nil {
+				log.Fatalf("invalid -name-regex: %v", err)
+			}
+		}
+		if *valueRegexp != "" {
+			if query.ValueRegexp, err = regexp.Compile(*valueRegexp); err != nil {
+				log.Fatalf("invalid -value-regex: %v", err)
+			}
+		}
+		for _, timeFlag := range []struct {
+			name  string
+			value string
+			dest  *time.Time
+		}{
+			{"created-after", *createdAfter, &query.CreatedAfter},
+			{"created-before", *createdBefore, &query.CreatedBefore},
+			{"modified-after", *modifiedAfter, &query.ModifiedAfter},
+			{"modified-before", *modifiedBefore, &query.ModifiedBefore},
+		} {
+			if *timeFlag.dest, err = parseTimeFlag(timeFlag.value); err != nil {
+				log.Fatalf("invalid -%s: %v", timeFlag.name, err)
+			}
+		}
+		return query
+	}
+}
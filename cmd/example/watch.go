@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+)
+
+// cmdWatch prints record changes as JSON lines, saving the watcher state to
+// statePath (if set) to resume after a restart.
+func cmdWatch(flagSet *flag.FlagSet, interval time.Duration, statePath string) {
+	client := hetzner_dns.Client{}
+
+	watcher := hetzner_dns.NewWatcher(&client)
+	watcher.Interval = interval
+	if statePath != "" {
+		if _, err := os.Stat(statePath); err == nil {
+			state, err := hetzner_dns.LoadWatcherState(statePath)
+			if err != nil {
+				log.Fatal(err)
+			}
+			watcher.State = state
+		}
+		watcher.OnPoll = func(state *hetzner_dns.WatcherState) {
+			if err := hetzner_dns.SaveWatcherState(statePath, state); err != nil {
+				log.Printf("can't save state: %v", err)
+			}
+		}
+	}
+
+	ctx, cancel := interruptContext()
+	defer cancel()
+
+	encoder := json.NewEncoder(os.Stdout)
+	for event := range watcher.Watch(ctx) {
+		if err := encoder.Encode(&event); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
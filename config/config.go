@@ -0,0 +1,240 @@
+// Package config loads declarative zone configurations (YAML or JSON) and
+// reconciles the zones of an Hetzner DNS account with them, through a
+// plan/apply workflow.
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+)
+
+const MAX_TXT_CHUNK = 255
+
+// Config is the declarative configuration of a set of zones.
+//
+// YAML anchors can be used to share records between zones: the top-level
+// "shared" key is ignored and can hold anchored records or lists of records,
+// and a list aliased inside "records" is flattened into it.
+type Config struct {
+	// DefaultTTL is the TTL of records not setting one explicitly (0 means
+	// the zone default).
+	DefaultTTL int          `yaml:"default_ttl"`
+	Shared     yaml.Node    `yaml:"shared"`
+	Zones      []ZoneConfig `yaml:"zones"`
+}
+
+// ZoneConfig is the desired state of a zone.
+type ZoneConfig struct {
+	Name string `yaml:"name"`
+	// TTL is the default TTL of the zone, used when creating it.
+	TTL int `yaml:"ttl"`
+	// DefaultTTL overrides Config.DefaultTTL for the records of this zone.
+	DefaultTTL int `yaml:"default_ttl"`
+	// ManageApexNS must be set to manage the NS records of the zone apex,
+	// which are otherwise left untouched.
+	ManageApexNS bool       `yaml:"manage_apex_ns"`
+	Records      RecordList `yaml:"records"`
+}
+
+// RecordConfig is a record of a zone. The value is given either as a raw
+// Value, or through one of the typed MX, SRV and TXT fields.
+type RecordConfig struct {
+	Name  string     `yaml:"name"`
+	Type  string     `yaml:"type"`
+	Value string     `yaml:"value"`
+	TTL   int        `yaml:"ttl"`
+	MX    *MXConfig  `yaml:"mx"`
+	SRV   *SRVConfig `yaml:"srv"`
+	// TXT is the text of a TXT record, split into quoted strings of at most
+	// MAX_TXT_CHUNK characters when needed.
+	TXT string `yaml:"txt"`
+}
+
+type MXConfig struct {
+	Priority int    `yaml:"priority"`
+	Host     string `yaml:"host"`
+}
+
+type SRVConfig struct {
+	Priority int    `yaml:"priority"`
+	Weight   int    `yaml:"weight"`
+	Port     int    `yaml:"port"`
+	Target   string `yaml:"target"`
+}
+
+// RecordList is a list of records, where nested lists (typically aliases of
+// shared anchored lists) are flattened.
+type RecordList []RecordConfig
+
+func (list *RecordList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if node.Kind != yaml.SequenceNode {
+		return errors.Errorf("line %d: records must be a list", node.Line)
+	}
+	for _, item := range node.Content {
+		resolved := item
+		if resolved.Kind == yaml.AliasNode {
+			resolved = resolved.Alias
+		}
+		if resolved.Kind == yaml.SequenceNode {
+			var nested RecordList
+			if err := nested.UnmarshalYAML(resolved); err != nil {
+				return err
+			}
+			*list = append(*list, nested...)
+			continue
+		}
+		record := RecordConfig{}
+		if err := item.Decode(&record); err != nil {
+			return err
+		}
+		*list = append(*list, record)
+	}
+	return nil
+}
+
+// Load reads a configuration file. JSON files are accepted as well, being a
+// subset of YAML.
+func Load(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't read config")
+	}
+	return Parse(data)
+}
+
+// Parse parses and validates a configuration.
+func Parse(data []byte) (*Config, error) {
+	config := &Config{}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, errors.Wrap(err, "can't parse config")
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Validate checks the configuration for errors.
+func (config *Config) Validate() error {
+	seen := map[string]bool{}
+	for _, zone := range config.Zones {
+		if zone.Name == "" {
+			return errors.New("config: zone without name")
+		}
+		name := hetzner_dns.NormalizeZoneName(zone.Name)
+		if seen[name] {
+			return errors.Errorf("config: zone %s defined more than once", zone.Name)
+		}
+		seen[name] = true
+		for i, record := range zone.Records {
+			if _, err := record.value(); err != nil {
+				return errors.Wrapf(err, "config: zone %s, record #%d (%s %s)", zone.Name, i+1, record.Name, record.Type)
+			}
+			if !zone.ManageApexNS && strings.EqualFold(record.Type, "NS") && (record.Name == "" || record.Name == "@") {
+				return errors.Errorf("config: zone %s, record #%d: apex NS records require manage_apex_ns", zone.Name, i+1)
+			}
+		}
+	}
+	return nil
+}
+
+// RecordRequests returns the records of the zone as record requests, with
+// default TTLs applied.
+func (config *Config) RecordRequests(zone ZoneConfig) ([]hetzner_dns.RecordRequest, error) {
+	defaultTTL := config.DefaultTTL
+	if zone.DefaultTTL > 0 {
+		defaultTTL = zone.DefaultTTL
+	}
+	requests := make([]hetzner_dns.RecordRequest, 0, len(zone.Records))
+	for _, record := range zone.Records {
+		value, err := record.value()
+		if err != nil {
+			return nil, err
+		}
+		name := record.Name
+		if name == "" {
+			name = "@"
+		}
+		ttl := record.TTL
+		if ttl == 0 {
+			ttl = defaultTTL
+		}
+		requests = append(requests, hetzner_dns.RecordRequest{
+			Type:  strings.ToUpper(record.Type),
+			Name:  name,
+			Value: value,
+			TTL:   ttl,
+		})
+	}
+	return requests, nil
+}
+
+// value returns the value of the record as expected by the API.
+func (record *RecordConfig) value() (string, error) {
+	if record.Type == "" {
+		return "", errors.New("missing type")
+	}
+	set := 0
+	for _, isSet := range []bool{record.Value != "", record.MX != nil, record.SRV != nil, record.TXT != ""} {
+		if isSet {
+			set++
+		}
+	}
+	if set != 1 {
+		return "", errors.New("exactly one of value, mx, srv and txt must be set")
+	}
+
+	recordType := strings.ToUpper(record.Type)
+	switch {
+	case record.MX != nil:
+		if recordType != "MX" {
+			return "", errors.New("mx can only be used with MX records")
+		}
+		if record.MX.Host == "" {
+			return "", errors.New("missing mx host")
+		}
+		return fmt.Sprintf("%d %s", record.MX.Priority, record.MX.Host), nil
+	case record.SRV != nil:
+		if recordType != "SRV" {
+			return "", errors.New("srv can only be used with SRV records")
+		}
+		if record.SRV.Target == "" {
+			return "", errors.New("missing srv target")
+		}
+		return fmt.Sprintf("%d %d %d %s", record.SRV.Priority, record.SRV.Weight, record.SRV.Port, record.SRV.Target), nil
+	case record.TXT != "":
+		if recordType != "TXT" {
+			return "", errors.New("txt can only be used with TXT records")
+		}
+		return txtValue(record.TXT), nil
+	default:
+		return record.Value, nil
+	}
+}
+
+// txtValue splits long texts into quoted strings of at most MAX_TXT_CHUNK
+// characters, as required by the DNS protocol.
+func txtValue(text string) string {
+	if len(text) <= MAX_TXT_CHUNK {
+		return text
+	}
+	var chunks []string
+	for len(text) > 0 {
+		n := MAX_TXT_CHUNK
+		if n > len(text) {
+			n = len(text)
+		}
+		chunks = append(chunks, fmt.Sprintf("%q", text[:n]))
+		text = text[n:]
+	}
+	return strings.Join(chunks, " ")
+}
@@ -0,0 +1,127 @@
+package config_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+	"github.com/panta/go-hetzner-dns/config"
+)
+
+const sampleConfig = `
+default_ttl: 3600
+shared:
+  mail: &mail
+    - {type: MX, mx: {priority: 10, host: mx1.example.net.}}
+    - {type: MX, mx: {priority: 20, host: mx2.example.net.}}
+  www: &www {name: www, type: CNAME, value: "@", ttl: 300}
+zones:
+  - name: example.com
+    records:
+      - {name: "@", type: A, value: 10.0.0.10}
+      - *mail
+      - *www
+      - name: _sip._tcp
+        type: SRV
+        srv: {priority: 10, weight: 5, port: 5060, target: sip.example.com.}
+  - name: example.org
+    ttl: 86400
+    records:
+      - *mail
+      - {type: TXT, txt: "v=spf1 mx -all"}
+`
+
+func TestParse(t *testing.T) {
+	cfg, err := config.Parse([]byte(sampleConfig))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(cfg.Zones) != 2 {
+		t.Fatalf("Wrong # of zones: %d", len(cfg.Zones))
+	}
+	requests, err := cfg.RecordRequests(cfg.Zones[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 5 {
+		t.Fatalf("Wrong # of records: %d", len(requests))
+	}
+	if requests[1].Value != "10 mx1.example.net." || requests[1].TTL != 3600 {
+		t.Errorf("Wrong MX record: %+v", requests[1])
+	}
+	if requests[3].TTL != 300 {
+		t.Errorf("Wrong TTL for shared record: %+v", requests[3])
+	}
+	if requests[4].Value != "10 5 5060 sip.example.com." {
+		t.Errorf("Wrong SRV record: %+v", requests[4])
+	}
+
+	if _, err := config.Parse([]byte(`zones: [{name: example.com, records: [{type: MX, value: "10 mx", mx: {host: mx}}]}]`)); err == nil {
+		t.Error("Expected error for record with both value and mx")
+	}
+	if _, err := config.Parse([]byte(`zones: [{name: example.com, records: [{name: "@", type: ns, value: ns1.example.net.}]}]`)); err == nil {
+		t.Error("Expected error for apex NS record without manage_apex_ns")
+	}
+	if _, err := config.Parse([]byte(`zones: [{name: example.com, manage_apex_ns: true, records: [{type: NS, value: ns1.example.net.}]}]`)); err != nil {
+		t.Errorf("Unexpected error for managed apex NS record: %v", err)
+	}
+}
+
+func TestBuildPlan(t *testing.T) {
+	hs := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/zones":
+			_, _ = io.WriteString(rw, `{
+  "zones": [{"id": "zone-1", "name": "example.com", "ttl": 3600}],
+  "meta": {"pagination": {"page": 1, "per_page": 100, "last_page": 1, "total_entries": 1}}
+}`)
+		case "/records":
+			_, _ = io.WriteString(rw, `{
+  "records": [
+    {"type": "NS", "id": "r0", "zone_id": "zone-1", "name": "@", "value": "ns1.example.net.", "ttl": 0},
+    {"type": "A", "id": "r1", "zone_id": "zone-1", "name": "@", "value": "10.0.0.10", "ttl": 3600},
+    {"type": "MX", "id": "r2", "zone_id": "zone-1", "name": "@", "value": "10 mx1.example.net.", "ttl": 3600},
+    {"type": "A", "id": "r3", "zone_id": "zone-1", "name": "old", "value": "10.0.0.1", "ttl": 3600}
+  ]
+}`)
+		default:
+			t.Errorf("Unexpected request: %s %s", req.Method, req.URL.Path)
+		}
+	}))
+	defer hs.Close()
+	client := &hetzner_dns.Client{BaseURL: hs.URL, ApiKey: "dummy"}
+
+	cfg, err := config.Parse([]byte(sampleConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := config.BuildPlan(context.Background(), client, cfg)
+	if err != nil {
+		t.Fatalf("BuildPlan failed: %v", err)
+	}
+	if !plan.HasChanges() {
+		t.Fatal("Expected changes")
+	}
+
+	existing := plan.Zones[0]
+	if existing.ZoneID != "zone-1" || existing.CreateZone {
+		t.Errorf("Wrong plan for existing zone: %+v", existing)
+	}
+	// mx2, www and SRV are created, "old" is deleted, the apex NS is left alone
+	if existing.ChangeSet.Count(hetzner_dns.CHANGE_CREATE) != 3 ||
+		existing.ChangeSet.Count(hetzner_dns.CHANGE_UPDATE) != 0 ||
+		existing.ChangeSet.Count(hetzner_dns.CHANGE_DELETE) != 1 {
+		t.Errorf("Wrong changes:\n%s", existing.ChangeSet)
+	}
+	if strings.Contains(existing.ChangeSet.String(), "NS") {
+		t.Errorf("Apex NS records should not be changed:\n%s", existing.ChangeSet)
+	}
+
+	if !plan.Zones[1].CreateZone {
+		t.Error("Expected example.org to be created")
+	}
+}
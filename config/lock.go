@@ -0,0 +1,45 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var ErrLocked = errors.New("config: another apply is in progress")
+
+// Lock is an exclusive lock preventing concurrent applies, held as long as
+// its lock file exists.
+type Lock struct {
+	path string
+}
+
+// AcquireLock creates the lock file at path, failing with ErrLocked if it
+// already exists. The error message reports the content of the existing lock
+// file (process, host and time of the holder).
+func AcquireLock(path string) (*Lock, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if os.IsExist(err) {
+		holder, _ := ioutil.ReadFile(path)
+		return nil, errors.Wrapf(ErrLocked, "lock file %s held by %s", path, holder)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "can't create lock file")
+	}
+	defer file.Close()
+
+	hostname, _ := os.Hostname()
+	if _, err := fmt.Fprintf(file, "pid %d on %s since %s", os.Getpid(), hostname, time.Now().Format(time.RFC3339)); err != nil {
+		_ = os.Remove(path)
+		return nil, errors.Wrap(err, "can't write lock file")
+	}
+	return &Lock{path: path}, nil
+}
+
+// Release removes the lock file.
+func (lock *Lock) Release() error {
+	return os.Remove(lock.path)
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+)
+
+// ZonePlan holds the changes needed to bring a zone to its configured state.
+type ZonePlan struct {
+	Config ZoneConfig
+	// ZoneID is empty if the zone doesn't exist yet.
+	ZoneID     string
+	CreateZone bool
+	ChangeSet  *hetzner_dns.ChangeSet
+	Diff       *hetzner_dns.ZoneDiff
+}
+
+// Plan holds the changes needed to reconcile all the configured zones.
+type Plan struct {
+	config *Config
+	Zones  []ZonePlan
+}
+
+// HasChanges returns true if applying the plan would change anything.
+func (plan *Plan) HasChanges() bool {
+	for _, zonePlan := range plan.Zones {
+		if zonePlan.CreateZone || !zonePlan.ChangeSet.Empty() {
+			return true
+		}
+	}
+	return false
+}
+
+// BuildPlan compares the configuration with the live zones.
+func BuildPlan(ctx context.Context, client *hetzner_dns.Client, config *Config) (*Plan, error) {
+	zones, err := client.GetAllZones(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't get zones")
+	}
+	liveZones := map[string]hetzner_dns.Zone{}
+	for _, zone := range zones {
+		liveZones[hetzner_dns.NormalizeZoneName(zone.Name)] = zone
+	}
+
+	plan := &Plan{config: config}
+	for _, zoneConfig := range config.Zones {
+		zonePlan := ZonePlan{Config: zoneConfig}
+		var current []hetzner_dns.Record
+		if zone, ok := liveZones[hetzner_dns.NormalizeZoneName(zoneConfig.Name)]; ok {
+			zonePlan.ZoneID = zone.ID
+			if current, err = client.GetAllRecords(ctx, zone.ID); err != nil {
+				return nil, errors.Wrapf(err, "can't get records for zone %s", zoneConfig.Name)
+			}
+		} else {
+			zonePlan.CreateZone = true
+		}
+		if err := plan.planZone(&zonePlan, current); err != nil {
+			return nil, err
+		}
+		plan.Zones = append(plan.Zones, zonePlan)
+	}
+	return plan, nil
+}
+
+func (plan *Plan) planZone(zonePlan *ZonePlan, current []hetzner_dns.Record) error {
+	desired, err := plan.config.RecordRequests(zonePlan.Config)
+	if err != nil {
+		return errors.Wrapf(err, "zone %s", zonePlan.Config.Name)
+	}
+	if !zonePlan.Config.ManageApexNS {
+		current = withoutApexNS(current)
+	}
+
+	zonePlan.ChangeSet = hetzner_dns.PlanChanges(zonePlan.ZoneID, current, desired)
+	desiredRecords := make([]hetzner_dns.Record, 0, len(desired))
+	for i := range desired {
+		desiredRecords = append(desiredRecords, desired[i].Record())
+	}
+	zonePlan.Diff = hetzner_dns.DiffRecords(zonePlan.Config.Name, current, desiredRecords)
+	zonePlan.Diff.FromLabel = "live"
+	zonePlan.Diff.ToLabel = "config"
+	return nil
+}
+
+// Apply executes the plan, creating missing zones and reconciling records.
+func Apply(ctx context.Context, client *hetzner_dns.Client, plan *Plan) error {
+	for i := range plan.Zones {
+		zonePlan := &plan.Zones[i]
+		if zonePlan.CreateZone {
+			zoneResponse, err := client.CreateZone(ctx, hetzner_dns.ZoneRequest{
+				Name: zonePlan.Config.Name,
+				TTL:  zonePlan.Config.TTL,
+			})
+			if err != nil {
+				return errors.Wrapf(err, "can't create zone %s", zonePlan.Config.Name)
+			}
+			zonePlan.ZoneID = zoneResponse.Zone.ID
+			zonePlan.CreateZone = false
+
+			// new zones come with default records, plan again against them
+			current, err := client.GetAllRecords(ctx, zonePlan.ZoneID)
+			if err != nil {
+				return errors.Wrapf(err, "can't get records for zone %s", zonePlan.Config.Name)
+			}
+			if err := plan.planZone(zonePlan, current); err != nil {
+				return err
+			}
+		}
+		if zonePlan.ChangeSet.Empty() {
+			continue
+		}
+		if err := client.ApplyChanges(ctx, zonePlan.ChangeSet); err != nil {
+			return errors.Wrapf(err, "can't apply changes to zone %s", zonePlan.Config.Name)
+		}
+	}
+	return nil
+}
+
+func withoutApexNS(records []hetzner_dns.Record) []hetzner_dns.Record {
+	filtered := make([]hetzner_dns.Record, 0, len(records))
+	for _, record := range records {
+		if record.Type == "NS" && (record.Name == "@" || record.Name == "") {
+			continue
+		}
+		filtered = append(filtered, record)
+	}
+	return filtered
+}
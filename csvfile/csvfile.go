@@ -0,0 +1,187 @@
+// Package csvfile exports records to CSV or TSV files and imports them back,
+// validating the rows and upserting them with the bulk endpoints, so DNS
+// inventories can be maintained in spreadsheets.
+package csvfile
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+)
+
+// Columns of the files.
+const (
+	COLUMN_ZONE     = "zone"
+	COLUMN_ZONE_ID  = "zone_id"
+	COLUMN_ID       = "id"
+	COLUMN_NAME     = "name"
+	COLUMN_TYPE     = "type"
+	COLUMN_VALUE    = "value"
+	COLUMN_TTL      = "ttl"
+	COLUMN_CREATED  = "created"
+	COLUMN_MODIFIED = "modified"
+)
+
+// DefaultColumns are the columns exported by default, which are also the
+// ones needed to import records. The id column lets rows with changed values
+// update their records.
+var DefaultColumns = []string{COLUMN_ZONE, COLUMN_ID, COLUMN_NAME, COLUMN_TYPE, COLUMN_VALUE, COLUMN_TTL}
+
+// AllColumns are all the columns which can be exported.
+var AllColumns = []string{COLUMN_ZONE, COLUMN_ZONE_ID, COLUMN_ID, COLUMN_NAME, COLUMN_TYPE, COLUMN_VALUE, COLUMN_TTL, COLUMN_CREATED, COLUMN_MODIFIED}
+
+// Comma returns the field separator of a file format: "csv" (the default)
+// or "tsv".
+func Comma(format string) (rune, error) {
+	switch strings.ToLower(format) {
+	case "", "csv":
+		return ',', nil
+	case "tsv":
+		return '\t', nil
+	}
+	return 0, errors.Errorf("csvfile: unknown format %q", format)
+}
+
+// Writer writes records as rows of the configured columns. TSV rows are
+// written without quoting, values being unlikely to contain tabs.
+type Writer struct {
+	Columns []string
+	csv     *csv.Writer
+	tsv     *bufio.Writer
+}
+
+// NewWriter creates a Writer with comma as field separator, writing columns
+// (DefaultColumns if empty).
+func NewWriter(w io.Writer, comma rune, columns []string) (*Writer, error) {
+	if len(columns) == 0 {
+		columns = DefaultColumns
+	}
+	for _, column := range columns {
+		if !contains(AllColumns, column) {
+			return nil, errors.Errorf("csvfile: unknown column %q", column)
+		}
+	}
+	if comma == '\t' {
+		return &Writer{Columns: columns, tsv: bufio.NewWriter(w)}, nil
+	}
+	csvWriter := csv.NewWriter(w)
+	csvWriter.Comma = comma
+	return &Writer{Columns: columns, csv: csvWriter}, nil
+}
+
+// WriteHeader writes the header row.
+func (writer *Writer) WriteHeader() error {
+	return writer.writeRow(writer.Columns)
+}
+
+// Write writes the row of record, of the zone zoneName.
+func (writer *Writer) Write(zoneName string, record *hetzner_dns.Record) error {
+	row := make([]string, len(writer.Columns))
+	for i, column := range writer.Columns {
+		switch column {
+		case COLUMN_ZONE:
+			row[i] = zoneName
+		case COLUMN_ZONE_ID:
+			row[i] = record.ZoneID
+		case COLUMN_ID:
+			row[i] = record.ID
+		case COLUMN_NAME:
+			row[i] = record.Name
+		case COLUMN_TYPE:
+			row[i] = record.Type
+		case COLUMN_VALUE:
+			row[i] = record.Value
+		case COLUMN_TTL:
+			if record.TTL > 0 {
+				row[i] = strconv.Itoa(record.TTL)
+			}
+		case COLUMN_CREATED:
+			row[i] = formatTime(time.Time(record.Created))
+		case COLUMN_MODIFIED:
+			row[i] = formatTime(time.Time(record.Modified))
+		}
+	}
+	return writer.writeRow(row)
+}
+
+func (writer *Writer) writeRow(row []string) error {
+	if writer.tsv != nil {
+		_, err := writer.tsv.WriteString(strings.Join(row, "\t") + "\n")
+		return err
+	}
+	return writer.csv.Write(row)
+}
+
+// Flush writes any buffered data, returning the first write error.
+func (writer *Writer) Flush() error {
+	if writer.tsv != nil {
+		return writer.tsv.Flush()
+	}
+	writer.csv.Flush()
+	return writer.csv.Error()
+}
+
+// ExportOptions configures Export.
+type ExportOptions struct {
+	// Comma is the field separator (',' if zero).
+	Comma rune
+	// Columns are the exported columns (DefaultColumns if empty).
+	Columns []string
+	// Query selects the records to export (all if nil).
+	Query *hetzner_dns.RecordQuery
+	// FanOut fetches the zones concurrently (a default one if nil).
+	FanOut *hetzner_dns.FanOut
+}
+
+// Export writes the records selected by options.Query, sorted by zone, name
+// and type, returning the number of records written.
+func Export(ctx context.Context, client *hetzner_dns.Client, w io.Writer, options ExportOptions) (int, error) {
+	if options.Comma == 0 {
+		options.Comma = ','
+	}
+	writer, err := NewWriter(w, options.Comma, options.Columns)
+	if err != nil {
+		return 0, err
+	}
+	query := options.Query
+	if query == nil {
+		query = &hetzner_dns.RecordQuery{}
+	}
+	matches, err := client.SearchRecords(ctx, query, options.FanOut)
+	if err != nil {
+		return 0, err
+	}
+	if err := writer.WriteHeader(); err != nil {
+		return 0, errors.Wrap(err, "can't write header")
+	}
+	for i := range matches {
+		if err := writer.Write(matches[i].ZoneName, &matches[i].Record); err != nil {
+			return i, errors.Wrap(err, "can't write record")
+		}
+	}
+	return len(matches), writer.Flush()
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,227 @@
+package csvfile_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+	"github.com/panta/go-hetzner-dns/csvfile"
+	"github.com/panta/go-hetzner-dns/internal/fakeapi"
+)
+
+func newAPI(t *testing.T) *fakeapi.API {
+	api := fakeapi.New(t)
+	api.AddZoneWithTTL("zone-1", "example.com", 3600)
+	api.AddZoneWithTTL("zone-2", "example.org", 3600)
+	for _, request := range []hetzner_dns.RecordRequest{
+		{ZoneID: "zone-1", Name: "@", Type: "A", Value: "192.0.2.1"},
+		{ZoneID: "zone-1", Name: "www", Type: "CNAME", Value: "example.com.", TTL: 300},
+		{ZoneID: "zone-1", Name: "@", Type: "TXT", Value: `"v=spf1 mx -all"`},
+		{ZoneID: "zone-2", Name: "@", Type: "MX", Value: "10 mail.example.org."},
+	} {
+		api.Insert(request)
+	}
+	return api
+}
+
+func TestExport(t *testing.T) {
+	api := newAPI(t)
+	defer api.Close()
+	client := api.Client()
+
+	var buf bytes.Buffer
+	count, err := csvfile.Export(context.Background(), client, &buf, csvfile.ExportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `zone,id,name,type,value,ttl
+example.com,rec-1,@,A,192.0.2.1,
+example.com,rec-3,@,TXT,"""v=spf1 mx -all""",
+example.com,rec-2,www,CNAME,example.com.,300
+example.org,rec-4,@,MX,10 mail.example.org.,
+`
+	if count != 4 || buf.String() != expected {
+		t.Errorf("unexpected export (%d records):\n%s", count, buf.String())
+	}
+
+	buf.Reset()
+	_, err = csvfile.Export(context.Background(), client, &buf, csvfile.ExportOptions{
+		Comma:   '\t',
+		Columns: []string{csvfile.COLUMN_ID, csvfile.COLUMN_NAME, csvfile.COLUMN_VALUE},
+		Query:   &hetzner_dns.RecordQuery{Types: []string{"MX"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "id\tname\tvalue\nrec-4\t@\t10 mail.example.org.\n" {
+		t.Errorf("unexpected TSV export:\n%s", buf.String())
+	}
+	if _, err := csvfile.Export(context.Background(), client, &buf, csvfile.ExportOptions{Columns: []string{"color"}}); err == nil {
+		t.Errorf("expected an error for an unknown column")
+	}
+}
+
+func TestRead(t *testing.T) {
+	input := `Zone,Name,Type,Value,TTL,Notes
+example.com,www,A,192.0.2.10,300,web server
+
+example.com,,TXT,"multi
+line",,
+example.com,bad,A,2001:db8::1,,
+example.com,mx,MX,mail.example.com.,,
+example.com,www,A,192.0.2.10,,
+example.com,short,A,192.0.2.11,30,
+,orphan,A,192.0.2.12,,
+example.com,x,BOGUS,1,,
+example.com,@,CNAME,example.net.,,
+example.com,ttl,A,192.0.2.13,soon,
+`
+	rows, err := csvfile.Read(strings.NewReader(input), csvfile.ReadOptions{})
+	rowErrors, ok := err.(csvfile.RowErrors)
+	if !ok {
+		t.Fatalf("expected RowErrors, got %v", err)
+	}
+	var lines []int
+	for _, rowError := range rowErrors {
+		lines = append(lines, rowError.Line)
+	}
+	if len(lines) != 8 || lines[0] != 6 || lines[1] != 7 || lines[2] != 8 || lines[7] != 13 {
+		t.Errorf("unexpected errors on lines %v:\n%v", lines, err)
+	}
+	if !strings.Contains(err.Error(), "line 8: duplicate of line 2") {
+		t.Errorf("expected the duplicate to refer to line 2:\n%v", err)
+	}
+	if len(rows) != 2 || rows[0].TTL != 300 || rows[1].Line != 4 || rows[1].Name != "@" || rows[1].Value != "multi\nline" {
+		t.Errorf("unexpected rows %+v", rows)
+	}
+
+	rows, err = csvfile.Read(strings.NewReader("name\ttype\tvalue\nwww\tTXT\t\"quoted\" text\n"), csvfile.ReadOptions{Comma: '\t', Zone: "example.com."})
+	if err != nil || len(rows) != 1 || rows[0].Zone != "example.com" || rows[0].Value != `"quoted" text` {
+		t.Errorf("unexpected TSV rows %+v (%v)", rows, err)
+	}
+	if _, err := csvfile.Read(strings.NewReader("name,type\n"), csvfile.ReadOptions{}); err == nil || !strings.Contains(err.Error(), "line 1: missing value column") {
+		t.Errorf("expected a missing column error, got %v", err)
+	}
+}
+
+func TestImport(t *testing.T) {
+	api := newAPI(t)
+	defer api.Close()
+	client := api.Client()
+
+	input := `zone,id,name,type,value,ttl
+example.com,,@,A,192.0.2.1,
+example.com,,www,CNAME,lb.example.net.,300
+example.com,rec-3,@,TXT,"v=spf1 -all",
+example.org,,@,MX,10 mail.example.org.,600
+example.org,,api,A,192.0.2.20,
+`
+	rows, err := csvfile.Read(strings.NewReader(input), csvfile.ReadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := csvfile.Import(context.Background(), client, rows, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Count(csvfile.ACTION_UNCHANGED) != 1 || report.Count(csvfile.ACTION_UPDATE) != 3 || report.Count(csvfile.ACTION_CREATE) != 1 {
+		t.Errorf("unexpected report:\n%s", report)
+	}
+	if calls := api.CallCount("POST") + api.CallCount("PUT"); calls != 0 {
+		t.Errorf("expected no changes in dry-run, got %d calls", calls)
+	}
+	if !strings.Contains(report.String(), `line 3: ~ example.com www CNAME "example.com." ttl=300 -> "lb.example.net." ttl=300`) {
+		t.Errorf("expected the CNAME to be updated:\n%s", report)
+	}
+
+	if _, err := csvfile.Import(context.Background(), client, rows, false); err != nil {
+		t.Fatal(err)
+	}
+	// one bulk creation in example.org, one bulk update per zone
+	if api.CallCount("POST /records/bulk") != 1 || api.CallCount("PUT /records/bulk") != 2 {
+		t.Errorf("unexpected calls %v", api.Calls())
+	}
+	if records := api.ZoneRecords("zone-2"); len(records) != 2 || records[0].TTL != 600 || records[1].Name != "api" {
+		t.Errorf("unexpected records %+v", records)
+	}
+
+	rows = append(rows, csvfile.Row{Line: 7, Zone: "example.net", Name: "@", Type: "A", Value: "192.0.2.1"},
+		csvfile.Row{Line: 8, Zone: "example.com", ID: "nope", Name: "@", Type: "A", Value: "192.0.2.1"})
+	_, err = csvfile.PlanImport(context.Background(), client, rows)
+	if rowErrors, ok := err.(csvfile.RowErrors); !ok || len(rowErrors) != 2 || rowErrors[0].Line != 7 || rowErrors[1].Line != 8 {
+		t.Errorf("expected errors on lines 7 and 8, got %v", err)
+	}
+}
+
+func TestImportChangedValue(t *testing.T) {
+	api := newAPI(t)
+	defer api.Close()
+	client := api.Client()
+
+	// without id, a changed value updates the only record of its name and
+	// type, unless the file has several rows of that name and type
+	for input, expected := range map[string]string{
+		"zone,name,type,value\nexample.com,@,A,192.0.2.9\n":                            csvfile.ACTION_UPDATE,
+		"zone,name,type,value\nexample.com,@,A,192.0.2.9\nexample.com,@,A,192.0.2.8\n": csvfile.ACTION_CREATE,
+	} {
+		rows, err := csvfile.Read(strings.NewReader(input), csvfile.ReadOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		report, err := csvfile.PlanImport(context.Background(), client, rows)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Count(expected) != len(rows) {
+			t.Errorf("expected %q to %s records:\n%s", input, expected, report)
+		}
+	}
+}
+
+func TestImportSameRecord(t *testing.T) {
+	api := newAPI(t)
+	defer api.Close()
+	client := api.Client()
+	id := api.AddRecord("zone-2", "", "TXT", "v=spf1 mx -all")
+
+	// an empty name of the API is the apex
+	rows, err := csvfile.Read(strings.NewReader("zone,id,name,type,value\nexample.org,"+id+",@,TXT,v=spf1 mx -all\n"), csvfile.ReadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := csvfile.PlanImport(context.Background(), client, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Count(csvfile.ACTION_UNCHANGED) != 1 {
+		t.Errorf("expected the record to be unchanged:\n%s", report)
+	}
+
+	// two rows can't update the same record
+	rows, err = csvfile.Read(strings.NewReader("zone,id,name,type,value\nexample.org,"+id+",@,TXT,v=spf1 a -all\nexample.org,"+id+",@,TXT,v=spf1 -all\n"), csvfile.ReadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = csvfile.PlanImport(context.Background(), client, rows)
+	if rowErrors, ok := err.(csvfile.RowErrors); !ok || len(rowErrors) != 1 || rowErrors[0].Line != 3 || !strings.Contains(rowErrors[0].Msg, "line 2") {
+		t.Errorf("expected an error on line 3, got %v", err)
+	}
+}
+
+func TestApplyImportFailures(t *testing.T) {
+	api := newAPI(t)
+	defer api.Close()
+	client := api.Client()
+
+	for _, action := range []string{csvfile.ACTION_CREATE, csvfile.ACTION_UPDATE} {
+		report := &csvfile.Report{Changes: []csvfile.Change{{
+			Line: 2, Zone: "example.com", ZoneID: "zone-1", Action: action,
+			Record: hetzner_dns.RecordRequest{ID: "missing", ZoneID: "zone-1", Name: "@", Type: "A"},
+		}}}
+		if err := csvfile.ApplyImport(context.Background(), client, report); err == nil || !strings.Contains(err.Error(), "1 records") {
+			t.Errorf("%s: expected an error for the failed record, got %v", action, err)
+		}
+	}
+}
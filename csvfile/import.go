@@ -0,0 +1,483 @@
+package csvfile
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+)
+
+const (
+	ACTION_CREATE    = "create"
+	ACTION_UPDATE    = "update"
+	ACTION_UNCHANGED = "unchanged"
+)
+
+// RECORD_TYPES are the record types supported by the API.
+var RECORD_TYPES = []string{"A", "AAAA", "CAA", "CNAME", "DANE", "DS", "HINFO", "MX", "NS", "PTR", "RP", "SOA", "SRV", "TLSA", "TXT"}
+
+// Row is a row of an imported file.
+type Row struct {
+	// Line is the line of the row in the file.
+	Line int
+	Zone string
+	// ID, if set, is the record to update.
+	ID    string
+	Name  string
+	Type  string
+	Value string
+	TTL   int
+}
+
+// Request returns the record request of the row, without zone.
+func (row *Row) Request() hetzner_dns.RecordRequest {
+	return hetzner_dns.RecordRequest{ID: row.ID, Name: row.Name, Type: row.Type, Value: row.Value, TTL: row.TTL}
+}
+
+// RowError is an invalid row.
+type RowError struct {
+	Line int
+	Msg  string
+}
+
+func (rowError *RowError) Error() string {
+	return fmt.Sprintf("line %d: %s", rowError.Line, rowError.Msg)
+}
+
+// RowErrors are all the invalid rows of a file.
+type RowErrors []*RowError
+
+func (rowErrors RowErrors) Error() string {
+	messages := make([]string, len(rowErrors))
+	for i, rowError := range rowErrors {
+		messages[i] = rowError.Error()
+	}
+	return fmt.Sprintf("%d invalid rows:\n%s", len(rowErrors), strings.Join(messages, "\n"))
+}
+
+// ReadOptions configures Read.
+type ReadOptions struct {
+	// Comma is the field separator (',' if zero).
+	Comma rune
+	// Zone is the zone of the rows if the file has no zone column.
+	Zone string
+	// Lenient disables the validation of the rows and the rejection of
+	// duplicates, for callers normalizing the records themselves. Rows that
+	// can't be parsed are still errors.
+	Lenient bool
+}
+
+// Read reads and validates the rows of a file. The header row names the
+// columns, in any order: zone (unless options.Zone is set), name, type and
+// value are required, ttl and id are optional and other columns are
+// ignored. All the invalid rows are reported as RowErrors.
+func Read(r io.Reader, options ReadOptions) ([]Row, error) {
+	comma := options.Comma
+	if comma == 0 {
+		comma = ','
+	}
+	lines := newLineReader(r, comma)
+
+	headerLine, header, err := lines.next()
+	if err == io.EOF {
+		return nil, RowErrors{{Line: 1, Msg: "missing header"}}
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "can't read header")
+	}
+	columns := map[string]int{}
+	for i, column := range header {
+		columns[strings.ToLower(strings.TrimSpace(column))] = i
+	}
+	required := []string{COLUMN_NAME, COLUMN_TYPE, COLUMN_VALUE}
+	if options.Zone == "" {
+		required = append(required, COLUMN_ZONE)
+	}
+	for _, column := range required {
+		if _, ok := columns[column]; !ok {
+			return nil, RowErrors{{Line: headerLine, Msg: fmt.Sprintf("missing %s column", column)}}
+		}
+	}
+	field := func(fields []string, column string) string {
+		if i, ok := columns[column]; ok && i < len(fields) {
+			return strings.TrimSpace(fields[i])
+		}
+		return ""
+	}
+
+	var rows []Row
+	var rowErrors RowErrors
+	seen := map[string]int{}
+	for {
+		line, fields, err := lines.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			rowErrors = append(rowErrors, &RowError{Line: line, Msg: err.Error()})
+			continue
+		}
+		row := Row{
+			Line:  line,
+			Zone:  strings.TrimSuffix(field(fields, COLUMN_ZONE), "."),
+			ID:    field(fields, COLUMN_ID),
+			Name:  field(fields, COLUMN_NAME),
+			Type:  strings.ToUpper(field(fields, COLUMN_TYPE)),
+			Value: field(fields, COLUMN_VALUE),
+		}
+		if row.Zone == "" {
+			row.Zone = strings.TrimSuffix(options.Zone, ".")
+		}
+		if row.Name == "" {
+			row.Name = "@"
+		}
+		if ttl := field(fields, COLUMN_TTL); ttl != "" {
+			if row.TTL, err = strconv.Atoi(ttl); err != nil || row.TTL < 0 {
+				rowErrors = append(rowErrors, &RowError{Line: line, Msg: fmt.Sprintf("invalid ttl %q", ttl)})
+				continue
+			}
+		}
+		if options.Lenient {
+			rows = append(rows, row)
+			continue
+		}
+		if msg := validate(&row); msg != "" {
+			rowErrors = append(rowErrors, &RowError{Line: line, Msg: msg})
+			continue
+		}
+		key := strings.ToLower(row.Zone+" "+row.Name) + " " + row.Type + " " + row.Value
+		if first, ok := seen[key]; ok {
+			rowErrors = append(rowErrors, &RowError{Line: line, Msg: fmt.Sprintf("duplicate of line %d", first)})
+			continue
+		}
+		seen[key] = line
+		rows = append(rows, row)
+	}
+	if len(rowErrors) > 0 {
+		return rows, rowErrors
+	}
+	return rows, nil
+}
+
+// lineReader reads the rows of a file with their line numbers, which
+// csv.Reader doesn't track. Blank lines are skipped; CSV rows span several
+// lines while a quoted field is open, TSV rows are single lines without
+// quoting.
+type lineReader struct {
+	scanner *bufio.Scanner
+	comma   rune
+	line    int
+}
+
+func newLineReader(r io.Reader, comma rune) *lineReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	return &lineReader{scanner: scanner, comma: comma}
+}
+
+// next returns the next row and the line it starts at.
+func (lines *lineReader) next() (int, []string, error) {
+	var text string
+	start := 0
+	for lines.scanner.Scan() {
+		lines.line++
+		if start == 0 {
+			if strings.TrimSpace(lines.scanner.Text()) == "" {
+				continue
+			}
+			start, text = lines.line, lines.scanner.Text()
+		} else {
+			text += "\n" + lines.scanner.Text()
+		}
+		// TSV rows are single lines, CSV ones end with balanced quotes
+		if lines.comma == '\t' || strings.Count(text, `"`)%2 == 0 {
+			break
+		}
+	}
+	if err := lines.scanner.Err(); err != nil {
+		return lines.line, nil, err
+	}
+	if start == 0 {
+		return lines.line, nil, io.EOF
+	}
+
+	if lines.comma == '\t' {
+		return start, strings.Split(text, "\t"), nil
+	}
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.Comma = lines.comma
+	reader.FieldsPerRecord = -1
+	fields, err := reader.Read()
+	if err != nil {
+		if parseError, ok := err.(*csv.ParseError); ok {
+			err = parseError.Err
+		}
+		return start, nil, err
+	}
+	return start, fields, nil
+}
+
+// validate returns why row is invalid, or an empty string.
+func validate(row *Row) string {
+	switch {
+	case row.Zone == "":
+		return "missing zone"
+	case row.Type == "":
+		return "missing type"
+	case !contains(RECORD_TYPES, row.Type):
+		return fmt.Sprintf("unknown type %q", row.Type)
+	case row.Value == "":
+		return "missing value"
+	case row.TTL > 0 && row.TTL < 60:
+		return fmt.Sprintf("ttl %d is lower than 60", row.TTL)
+	}
+	fields := strings.Fields(row.Value)
+	switch row.Type {
+	case "A":
+		if ip := net.ParseIP(row.Value); ip == nil || ip.To4() == nil {
+			return fmt.Sprintf("invalid IPv4 address %q", row.Value)
+		}
+	case "AAAA":
+		if ip := net.ParseIP(row.Value); ip == nil || ip.To4() != nil {
+			return fmt.Sprintf("invalid IPv6 address %q", row.Value)
+		}
+	case "CNAME":
+		if row.Name == "@" {
+			return "CNAME records can't be at the zone apex"
+		}
+		if len(fields) != 1 {
+			return fmt.Sprintf("invalid CNAME target %q", row.Value)
+		}
+	case "MX":
+		if len(fields) != 2 || !isUint16(fields[0]) {
+			return fmt.Sprintf("invalid MX value %q (expected PRIORITY HOST)", row.Value)
+		}
+	case "SRV":
+		if len(fields) != 4 || !isUint16(fields[0]) || !isUint16(fields[1]) || !isUint16(fields[2]) {
+			return fmt.Sprintf("invalid SRV value %q (expected PRIORITY WEIGHT PORT TARGET)", row.Value)
+		}
+	}
+	return ""
+}
+
+func isUint16(s string) bool {
+	_, err := strconv.ParseUint(s, 10, 16)
+	return err == nil
+}
+
+// Change is the change of a row.
+type Change struct {
+	Line     int                       `json:"line"`
+	Zone     string                    `json:"zone"`
+	ZoneID   string                    `json:"zone_id"`
+	Action   string                    `json:"action"`
+	Record   hetzner_dns.RecordRequest `json:"record"`
+	Previous *hetzner_dns.Record       `json:"previous,omitempty"`
+}
+
+// Report is the outcome of an import.
+type Report struct {
+	Changes []Change `json:"changes"`
+	DryRun  bool     `json:"dry_run"`
+}
+
+// Count returns the number of changes with action.
+func (report *Report) Count(action string) int {
+	count := 0
+	for _, change := range report.Changes {
+		if change.Action == action {
+			count++
+		}
+	}
+	return count
+}
+
+// String returns the changes, one per line, and their counts.
+func (report *Report) String() string {
+	var sb strings.Builder
+	for _, change := range report.Changes {
+		record := change.Record
+		switch change.Action {
+		case ACTION_CREATE:
+			fmt.Fprintf(&sb, "line %d: + %s %s %s %s ttl=%d\n", change.Line, change.Zone, record.Name, record.Type, record.Value, record.TTL)
+		case ACTION_UPDATE:
+			fmt.Fprintf(&sb, "line %d: ~ %s %s %s %q ttl=%d -> %q ttl=%d\n", change.Line, change.Zone, record.Name, record.Type,
+				change.Previous.Value, change.Previous.TTL, record.Value, record.TTL)
+		}
+	}
+	fmt.Fprintf(&sb, "%d to create, %d to update, %d unchanged", report.Count(ACTION_CREATE), report.Count(ACTION_UPDATE), report.Count(ACTION_UNCHANGED))
+	if report.DryRun {
+		sb.WriteString(" (dry run)")
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// PlanImport compares rows with the live records. A row updates the record
+// with its id if set, otherwise the record with the same name, type and
+// value (or, for CNAMEs, the same name and type); the other rows create
+// records. Rows of unknown zones or ids, and rows updating a record already
+// updated by another row, are reported as RowErrors.
+func PlanImport(ctx context.Context, client *hetzner_dns.Client, rows []Row) (*Report, error) {
+	zones, err := client.GetAllZones(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't get zones")
+	}
+	zoneIds := map[string]string{}
+	for _, zone := range zones {
+		zoneIds[hetzner_dns.NormalizeZoneName(zone.Name)] = zone.ID
+	}
+
+	report := &Report{}
+	var rowErrors RowErrors
+	current := map[string][]hetzner_dns.Record{}
+	updatedBy := map[string]int{} // record ID -> line
+	rowCounts := map[string]int{}
+	for _, row := range rows {
+		rowCounts[nameTypeKey(row.Zone, row.Name, row.Type)]++
+	}
+	for _, row := range rows {
+		zoneId, ok := zoneIds[strings.ToLower(row.Zone)]
+		if !ok {
+			rowErrors = append(rowErrors, &RowError{Line: row.Line, Msg: fmt.Sprintf("unknown zone %s", row.Zone)})
+			continue
+		}
+		records, ok := current[zoneId]
+		if !ok {
+			if records, err = client.GetAllRecords(ctx, zoneId); err != nil {
+				return nil, errors.Wrapf(err, "can't get records for zone %s", row.Zone)
+			}
+			current[zoneId] = records
+		}
+
+		change := Change{Line: row.Line, Zone: row.Zone, ZoneID: zoneId, Action: ACTION_CREATE, Record: row.Request()}
+		change.Record.ZoneID = zoneId
+		change.Previous = match(records, &row, rowCounts[nameTypeKey(row.Zone, row.Name, row.Type)] == 1)
+		if row.ID != "" && change.Previous == nil {
+			rowErrors = append(rowErrors, &RowError{Line: row.Line, Msg: fmt.Sprintf("unknown record id %s in zone %s", row.ID, row.Zone)})
+			continue
+		}
+		if change.Previous != nil {
+			if first, ok := updatedBy[change.Previous.ID]; ok {
+				rowErrors = append(rowErrors, &RowError{Line: row.Line, Msg: fmt.Sprintf("record id %s already updated by line %d", change.Previous.ID, first)})
+				continue
+			}
+			updatedBy[change.Previous.ID] = row.Line
+			change.Record.ID = change.Previous.ID
+			change.Action = ACTION_UPDATE
+			if normalizeName(change.Previous.Name) == normalizeName(row.Name) && change.Previous.Type == row.Type &&
+				change.Previous.Value == row.Value && change.Previous.TTL == row.TTL {
+				change.Action = ACTION_UNCHANGED
+			}
+		}
+		report.Changes = append(report.Changes, change)
+	}
+	if len(rowErrors) > 0 {
+		return report, rowErrors
+	}
+	return report, nil
+}
+
+// match returns the live record updated by row, if any: the record with its
+// ID, else the record with the same name, type and value, else the only
+// record with the same name and type if row is the only row of that name and
+// type. Otherwise rows without ID create records, leaving the records with
+// the previous values in place.
+func match(records []hetzner_dns.Record, row *Row, onlyRow bool) *hetzner_dns.Record {
+	var sameNameType []*hetzner_dns.Record
+	for i := range records {
+		record := &records[i]
+		if row.ID != "" {
+			if record.ID == row.ID {
+				return record
+			}
+			continue
+		}
+		if !strings.EqualFold(normalizeName(record.Name), row.Name) || record.Type != row.Type {
+			continue
+		}
+		if record.Value == row.Value {
+			return record
+		}
+		sameNameType = append(sameNameType, record)
+	}
+	if row.ID == "" && onlyRow && len(sameNameType) == 1 {
+		return sameNameType[0]
+	}
+	return nil
+}
+
+func nameTypeKey(zone string, name string, recordType string) string {
+	return strings.ToLower(zone+" "+name) + " " + recordType
+}
+
+// ApplyImport applies the changes of report with one BulkCreateRecords and
+// one BulkUpdateRecords call per zone.
+func ApplyImport(ctx context.Context, client *hetzner_dns.Client, report *Report) error {
+	var zoneIds []string
+	creates := map[string]*hetzner_dns.BulkRecordRequest{}
+	updates := map[string]*hetzner_dns.BulkRecordRequest{}
+	for _, change := range report.Changes {
+		if _, ok := creates[change.ZoneID]; !ok {
+			zoneIds = append(zoneIds, change.ZoneID)
+			creates[change.ZoneID] = &hetzner_dns.BulkRecordRequest{}
+			updates[change.ZoneID] = &hetzner_dns.BulkRecordRequest{}
+		}
+		switch change.Action {
+		case ACTION_CREATE:
+			creates[change.ZoneID].Records = append(creates[change.ZoneID].Records, change.Record)
+		case ACTION_UPDATE:
+			updates[change.ZoneID].Records = append(updates[change.ZoneID].Records, change.Record)
+		}
+	}
+
+	for _, zoneId := range zoneIds {
+		if len(creates[zoneId].Records) > 0 {
+			response, err := client.BulkCreateRecords(ctx, creates[zoneId])
+			if err != nil {
+				return errors.Wrapf(err, "can't create records in zone %s", zoneId)
+			}
+			if n := len(response.InvalidRecords) + len(response.FailedRecords); n > 0 {
+				return errors.Errorf("csvfile: %d records of zone %s failed to create", n, zoneId)
+			}
+		}
+		if len(updates[zoneId].Records) > 0 {
+			response, err := client.BulkUpdateRecords(ctx, updates[zoneId])
+			if err != nil {
+				return errors.Wrapf(err, "can't update records in zone %s", zoneId)
+			}
+			if n := len(response.InvalidRecords) + len(response.FailedRecords); n > 0 {
+				return errors.Errorf("csvfile: %d records of zone %s failed to update", n, zoneId)
+			}
+		}
+	}
+	return nil
+}
+
+// Import plans the import of rows and, unless dryRun is set, applies it.
+func Import(ctx context.Context, client *hetzner_dns.Client, rows []Row, dryRun bool) (*Report, error) {
+	report, err := PlanImport(ctx, client, rows)
+	if err != nil {
+		return report, err
+	}
+	report.DryRun = dryRun
+	if dryRun {
+		return report, nil
+	}
+	return report, ApplyImport(ctx, client, report)
+}
+
+func normalizeName(name string) string {
+	if name == "" {
+		return "@"
+	}
+	return name
+}
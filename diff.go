@@ -0,0 +1,267 @@
+package hetzner_dns
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DiffOp is the kind of difference between two versions of a record set.
+type DiffOp string
+
+const (
+	DIFF_ADDED   DiffOp = "added"
+	DIFF_REMOVED DiffOp = "removed"
+	DIFF_TTL     DiffOp = "ttl_changed"
+)
+
+// DiffFormat is an output format for ZoneDiff.Render.
+type DiffFormat string
+
+const (
+	DIFF_FORMAT_UNIFIED  DiffFormat = "unified"
+	DIFF_FORMAT_COLOR    DiffFormat = "color"
+	DIFF_FORMAT_JSON     DiffFormat = "json"
+	DIFF_FORMAT_MARKDOWN DiffFormat = "markdown"
+)
+
+const (
+	ansiReset  = "\033[0m"
+	ansiBold   = "\033[1m"
+	ansiRed    = "\033[31m"
+	ansiGreen  = "\033[32m"
+	ansiYellow = "\033[33m"
+	ansiCyan   = "\033[36m"
+)
+
+// DiffEntry is a single difference. Records are identified by name, type and
+// value, so a changed value shows up as a removal plus an addition. OldTTL is
+// nil for added records and NewTTL for removed ones; a TTL of 0 is the default
+// TTL of the zone.
+type DiffEntry struct {
+	Op     DiffOp `json:"op"`
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Value  string `json:"value"`
+	OldTTL *int   `json:"old_ttl,omitempty"`
+	NewTTL *int   `json:"new_ttl,omitempty"`
+}
+
+// ZoneDiff holds the differences between two record sets of a zone.
+type ZoneDiff struct {
+	Zone      string      `json:"zone"`
+	FromLabel string      `json:"from"`
+	ToLabel   string      `json:"to"`
+	Entries   []DiffEntry `json:"changes"`
+}
+
+// Empty returns true if there are no differences.
+func (diff *ZoneDiff) Empty() bool {
+	return len(diff.Entries) == 0
+}
+
+// Count returns the number of entries with the given operation.
+func (diff *ZoneDiff) Count(op DiffOp) int {
+	count := 0
+	for _, entry := range diff.Entries {
+		if entry.Op == op {
+			count++
+		}
+	}
+	return count
+}
+
+// Summary returns a short description of the differences.
+func (diff *ZoneDiff) Summary() string {
+	return fmt.Sprintf("%d to add, %d to remove, %d TTL changes",
+		diff.Count(DIFF_ADDED), diff.Count(DIFF_REMOVED), diff.Count(DIFF_TTL))
+}
+
+// DiffRecords compares two record sets of a zone (e.g. live and desired, or
+// two snapshots), ignoring SOA records. Entries are sorted by name and type.
+func DiffRecords(zone string, from []Record, to []Record) *ZoneDiff {
+	diff := &ZoneDiff{Zone: zone, FromLabel: "from", ToLabel: "to"}
+
+	remaining := map[string][]Record{}
+	for _, record := range from {
+		if record.Type == "SOA" {
+			continue
+		}
+		key := RecordKey(record.Name, record.Type, record.Value)
+		remaining[key] = append(remaining[key], record)
+	}
+	for _, record := range to {
+		if record.Type == "SOA" {
+			continue
+		}
+		key := RecordKey(record.Name, record.Type, record.Value)
+		if matches := remaining[key]; len(matches) > 0 {
+			remaining[key] = matches[1:]
+			if matches[0].TTL != record.TTL {
+				diff.Entries = append(diff.Entries, newDiffEntry(DIFF_TTL, record, ttlPointer(matches[0].TTL), ttlPointer(record.TTL)))
+			}
+			continue
+		}
+		diff.Entries = append(diff.Entries, newDiffEntry(DIFF_ADDED, record, nil, ttlPointer(record.TTL)))
+	}
+	for _, records := range remaining {
+		for _, record := range records {
+			diff.Entries = append(diff.Entries, newDiffEntry(DIFF_REMOVED, record, ttlPointer(record.TTL), nil))
+		}
+	}
+
+	sort.SliceStable(diff.Entries, func(i, j int) bool {
+		a, b := diff.Entries[i], diff.Entries[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		if a.Op != b.Op {
+			return diffOpOrder(a.Op) < diffOpOrder(b.Op)
+		}
+		return a.Value < b.Value
+	})
+	return diff
+}
+
+func newDiffEntry(op DiffOp, record Record, oldTTL *int, newTTL *int) DiffEntry {
+	return DiffEntry{
+		Op:     op,
+		Name:   normalizeName(record.Name),
+		Type:   strings.ToUpper(record.Type),
+		Value:  record.Value,
+		OldTTL: oldTTL,
+		NewTTL: newTTL,
+	}
+}
+
+func ttlPointer(ttl int) *int {
+	return &ttl
+}
+
+func diffOpOrder(op DiffOp) int {
+	switch op {
+	case DIFF_REMOVED:
+		return 0
+	case DIFF_TTL:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// Render writes the diff to w in the given format.
+func (diff *ZoneDiff) Render(w io.Writer, format DiffFormat) error {
+	switch format {
+	case DIFF_FORMAT_UNIFIED, "":
+		return diff.renderUnified(w, false)
+	case DIFF_FORMAT_COLOR:
+		return diff.renderUnified(w, true)
+	case DIFF_FORMAT_JSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(diff)
+	case DIFF_FORMAT_MARKDOWN:
+		return diff.renderMarkdown(w)
+	default:
+		return errors.Errorf("hetzner_dns: unknown diff format %q", format)
+	}
+}
+
+func (diff *ZoneDiff) renderUnified(w io.Writer, color bool) error {
+	paint := func(code string, s string) string {
+		if !color {
+			return s
+		}
+		return code + s + ansiReset
+	}
+
+	var sb strings.Builder
+	sb.WriteString(paint(ansiBold, fmt.Sprintf("--- %s (%s)", diff.Zone, diff.FromLabel)) + "\n")
+	sb.WriteString(paint(ansiBold, fmt.Sprintf("+++ %s (%s)", diff.Zone, diff.ToLabel)) + "\n")
+	lastName := ""
+	for i, entry := range diff.Entries {
+		if i == 0 || entry.Name != lastName {
+			sb.WriteString(paint(ansiCyan, fmt.Sprintf("@@ %s @@", entry.Name)) + "\n")
+			lastName = entry.Name
+		}
+		switch entry.Op {
+		case DIFF_ADDED:
+			sb.WriteString(paint(ansiGreen, "+"+formatDiffLine(entry.Name, *entry.NewTTL, entry.Type, entry.Value)) + "\n")
+		case DIFF_REMOVED:
+			sb.WriteString(paint(ansiRed, "-"+formatDiffLine(entry.Name, *entry.OldTTL, entry.Type, entry.Value)) + "\n")
+		case DIFF_TTL:
+			sb.WriteString(paint(ansiYellow, "-"+formatDiffLine(entry.Name, *entry.OldTTL, entry.Type, entry.Value)) + "\n")
+			sb.WriteString(paint(ansiYellow, "+"+formatDiffLine(entry.Name, *entry.NewTTL, entry.Type, entry.Value)) + "\n")
+		}
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func (diff *ZoneDiff) renderMarkdown(w io.Writer) error {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("### DNS changes for `%s`\n\n", diff.Zone))
+	if diff.Empty() {
+		sb.WriteString("No changes.\n")
+		_, err := io.WriteString(w, sb.String())
+		return err
+	}
+	sb.WriteString(diff.Summary() + ".\n\n")
+	sb.WriteString("| | Name | Type | Value | TTL |\n")
+	sb.WriteString("|---|---|---|---|---|\n")
+	for _, entry := range diff.Entries {
+		var op, ttl string
+		switch entry.Op {
+		case DIFF_ADDED:
+			op, ttl = "+", formatTTL(*entry.NewTTL)
+		case DIFF_REMOVED:
+			op, ttl = "-", formatTTL(*entry.OldTTL)
+		case DIFF_TTL:
+			op, ttl = "~", formatTTL(*entry.OldTTL)+" → "+formatTTL(*entry.NewTTL)
+		}
+		sb.WriteString(fmt.Sprintf("| %s | %s | %s | %s | %s |\n",
+			op, markdownCode(entry.Name), entry.Type, markdownCode(entry.Value), ttl))
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func formatDiffLine(name string, ttl int, recordType string, value string) string {
+	return fmt.Sprintf("%s\t%s\tIN\t%s\t%s", name, formatTTL(ttl), recordType, value)
+}
+
+func formatTTL(ttl int) string {
+	if ttl <= 0 {
+		return "default"
+	}
+	return fmt.Sprintf("%d", ttl)
+}
+
+// markdownCode returns s as a code span of a table cell, delimited by more
+// backticks than s contains in a row so that its backticks are kept.
+func markdownCode(s string) string {
+	longest, run := 0, 0
+	for _, c := range s {
+		if c == '`' {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	fence := strings.Repeat("`", longest+1)
+	s = strings.Replace(s, "|", "\\|", -1)
+	if strings.HasPrefix(s, "`") || strings.HasSuffix(s, "`") {
+		s = " " + s + " "
+	}
+	return fence + s + fence
+}
@@ -0,0 +1,94 @@
+package hetzner_dns_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+)
+
+func TestDiffRecords(t *testing.T) {
+	from := []hetzner_dns.Record{
+		{Type: "SOA", Name: "@", Value: "ns1. dns. 1 86400 10800 3600000 3600"},
+		{Type: "A", Name: "@", Value: "10.0.0.10"},
+		{Type: "A", Name: "www", Value: "10.0.0.1", TTL: 60},
+		{Type: "TXT", Name: "@", Value: "v=spf1 mx -all", TTL: 300},
+	}
+	to := []hetzner_dns.Record{
+		{Type: "SOA", Name: "@", Value: "ns1. dns. 2 86400 10800 3600000 3600"},
+		{Type: "A", Name: "", Value: "10.0.0.10"},
+		{Type: "A", Name: "www", Value: "10.0.0.2", TTL: 60},
+		{Type: "TXT", Name: "@", Value: "v=spf1 mx -all", TTL: 3600},
+	}
+
+	diff := hetzner_dns.DiffRecords("example.com", from, to)
+	if diff.Count(hetzner_dns.DIFF_ADDED) != 1 || diff.Count(hetzner_dns.DIFF_REMOVED) != 1 || diff.Count(hetzner_dns.DIFF_TTL) != 1 {
+		t.Fatalf("Wrong diff: %+v", diff.Entries)
+	}
+
+	var unified bytes.Buffer
+	if err := diff.Render(&unified, hetzner_dns.DIFF_FORMAT_UNIFIED); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"-www\t60\tIN\tA\t10.0.0.1", "+www\t60\tIN\tA\t10.0.0.2", "+@\t3600\tIN\tTXT\tv=spf1 mx -all"} {
+		if !strings.Contains(unified.String(), line+"\n") {
+			t.Errorf("Missing line %q in:\n%s", line, unified.String())
+		}
+	}
+
+	var jsonOutput bytes.Buffer
+	if err := diff.Render(&jsonOutput, hetzner_dns.DIFF_FORMAT_JSON); err != nil {
+		t.Fatal(err)
+	}
+	decoded := hetzner_dns.ZoneDiff{}
+	if err := json.Unmarshal(jsonOutput.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Entries) != 3 {
+		t.Error("Wrong # of entries in JSON output")
+	}
+
+	var markdown bytes.Buffer
+	if err := diff.Render(&markdown, hetzner_dns.DIFF_FORMAT_MARKDOWN); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(markdown.String(), "| ~ | `@` | TXT | `v=spf1 mx -all` | 300 → 3600 |") {
+		t.Errorf("Wrong markdown output:\n%s", markdown.String())
+	}
+}
+
+func TestDiffRenderValues(t *testing.T) {
+	from := []hetzner_dns.Record{{Type: "TXT", Name: "@", Value: "a `b` c|d", TTL: 300}}
+	to := []hetzner_dns.Record{{Type: "TXT", Name: "@", Value: "a `b` c|d"}}
+	diff := hetzner_dns.DiffRecords("example.com", from, to)
+
+	// a TTL change to the zone default is kept in the JSON output
+	var jsonOutput bytes.Buffer
+	if err := diff.Render(&jsonOutput, hetzner_dns.DIFF_FORMAT_JSON); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(jsonOutput.String(), `"new_ttl": 0`) {
+		t.Errorf("Wrong JSON output:\n%s", jsonOutput.String())
+	}
+
+	// added and removed records only have the TTL of their side
+	jsonOutput.Reset()
+	added := hetzner_dns.DiffRecords("example.com", nil, []hetzner_dns.Record{{Type: "A", Name: "www", Value: "10.0.0.1"}})
+	if err := added.Render(&jsonOutput, hetzner_dns.DIFF_FORMAT_JSON); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(jsonOutput.String(), `"new_ttl": 0`) || strings.Contains(jsonOutput.String(), "old_ttl") {
+		t.Errorf("Wrong JSON output:\n%s", jsonOutput.String())
+	}
+
+	// backticks are kept in values
+	var markdown bytes.Buffer
+	if err := diff.Render(&markdown, hetzner_dns.DIFF_FORMAT_MARKDOWN); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(markdown.String(), "| ~ | `@` | TXT | ``a `b` c\\|d`` | 300 → default |") {
+		t.Errorf("Wrong markdown output:\n%s", markdown.String())
+	}
+}
@@ -0,0 +1,224 @@
+package hetzner_dns
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DryRunRequest is a request that would have been sent by a client in
+// dry-run mode.
+type DryRunRequest struct {
+	Time      time.Time       `json:"time"`
+	Operation string          `json:"operation"`
+	Method    string          `json:"method"`
+	Endpoint  string          `json:"endpoint"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+}
+
+func (request *DryRunRequest) String() string {
+	if request.Payload == nil {
+		return fmt.Sprintf("%s %s", request.Method, request.Endpoint)
+	}
+	return fmt.Sprintf("%s %s %s", request.Method, request.Endpoint, request.Payload)
+}
+
+// DryRun collects the mutating requests of a client in dry-run mode.
+type DryRun struct {
+	mu       sync.Mutex
+	requests []DryRunRequest
+	nextID   int
+	// created are the IDs synthesized for zones and records created in
+	// dry-run mode, which don't exist in the API.
+	created map[string]bool
+}
+
+// NewDryRun creates an empty dry-run log.
+func NewDryRun() *DryRun {
+	return &DryRun{created: map[string]bool{}}
+}
+
+// Requests returns the requests that would have been sent, in order.
+func (dryRun *DryRun) Requests() []DryRunRequest {
+	dryRun.mu.Lock()
+	defer dryRun.mu.Unlock()
+	return append([]DryRunRequest(nil), dryRun.requests...)
+}
+
+// String returns the requests that would have been sent, one per line.
+func (dryRun *DryRun) String() string {
+	var sb strings.Builder
+	for _, request := range dryRun.Requests() {
+		sb.WriteString(request.String())
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func (dryRun *DryRun) record(op *Operation) {
+	var payload json.RawMessage
+	if op.Request != nil {
+		payload, _ = json.Marshal(op.Request)
+	}
+	dryRun.mu.Lock()
+	defer dryRun.mu.Unlock()
+	dryRun.requests = append(dryRun.requests, DryRunRequest{
+		Time:      time.Now(),
+		Operation: op.Name,
+		Method:    op.Method,
+		Endpoint:  op.Endpoint,
+		Payload:   payload,
+	})
+}
+
+func (dryRun *DryRun) newID() string {
+	dryRun.mu.Lock()
+	defer dryRun.mu.Unlock()
+	dryRun.nextID++
+	id := fmt.Sprintf("dry-run-%d", dryRun.nextID)
+	if dryRun.created == nil {
+		dryRun.created = map[string]bool{}
+	}
+	dryRun.created[id] = true
+	return id
+}
+
+func (dryRun *DryRun) isCreated(id string) bool {
+	dryRun.mu.Lock()
+	defer dryRun.mu.Unlock()
+	return dryRun.created[id]
+}
+
+// fetchLive gets the zone or record with the given ID at endpoint into
+// response, to validate a dry-run operation as the API would. Zones and
+// records created in dry-run mode are taken as existing.
+func (client *Client) fetchLive(ctx context.Context, endpoint string, id string, response interface{}) error {
+	if client.DryRun.isCreated(id) {
+		return nil
+	}
+	return client.Perform(ctx, http.MethodGet, endpoint, nil, nil, response)
+}
+
+// validateDryRunRecord checks the record of a single record operation as the
+// API would, returning an unprocessable entity APIError for missing fields
+// and the not found APIError of an unknown zone.
+func (client *Client) validateDryRunRecord(ctx context.Context, op *Operation, request *RecordRequest) error {
+	if message := invalidRecordMessage(request); message != "" {
+		return &APIError{Method: op.Method, Endpoint: op.Endpoint, StatusCode: http.StatusUnprocessableEntity, Message: message}
+	}
+	return client.fetchLive(ctx, "/zones/"+request.ZoneID, request.ZoneID, &ZoneResponse{})
+}
+
+// invalidRecordMessage returns why the API would reject request, if it
+// would.
+func invalidRecordMessage(request *RecordRequest) string {
+	switch {
+	case request.Type == "":
+		return "missing record type"
+	case request.Value == "":
+		return "missing record value"
+	}
+	return ""
+}
+
+// performDryRun validates a mutating operation, fetching the zone or record
+// it targets, then logs it and synthesizes its response instead of sending
+// it.
+func (client *Client) performDryRun(ctx context.Context, op *Operation) error {
+	dryRun := client.DryRun
+	now := HetznerTime(time.Now())
+
+	switch op.Name {
+	case OP_CREATE_RECORD:
+		request := op.Request.(*RecordRequest)
+		if err := client.validateDryRunRecord(ctx, op, request); err != nil {
+			return err
+		}
+		record := request.Record()
+		record.ID, record.Created, record.Modified = dryRun.newID(), now, now
+		op.Response.(*RecordResponse).Record = record
+	case OP_UPDATE_RECORD:
+		request := op.Request.(*RecordRequest)
+		if err := client.validateDryRunRecord(ctx, op, request); err != nil {
+			return err
+		}
+		current := RecordResponse{}
+		if err := client.fetchLive(ctx, op.Endpoint, op.ID, &current); err != nil {
+			return err
+		}
+		record := request.Record()
+		record.Created, record.Modified = current.Record.Created, now
+		op.Response.(*RecordResponse).Record = record
+	case OP_DELETE_RECORD:
+		if err := client.fetchLive(ctx, op.Endpoint, op.ID, &RecordResponse{}); err != nil {
+			return err
+		}
+	case OP_BULK_CREATE_RECORDS, OP_BULK_UPDATE_RECORDS:
+		// records without type or value, or of unknown zones, are invalid,
+		// updates of unknown records fail, as reported by the API
+		bulkRecordResponse := op.Response.(*BulkRecordResponse)
+		zones := map[string]error{}
+		for _, request := range op.Request.(*BulkRecordRequest).Records {
+			if invalidRecordMessage(&request) != "" {
+				bulkRecordResponse.InvalidRecords = append(bulkRecordResponse.InvalidRecords, request)
+				continue
+			}
+			zoneErr, ok := zones[request.ZoneID]
+			if !ok {
+				zoneErr = client.fetchLive(ctx, "/zones/"+request.ZoneID, request.ZoneID, &ZoneResponse{})
+				zones[request.ZoneID] = zoneErr
+			}
+			if zoneErr != nil {
+				if !isNotFound(zoneErr) {
+					return zoneErr
+				}
+				bulkRecordResponse.InvalidRecords = append(bulkRecordResponse.InvalidRecords, request)
+				continue
+			}
+			record := request.Record()
+			if op.Name == OP_BULK_CREATE_RECORDS {
+				record.ID, record.Created = dryRun.newID(), now
+			} else {
+				current := RecordResponse{}
+				if err := client.fetchLive(ctx, "/records/"+request.ID, request.ID, &current); err != nil {
+					if !isNotFound(err) {
+						return err
+					}
+					bulkRecordResponse.FailedRecords = append(bulkRecordResponse.FailedRecords, request)
+					continue
+				}
+				record.Created = current.Record.Created
+			}
+			record.Modified = now
+			bulkRecordResponse.Records = append(bulkRecordResponse.Records, record)
+			bulkRecordResponse.ValidRecords = append(bulkRecordResponse.ValidRecords, request)
+		}
+	case OP_CREATE_ZONE:
+		request := op.Request.(*ZoneRequest)
+		op.Response.(*ZoneResponse).Zone = Zone{
+			ID:       dryRun.newID(),
+			Name:     request.Name,
+			TTL:      request.TTL,
+			Created:  now,
+			Modified: now,
+		}
+	case OP_UPDATE_ZONE:
+		zoneResponse := op.Response.(*ZoneResponse)
+		if err := client.fetchLive(ctx, op.Endpoint, op.ID, zoneResponse); err != nil {
+			return err
+		}
+		request := op.Request.(*ZoneRequest)
+		zoneResponse.Zone.Name, zoneResponse.Zone.TTL, zoneResponse.Zone.Modified = request.Name, request.TTL, now
+	case OP_DELETE_ZONE:
+		if err := client.fetchLive(ctx, op.Endpoint, op.ID, &ZoneResponse{}); err != nil {
+			return err
+		}
+	}
+
+	dryRun.record(op)
+	return nil
+}
@@ -0,0 +1,136 @@
+package hetzner_dns_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+	"github.com/panta/go-hetzner-dns/internal/fakeapi"
+)
+
+func TestDryRun(t *testing.T) {
+	ctx := context.Background()
+	api := fakeapi.New(t)
+	defer api.Close()
+	api.AddZone("zone-1", "example.com")
+	www := api.AddRecord("zone-1", "www", "A", "127.0.0.1")
+
+	client := api.Client()
+	client.DryRun = hetzner_dns.NewDryRun()
+
+	created, err := client.CreateRecord(ctx, hetzner_dns.RecordRequest{ZoneID: "zone-1", Name: "mail", Type: "A", Value: "127.0.0.2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Record.ID == "" || created.Record.Name != "mail" {
+		t.Errorf("unexpected synthesized record %+v", created.Record)
+	}
+	updated, err := client.CreateOrUpdateRecord(ctx, hetzner_dns.RecordRequest{ZoneID: "zone-1", Name: "www", Type: "A", Value: "127.0.0.3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Record.ID != www || updated.Record.Value != "127.0.0.3" {
+		t.Errorf("unexpected synthesized record %+v", updated.Record)
+	}
+	if err := client.DeleteRecord(ctx, www); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.BulkCreateRecords(ctx, &hetzner_dns.BulkRecordRequest{Records: []hetzner_dns.RecordRequest{
+		{ZoneID: "zone-1", Name: "a", Type: "A", Value: "127.0.0.4"},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// validation still happens against the live API
+	err = client.DeleteRecord(ctx, "missing")
+	var apiError *hetzner_dns.APIError
+	if !errors.As(err, &apiError) || !apiError.NotFound() {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+
+	if count := api.CallCount("POST") + api.CallCount("PUT") + api.CallCount("DELETE"); count != 0 {
+		t.Errorf("expected no mutating request, got %d", count)
+	}
+	if records := api.ZoneRecords("zone-1"); len(records) != 1 || records[0].Value != "127.0.0.1" {
+		t.Errorf("expected the zone to be unchanged, got %+v", records)
+	}
+
+	requests := client.DryRun.Requests()
+	operations := []string{}
+	for _, request := range requests {
+		operations = append(operations, request.Operation)
+	}
+	expected := "CreateRecord UpdateRecord DeleteRecord BulkCreateRecords"
+	if strings.Join(operations, " ") != expected {
+		t.Errorf("expected %s, got %v", expected, operations)
+	}
+	if log := client.DryRun.String(); !strings.Contains(log, `PUT /records/`+www+` {"id":"`+www+`"`) {
+		t.Errorf("unexpected log:\n%s", log)
+	}
+}
+
+func TestDryRunValidation(t *testing.T) {
+	ctx := context.Background()
+	api := fakeapi.New(t)
+	defer api.Close()
+	api.AddZone("zone-1", "example.com")
+	www := api.AddRecord("zone-1", "www", "A", "127.0.0.1")
+
+	client := api.Client()
+	client.DryRun = hetzner_dns.NewDryRun()
+
+	_, err := client.CreateRecord(ctx, hetzner_dns.RecordRequest{ZoneID: "missing", Name: "mail", Type: "A", Value: "127.0.0.2"})
+	var apiError *hetzner_dns.APIError
+	if !errors.As(err, &apiError) || !apiError.NotFound() {
+		t.Errorf("expected a not found error for an unknown zone, got %v", err)
+	}
+
+	response, err := client.BulkUpdateRecords(ctx, &hetzner_dns.BulkRecordRequest{Records: []hetzner_dns.RecordRequest{
+		{ID: www, ZoneID: "zone-1", Name: "www", Type: "A", Value: "127.0.0.3"},
+		{ID: "missing", ZoneID: "zone-1", Name: "api", Type: "A", Value: "127.0.0.4"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Records) != 1 || len(response.FailedRecords) != 1 || response.FailedRecords[0].ID != "missing" {
+		t.Errorf("expected the unknown record to fail, got %+v", response)
+	}
+	response, err = client.BulkCreateRecords(ctx, &hetzner_dns.BulkRecordRequest{Records: []hetzner_dns.RecordRequest{
+		{ZoneID: "missing", Name: "api", Type: "A", Value: "127.0.0.4"},
+	}})
+	if err != nil || len(response.Records) != 0 || len(response.InvalidRecords) != 1 {
+		t.Errorf("expected the record of an unknown zone to be invalid, got %+v (%v)", response, err)
+	}
+
+	response, err = client.BulkCreateRecords(ctx, &hetzner_dns.BulkRecordRequest{Records: []hetzner_dns.RecordRequest{
+		{ZoneID: "zone-1", Name: "api", Type: "A"},
+		{ZoneID: "zone-1", Name: "api", Type: "A", Value: "127.0.0.4"},
+	}})
+	if err != nil || len(response.Records) != 1 || len(response.InvalidRecords) != 1 || response.InvalidRecords[0].Value != "" {
+		t.Errorf("expected the record without value to be invalid, got %+v (%v)", response, err)
+	}
+	_, err = client.CreateRecord(ctx, hetzner_dns.RecordRequest{ZoneID: "zone-1", Name: "mail", Type: "A"})
+	if !errors.As(err, &apiError) || apiError.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("expected an error for a record without value, got %v", err)
+	}
+	_, err = client.UpdateRecord(ctx, hetzner_dns.RecordRequest{ID: www, ZoneID: "missing", Name: "www", Type: "A", Value: "127.0.0.3"})
+	if !errors.As(err, &apiError) || !apiError.NotFound() {
+		t.Errorf("expected a not found error for an update to an unknown zone, got %v", err)
+	}
+
+	// zones created in dry-run mode exist for the following operations
+	zone, err := client.CreateZone(ctx, hetzner_dns.ZoneRequest{Name: "example.org"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CreateRecord(ctx, hetzner_dns.RecordRequest{ZoneID: zone.Zone.ID, Name: "www", Type: "A", Value: "127.0.0.5"}); err != nil {
+		t.Errorf("expected the record of a dry-run zone to be created, got %v", err)
+	}
+	if count := api.CallCount("POST") + api.CallCount("PUT") + api.CallCount("DELETE"); count != 0 {
+		t.Errorf("expected no mutating request, got %d", count)
+	}
+}
@@ -0,0 +1,33 @@
+package hetzner_dns
+
+import "strings"
+
+var (
+	endpointResources = map[string]bool{"zones": true, "records": true, "primary_servers": true}
+	endpointActions   = map[string]bool{"bulk": true, "validate": true, "import": true, "export": true, "file": true}
+)
+
+// EndpointTemplate returns the API endpoint of a request path with resource
+// IDs replaced by placeholders, e.g. "/records/{id}" for
+// "/api/v1/records/abc123", suitable as a low-cardinality label. Anything
+// before the first resource name (such as the base URL path) is dropped.
+func EndpointTemplate(path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	start := -1
+	for i, segment := range segments {
+		if endpointResources[segment] {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return path
+	}
+	segments = segments[start:]
+	for i := 1; i < len(segments); i++ {
+		if endpointResources[segments[i-1]] && !endpointActions[segments[i]] {
+			segments[i] = "{id}"
+		}
+	}
+	return "/" + strings.Join(segments, "/")
+}
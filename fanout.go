@@ -0,0 +1,148 @@
+package hetzner_dns
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+const DEFAULT_CONCURRENCY = 8
+
+// ZoneError is the error of an operation on a zone.
+type ZoneError struct {
+	Zone Zone
+	Err  error
+}
+
+func (zoneError *ZoneError) Error() string {
+	return fmt.Sprintf("zone %s: %v", zoneError.Zone.Name, zoneError.Err)
+}
+
+func (zoneError *ZoneError) Unwrap() error {
+	return zoneError.Err
+}
+
+// ZoneErrors collects the errors of an operation run across zones.
+type ZoneErrors []*ZoneError
+
+func (zoneErrors ZoneErrors) Error() string {
+	messages := make([]string, len(zoneErrors))
+	for i, zoneError := range zoneErrors {
+		messages[i] = zoneError.Error()
+	}
+	return fmt.Sprintf("%d zones failed: %s", len(zoneErrors), strings.Join(messages, "; "))
+}
+
+type rateLimiterKey struct{}
+
+// WithRateLimiter returns a context whose API calls wait for limiter, which
+// can be shared by concurrent callers to respect the API rate limits.
+func WithRateLimiter(ctx context.Context, limiter *rate.Limiter) context.Context {
+	return context.WithValue(ctx, rateLimiterKey{}, limiter)
+}
+
+func rateLimiter(ctx context.Context) *rate.Limiter {
+	limiter, _ := ctx.Value(rateLimiterKey{}).(*rate.Limiter)
+	return limiter
+}
+
+// FanOut runs an operation on many zones concurrently.
+type FanOut struct {
+	// Concurrency is the number of zones processed at the same time
+	// (DEFAULT_CONCURRENCY if zero).
+	Concurrency int
+	// Limiter, if set, is shared by the API calls of all the workers.
+	Limiter *rate.Limiter
+	// Progress, if set, is called after each zone with the number of zones
+	// done so far and the error of the zone, if any. Calls are serialized.
+	Progress func(done int, total int, zone Zone, err error)
+}
+
+// NewFanOut creates a FanOut with the given concurrency and rate limit, in
+// API calls per second (unlimited if zero).
+func NewFanOut(concurrency int, callsPerSecond float64) *FanOut {
+	fanOut := &FanOut{Concurrency: concurrency}
+	if callsPerSecond > 0 {
+		fanOut.Limiter = rate.NewLimiter(rate.Limit(callsPerSecond), 1)
+	}
+	return fanOut
+}
+
+// Run calls fn for each zone using a bounded pool of workers. The errors of
+// the zones are collected in a ZoneErrors. When ctx is cancelled, the zones
+// not started yet are skipped and ctx.Err() is returned.
+func (fanOut *FanOut) Run(ctx context.Context, zones []Zone, fn func(ctx context.Context, zone Zone) error) error {
+	concurrency := fanOut.Concurrency
+	if concurrency <= 0 {
+		concurrency = DEFAULT_CONCURRENCY
+	}
+	if fanOut.Limiter != nil {
+		ctx = WithRateLimiter(ctx, fanOut.Limiter)
+	}
+
+	var mu sync.Mutex
+	var zoneErrors ZoneErrors
+	done := 0
+	finish := func(zone Zone, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		done++
+		if err != nil {
+			zoneErrors = append(zoneErrors, &ZoneError{Zone: zone, Err: err})
+		}
+		if fanOut.Progress != nil {
+			fanOut.Progress(done, len(zones), zone, err)
+		}
+	}
+
+	work := make(chan Zone)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency && i < len(zones); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for zone := range work {
+				finish(zone, fn(ctx, zone))
+			}
+		}()
+	}
+feed:
+	for _, zone := range zones {
+		select {
+		case work <- zone:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if len(zoneErrors) > 0 {
+		return zoneErrors
+	}
+	return nil
+}
+
+// GetAllRecordsByZone returns the records of zones by zone ID, fetching the
+// zones concurrently. The records of the zones which failed are missing.
+func (fanOut *FanOut) GetAllRecordsByZone(ctx context.Context, client *Client, zones []Zone) (map[string][]Record, error) {
+	var mu sync.Mutex
+	records := make(map[string][]Record, len(zones))
+	err := fanOut.Run(ctx, zones, func(ctx context.Context, zone Zone) error {
+		zoneRecords, err := client.GetAllRecords(ctx, zone.ID)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		records[zone.ID] = zoneRecords
+		mu.Unlock()
+		return nil
+	})
+	return records, err
+}
@@ -0,0 +1,110 @@
+package hetzner_dns_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+	"github.com/panta/go-hetzner-dns/internal/fakeapi"
+)
+
+func TestFanOut(t *testing.T) {
+	var zones []hetzner_dns.Zone
+	for i := 0; i < 20; i++ {
+		zones = append(zones, hetzner_dns.Zone{ID: fmt.Sprintf("zone-%d", i), Name: fmt.Sprintf("example%d.com", i)})
+	}
+
+	var mu sync.Mutex
+	running, maxRunning, progress := 0, 0, 0
+	fanOut := hetzner_dns.NewFanOut(3, 0)
+	fanOut.Progress = func(done int, total int, zone hetzner_dns.Zone, err error) {
+		progress++
+		if done != progress || total != len(zones) {
+			t.Errorf("unexpected progress %d/%d", done, total)
+		}
+	}
+	failure := errors.New("failure")
+	err := fanOut.Run(context.Background(), zones, func(ctx context.Context, zone hetzner_dns.Zone) error {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		if zone.ID == "zone-4" || zone.ID == "zone-7" {
+			return failure
+		}
+		return nil
+	})
+
+	if maxRunning > 3 {
+		t.Errorf("expected at most 3 concurrent zones, got %d", maxRunning)
+	}
+	if progress != len(zones) {
+		t.Errorf("expected progress for %d zones, got %d", len(zones), progress)
+	}
+	zoneErrors, ok := err.(hetzner_dns.ZoneErrors)
+	if !ok || len(zoneErrors) != 2 {
+		t.Fatalf("expected 2 zone errors, got %v", err)
+	}
+	if !errors.Is(zoneErrors[0], failure) {
+		t.Errorf("expected the zone error to wrap the failure, got %v", zoneErrors[0])
+	}
+}
+
+func TestFanOutCancel(t *testing.T) {
+	zones := make([]hetzner_dns.Zone, 100)
+	ctx, cancel := context.WithCancel(context.Background())
+	started := 0
+	fanOut := hetzner_dns.NewFanOut(1, 0)
+	err := fanOut.Run(ctx, zones, func(ctx context.Context, zone hetzner_dns.Zone) error {
+		started++
+		if started == 5 {
+			cancel()
+		}
+		return nil
+	})
+	if err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if started > 6 {
+		t.Errorf("expected the remaining zones to be skipped, %d started", started)
+	}
+}
+
+func TestFanOutRecords(t *testing.T) {
+	api := fakeapi.New(t)
+	defer api.Close()
+	for i := 0; i < 5; i++ {
+		zoneId := fmt.Sprintf("zone-%d", i)
+		api.AddZone(zoneId, fmt.Sprintf("example%d.com", i))
+		for j := 0; j <= i; j++ {
+			api.AddRecord(zoneId, fmt.Sprintf("host%d", j), "A", "127.0.0.1")
+		}
+	}
+	client := api.Client()
+	zones, err := client.GetAllZones(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fanOut := hetzner_dns.NewFanOut(4, 1000)
+	records, err := fanOut.GetAllRecordsByZone(context.Background(), client, zones)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if count := len(records[fmt.Sprintf("zone-%d", i)]); count != i+1 {
+			t.Errorf("zone-%d: expected %d records, got %d", i, i+1, count)
+		}
+	}
+}
@@ -4,5 +4,13 @@ go 1.13
 
 require (
 	github.com/google/go-querystring v1.0.0
+	github.com/miekg/dns v1.1.41
 	github.com/pkg/errors v0.9.1
+	github.com/prometheus/client_golang v1.11.0
+	go.opentelemetry.io/otel v1.0.0
+	go.opentelemetry.io/otel/sdk v1.0.0
+	go.opentelemetry.io/otel/trace v1.0.0
+	golang.org/x/sync v0.1.0
+	golang.org/x/time v0.1.0
+	gopkg.in/yaml.v3 v3.0.1
 )
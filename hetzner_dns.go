@@ -9,7 +9,6 @@ import (
 	"log"
 	"net/http"
 	"net/http/httputil"
-	"os"
 	"time"
 
 	"github.com/google/go-querystring/query"
@@ -17,13 +16,15 @@ import (
 )
 
 const (
-	BASE_URL        = "https://dns.hetzner.com/api/v1"
-	DEFAULT_TIMEOUT = time.Second * 30
+	BASE_URL         = "https://dns.hetzner.com/api/v1"
+	DEFAULT_TIMEOUT  = time.Second * 30
+	DEFAULT_PER_PAGE = 100
 )
 
 var (
 	ErrAPIKeyNotSet = errors.New("hetzner_dns: API key has not been set")
 	ErrMissingID    = errors.New("hetzner_dns: missing record ID")
+	ErrMissingZone  = errors.New("hetzner_dns: missing zone ID")
 )
 
 // Client is the API service client structure.
@@ -32,7 +33,20 @@ type Client struct {
 	ApiKey  string
 	Debug   bool
 
+	// TokenProvider provides the API token when ApiKey is not set
+	// (DefaultTokenProvider, reading HETZNER_API_KEY, if nil).
+	TokenProvider TokenProvider
+
 	HttpClient *http.Client
+
+	// DryRun, if set, enables the dry-run mode: mutating operations are
+	// validated and logged in DryRun, and return synthesized responses
+	// without being sent. Reads are performed normally.
+	DryRun *DryRun
+
+	// Middlewares wrap the logical operations performed by the client
+	// methods, outermost first (see Use).
+	Middlewares []Middleware
 }
 
 func (client *Client) Perform(ctx context.Context, method string, endpoint string, queryParams, bodyParams, v interface{}) error {
@@ -72,7 +86,14 @@ func (client *Client) Perform(ctx context.Context, method string, endpoint strin
 	// Headers
 	apiKey := client.ApiKey
 	if apiKey == "" {
-		apiKey = os.Getenv("HETZNER_API_KEY")
+		tokenProvider := client.TokenProvider
+		if tokenProvider == nil {
+			tokenProvider = DefaultTokenProvider
+		}
+		apiKey, err = tokenProvider.Token(ctx)
+		if err != nil {
+			return errors.Wrap(err, "can't get API token")
+		}
 	}
 	if apiKey == "" {
 		return ErrAPIKeyNotSet
@@ -104,7 +125,7 @@ func (client *Client) Perform(ctx context.Context, method string, endpoint strin
 	}
 
 	if !isHttpSuccess(resp.StatusCode) {
-		return errors.Wrap(err, "can't read body")
+		return newAPIError(method, endpoint, resp.StatusCode, respBody)
 	}
 
 	if v != nil {
@@ -118,7 +139,7 @@ func (client *Client) Perform(ctx context.Context, method string, endpoint strin
 
 func (client *Client) GetZones(ctx context.Context, name string, searchName string, page int, perPage int) (*ZonesResponse, error) {
 	zonesResponse := ZonesResponse{}
-	err := client.Perform(ctx, http.MethodGet, "/zones", struct {
+	err := client.do(ctx, &Operation{Name: OP_GET_ZONES, Method: http.MethodGet, Endpoint: "/zones", Query: struct {
 		Name       string `url:"string"`
 		Page       int    `url:"page"`
 		PerPage    int    `url:"per_page"`
@@ -128,10 +149,60 @@ func (client *Client) GetZones(ctx context.Context, name string, searchName stri
 		SearchName: searchName,
 		Page:       page,
 		PerPage:    perPage,
-	}, nil, &zonesResponse)
+	}, Response: &zonesResponse})
 	return &zonesResponse, err
 }
 
+func (client *Client) GetZone(ctx context.Context, zoneId string) (*ZoneResponse, error) {
+	if zoneId == "" {
+		return nil, ErrMissingZone
+	}
+	zoneResponse := ZoneResponse{}
+	endpoint := fmt.Sprintf("/zones/%v", zoneId)
+	err := client.do(ctx, &Operation{Name: OP_GET_ZONE, Method: http.MethodGet, Endpoint: endpoint, ID: zoneId, Response: &zoneResponse})
+	return &zoneResponse, err
+}
+
+func (client *Client) CreateZone(ctx context.Context, zone ZoneRequest) (*ZoneResponse, error) {
+	zoneResponse := ZoneResponse{}
+	err := client.do(ctx, &Operation{Name: OP_CREATE_ZONE, Method: http.MethodPost, Endpoint: "/zones", Request: &zone, Response: &zoneResponse})
+	return &zoneResponse, err
+}
+
+func (client *Client) UpdateZone(ctx context.Context, zoneId string, zone ZoneRequest) (*ZoneResponse, error) {
+	if zoneId == "" {
+		return nil, ErrMissingZone
+	}
+	zoneResponse := ZoneResponse{}
+	endpoint := fmt.Sprintf("/zones/%v", zoneId)
+	err := client.do(ctx, &Operation{Name: OP_UPDATE_ZONE, Method: http.MethodPut, Endpoint: endpoint, ID: zoneId, Request: &zone, Response: &zoneResponse})
+	return &zoneResponse, err
+}
+
+func (client *Client) DeleteZone(ctx context.Context, zoneId string) error {
+	if zoneId == "" {
+		return ErrMissingZone
+	}
+	endpoint := fmt.Sprintf("/zones/%v", zoneId)
+	return client.do(ctx, &Operation{Name: OP_DELETE_ZONE, Method: http.MethodDelete, Endpoint: endpoint, ID: zoneId})
+}
+
+// GetAllZones returns all the zones of the account, following pagination.
+func (client *Client) GetAllZones(ctx context.Context) ([]Zone, error) {
+	var zones []Zone
+	for page := 1; ; page++ {
+		zonesResponse, err := client.GetZones(ctx, "", "", page, DEFAULT_PER_PAGE)
+		if err != nil {
+			return nil, err
+		}
+		zones = append(zones, zonesResponse.Zones...)
+		if isLastPage(zonesResponse.Meta.Pagination, page, len(zonesResponse.Zones)) {
+			break
+		}
+	}
+	return zones, nil
+}
+
 func (client *Client) GetRecords(ctx context.Context, zone_id string, page int, perPage int) (*RecordsResponse, error) {
 	recordsResponse := RecordsResponse{}
 	var params interface{}
@@ -154,13 +225,29 @@ func (client *Client) GetRecords(ctx context.Context, zone_id string, page int,
 			ZoneId: zone_id,
 		}
 	}
-	err := client.Perform(ctx, http.MethodGet, "/records", params, nil, &recordsResponse)
+	err := client.do(ctx, &Operation{Name: OP_GET_RECORDS, Method: http.MethodGet, Endpoint: "/records", ID: zone_id, Query: params, Response: &recordsResponse})
 	return &recordsResponse, err
 }
 
+// GetAllRecords returns all the records of a zone, following pagination.
+func (client *Client) GetAllRecords(ctx context.Context, zoneId string) ([]Record, error) {
+	var records []Record
+	for page := 1; ; page++ {
+		recordsResponse, err := client.GetRecords(ctx, zoneId, page, DEFAULT_PER_PAGE)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, recordsResponse.Records...)
+		if isLastPage(recordsResponse.Meta.Pagination, page, len(recordsResponse.Records)) {
+			break
+		}
+	}
+	return records, nil
+}
+
 func (client *Client) CreateRecord(ctx context.Context, record RecordRequest) (*RecordResponse, error) {
 	recordResponse := RecordResponse{}
-	err := client.Perform(ctx, http.MethodPost, "/records", nil, &record, &recordResponse)
+	err := client.do(ctx, &Operation{Name: OP_CREATE_RECORD, Method: http.MethodPost, Endpoint: "/records", Request: &record, Response: &recordResponse})
 	return &recordResponse, err
 }
 
@@ -170,7 +257,7 @@ func (client *Client) GetRecord(ctx context.Context, recordId string) (*RecordRe
 	}
 	recordResponse := RecordResponse{}
 	endpoint := fmt.Sprintf("/records/%v", recordId)
-	err := client.Perform(ctx, http.MethodGet, endpoint, nil, nil, &recordResponse)
+	err := client.do(ctx, &Operation{Name: OP_GET_RECORD, Method: http.MethodGet, Endpoint: endpoint, ID: recordId, Response: &recordResponse})
 	return &recordResponse, err
 }
 
@@ -180,7 +267,7 @@ func (client *Client) UpdateRecord(ctx context.Context, record RecordRequest) (*
 	}
 	recordResponse := RecordResponse{}
 	endpoint := fmt.Sprintf("/records/%v", record.ID)
-	err := client.Perform(ctx, http.MethodPut, endpoint, nil, &record, &recordResponse)
+	err := client.do(ctx, &Operation{Name: OP_UPDATE_RECORD, Method: http.MethodPut, Endpoint: endpoint, ID: record.ID, Request: &record, Response: &recordResponse})
 	return &recordResponse, err
 }
 
@@ -216,21 +303,30 @@ func (client *Client) DeleteRecord(ctx context.Context, recordId string) error {
 		return ErrMissingID
 	}
 	endpoint := fmt.Sprintf("/records/%v", recordId)
-	return client.Perform(ctx, http.MethodDelete, endpoint, nil, nil, nil)
+	return client.do(ctx, &Operation{Name: OP_DELETE_RECORD, Method: http.MethodDelete, Endpoint: endpoint, ID: recordId})
 }
 
 func (client *Client) BulkCreateRecords(ctx context.Context, bulkRecordsRequest *BulkRecordRequest) (*BulkRecordResponse, error) {
 	bulkRecordResponse := BulkRecordResponse{}
-	err := client.Perform(ctx, http.MethodPost, "/records/bulk", nil, bulkRecordsRequest, &bulkRecordResponse)
+	err := client.do(ctx, &Operation{Name: OP_BULK_CREATE_RECORDS, Method: http.MethodPost, Endpoint: "/records/bulk", Request: bulkRecordsRequest, Response: &bulkRecordResponse})
 	return &bulkRecordResponse, err
 }
 
 func (client *Client) BulkUpdateRecords(ctx context.Context, bulkRecordsRequest *BulkRecordRequest) (*BulkRecordResponse, error) {
 	bulkRecordResponse := BulkRecordResponse{}
-	err := client.Perform(ctx, http.MethodPut, "/records/bulk", nil, bulkRecordsRequest, &bulkRecordResponse)
+	err := client.do(ctx, &Operation{Name: OP_BULK_UPDATE_RECORDS, Method: http.MethodPut, Endpoint: "/records/bulk", Request: bulkRecordsRequest, Response: &bulkRecordResponse})
 	return &bulkRecordResponse, err
 }
 
+// isLastPage returns true if page is the last one, using pagination metadata
+// when available and the number of returned items otherwise.
+func isLastPage(pagination Pagination, page int, count int) bool {
+	if pagination.LastPage > 0 {
+		return page >= pagination.LastPage
+	}
+	return count < DEFAULT_PER_PAGE
+}
+
 // // isHttpInformational returns true if HTTP status code is 1xx.
 // func isHttpInformational(code int) bool {
 // 	return (code >= 100) && (code <= 199)
@@ -604,3 +604,17 @@ func TestClient_BulkUpdateRecords(t *testing.T) {
 	}
 	fmt.Println(bulkRecordsResponse)
 }
+
+func TestEndpointTemplate(t *testing.T) {
+	for path, expected := range map[string]string{
+		"/api/v1/records":             "/records",
+		"/api/v1/records/abc123":      "/records/{id}",
+		"/records/bulk":               "/records/bulk",
+		"/zones/zone-1/export":        "/zones/{id}/export",
+		"/api/v1/zones/file/validate": "/zones/file/validate",
+	} {
+		if template := hetzner_dns.EndpointTemplate(path); template != expected {
+			t.Errorf("EndpointTemplate(%q) = %q, expected %q", path, template, expected)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+// Package fakeapi is a minimal stateful implementation of the Hetzner DNS
+// API, to test the packages of this module against.
+package fakeapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+)
+
+// DEFAULT_TTL is the TTL of the zones added without one.
+const DEFAULT_TTL = 86400
+
+// API is a fake of the zones and records endpoints. Bulk requests reject
+// records with an empty value as invalid, and updates of unknown records as
+// failed, like the API does.
+type API struct {
+	// Drop makes bulk creations silently lose the records it returns true
+	// for, e.g. to test the verification of migrations.
+	Drop func(request hetzner_dns.RecordRequest) bool
+	// IDPrefix prefixes the IDs of the records ("rec-" if empty), e.g. to
+	// keep them unique across several fakes.
+	IDPrefix string
+	// Before is called with each request before handling it, e.g. to hold it
+	// until the test is ready.
+	Before func(req *http.Request)
+
+	t       testing.TB
+	mu      sync.Mutex
+	server  *httptest.Server
+	zones   []hetzner_dns.Zone
+	records []hetzner_dns.Record
+	nextID  int
+	calls   []string // "METHOD /path"
+}
+
+// New starts a fake API, to be closed with Close.
+func New(t testing.TB) *API {
+	api := &API{t: t}
+	api.server = httptest.NewServer(http.HandlerFunc(api.handle))
+	return api
+}
+
+// Close shuts the server down.
+func (api *API) Close() {
+	api.server.Close()
+}
+
+// URL returns the base URL of the API.
+func (api *API) URL() string {
+	return api.server.URL
+}
+
+// Client returns a client of the API.
+func (api *API) Client() *hetzner_dns.Client {
+	return &hetzner_dns.Client{BaseURL: api.server.URL, ApiKey: "dummy"}
+}
+
+// AddZone adds a zone with the default TTL and no records.
+func (api *API) AddZone(id string, name string) {
+	api.AddZoneWithTTL(id, name, DEFAULT_TTL)
+}
+
+// AddZoneWithTTL adds a zone with no records.
+func (api *API) AddZoneWithTTL(id string, name string, ttl int) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.zones = append(api.zones, hetzner_dns.Zone{ID: id, Name: name, TTL: ttl, Modified: hetzner_dns.HetznerTime(time.Now())})
+}
+
+// SetModified sets the modification time of a zone, e.g. to tell it changed
+// independently of the clock resolution.
+func (api *API) SetModified(zoneId string, modified time.Time) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	for i := range api.zones {
+		if api.zones[i].ID == zoneId {
+			api.zones[i].Modified = hetzner_dns.HetznerTime(modified)
+		}
+	}
+}
+
+// AddRecord adds a record to a zone, returning its ID.
+func (api *API) AddRecord(zoneId string, name string, recordType string, value string) string {
+	return api.Insert(hetzner_dns.RecordRequest{ZoneID: zoneId, Name: name, Type: recordType, Value: value}).ID
+}
+
+// Insert adds a record, returning it with its ID (IDPrefix followed by a
+// number).
+func (api *API) Insert(request hetzner_dns.RecordRequest) hetzner_dns.Record {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	return api.insert(request)
+}
+
+// Zones returns the zones.
+func (api *API) Zones() []hetzner_dns.Zone {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	return append([]hetzner_dns.Zone(nil), api.zones...)
+}
+
+// ZoneRecords returns the records of a zone.
+func (api *API) ZoneRecords(zoneId string) []hetzner_dns.Record {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	var records []hetzner_dns.Record
+	for _, record := range api.records {
+		if record.ZoneID == zoneId {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// Calls returns the calls received, as "METHOD /path".
+func (api *API) Calls() []string {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	return append([]string(nil), api.calls...)
+}
+
+// CallCount returns the number of calls whose "METHOD /path" starts with
+// prefix.
+func (api *API) CallCount(prefix string) int {
+	count := 0
+	for _, call := range api.Calls() {
+		if strings.HasPrefix(call, prefix) {
+			count++
+		}
+	}
+	return count
+}
+
+func (api *API) insert(request hetzner_dns.RecordRequest) hetzner_dns.Record {
+	api.nextID++
+	now := hetzner_dns.HetznerTime(time.Now())
+	record := request.Record()
+	prefix := api.IDPrefix
+	if prefix == "" {
+		prefix = "rec-"
+	}
+	record.ID = fmt.Sprintf("%s%d", prefix, api.nextID)
+	record.Created, record.Modified = now, now
+	api.records = append(api.records, record)
+	api.touchZone(record.ZoneID)
+	return record
+}
+
+func (api *API) touchZone(zoneId string) {
+	for i := range api.zones {
+		if api.zones[i].ID == zoneId {
+			api.zones[i].Modified = hetzner_dns.HetznerTime(time.Now())
+			count := 0
+			for _, record := range api.records {
+				if record.ZoneID == zoneId {
+					count++
+				}
+			}
+			api.zones[i].RecordsCount = count
+		}
+	}
+}
+
+func (api *API) handle(rw http.ResponseWriter, req *http.Request) {
+	if api.Before != nil {
+		api.Before(req)
+	}
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.calls = append(api.calls, req.Method+" "+req.URL.Path)
+
+	writeJSON := func(v interface{}) {
+		rw.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(rw).Encode(v)
+	}
+	notFound := func(resource string) {
+		http.Error(rw, fmt.Sprintf(`{"error": {"message": "%s not found", "code": 404}}`, resource), http.StatusNotFound)
+	}
+	decode := func(v interface{}) bool {
+		if err := json.NewDecoder(req.Body).Decode(v); err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return false
+		}
+		return true
+	}
+	zoneIndex := func(id string) int {
+		for i, zone := range api.zones {
+			if zone.ID == id {
+				return i
+			}
+		}
+		return -1
+	}
+	recordIndex := func(id string) int {
+		for i, record := range api.records {
+			if record.ID == id {
+				return i
+			}
+		}
+		return -1
+	}
+	update := func(i int, request *hetzner_dns.RecordRequest) {
+		api.records[i].Name, api.records[i].Type = request.Name, request.Type
+		api.records[i].Value, api.records[i].TTL = request.Value, request.TTL
+		api.records[i].Modified = hetzner_dns.HetznerTime(time.Now())
+		api.touchZone(api.records[i].ZoneID)
+	}
+
+	switch {
+	case req.URL.Path == "/zones" && req.Method == http.MethodGet:
+		writeJSON(&hetzner_dns.ZonesResponse{
+			Zones: api.zones,
+			Meta:  hetzner_dns.Meta{Pagination: hetzner_dns.Pagination{Page: 1, PerPage: 100, LastPage: 1, TotalEntries: len(api.zones)}},
+		})
+	case req.URL.Path == "/zones" && req.Method == http.MethodPost:
+		// new zones have the SOA and NS records set by Hetzner
+		request := hetzner_dns.ZoneRequest{}
+		if !decode(&request) {
+			return
+		}
+		zone := hetzner_dns.Zone{ID: fmt.Sprintf("zone-%d", len(api.zones)+1), Name: request.Name, TTL: request.TTL}
+		api.zones = append(api.zones, zone)
+		api.insert(hetzner_dns.RecordRequest{ZoneID: zone.ID, Name: "@", Type: "SOA", Value: "hydrogen.ns.hetzner.com. dns.hetzner.com. 1 86400 10800 3600000 3600"})
+		api.insert(hetzner_dns.RecordRequest{ZoneID: zone.ID, Name: "@", Type: "NS", Value: "hydrogen.ns.hetzner.com."})
+		writeJSON(&hetzner_dns.ZoneResponse{Zone: api.zones[zoneIndex(zone.ID)]})
+	case strings.HasPrefix(req.URL.Path, "/zones/") && req.Method == http.MethodGet:
+		i := zoneIndex(strings.TrimPrefix(req.URL.Path, "/zones/"))
+		if i < 0 {
+			notFound("zone")
+			return
+		}
+		writeJSON(&hetzner_dns.ZoneResponse{Zone: api.zones[i]})
+	case req.URL.Path == "/records" && req.Method == http.MethodGet:
+		zoneId := req.URL.Query().Get("zone_id")
+		records := []hetzner_dns.Record{}
+		for _, record := range api.records {
+			if zoneId == "" || record.ZoneID == zoneId {
+				records = append(records, record)
+			}
+		}
+		writeJSON(&hetzner_dns.RecordsResponse{Records: records})
+	case req.URL.Path == "/records" && req.Method == http.MethodPost:
+		request := hetzner_dns.RecordRequest{}
+		if !decode(&request) {
+			return
+		}
+		writeJSON(&hetzner_dns.RecordResponse{Record: api.insert(request)})
+	case req.URL.Path == "/records/bulk":
+		bulk := hetzner_dns.BulkRecordRequest{}
+		if !decode(&bulk) {
+			return
+		}
+		response := hetzner_dns.BulkRecordResponse{}
+		for _, request := range bulk.Records {
+			if request.Value == "" {
+				response.InvalidRecords = append(response.InvalidRecords, request)
+				continue
+			}
+			if req.Method == http.MethodPost {
+				if api.Drop != nil && api.Drop(request) {
+					continue
+				}
+				response.Records = append(response.Records, api.insert(request))
+				continue
+			}
+			i := recordIndex(request.ID)
+			if i < 0 {
+				response.FailedRecords = append(response.FailedRecords, request)
+				continue
+			}
+			update(i, &request)
+			response.Records = append(response.Records, api.records[i])
+		}
+		writeJSON(&response)
+	case strings.HasPrefix(req.URL.Path, "/records/"):
+		i := recordIndex(strings.TrimPrefix(req.URL.Path, "/records/"))
+		if i < 0 {
+			notFound("record")
+			return
+		}
+		switch req.Method {
+		case http.MethodGet:
+			writeJSON(&hetzner_dns.RecordResponse{Record: api.records[i]})
+		case http.MethodPut:
+			request := hetzner_dns.RecordRequest{}
+			if !decode(&request) {
+				return
+			}
+			update(i, &request)
+			writeJSON(&hetzner_dns.RecordResponse{Record: api.records[i]})
+		case http.MethodDelete:
+			zoneId := api.records[i].ZoneID
+			api.records = append(api.records[:i], api.records[i+1:]...)
+			api.touchZone(zoneId)
+		}
+	default:
+		api.t.Errorf("fakeapi: unexpected request %s %s", req.Method, req.URL.Path)
+		http.NotFound(rw, req)
+	}
+}
@@ -0,0 +1,254 @@
+// Package lint checks zones for common mistakes and deviations from best
+// practices, with a set of pluggable rules run over the records of the zones.
+package lint
+
+import (
+	"sort"
+	"strings"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+)
+
+// Severity is the severity of a finding.
+type Severity string
+
+const (
+	SEVERITY_INFO    Severity = "info"
+	SEVERITY_WARNING Severity = "warning"
+	SEVERITY_ERROR   Severity = "error"
+)
+
+var severityLevels = map[Severity]int{
+	SEVERITY_INFO:    1,
+	SEVERITY_WARNING: 2,
+	SEVERITY_ERROR:   3,
+}
+
+// AtLeast returns true if severity is at least as severe as other.
+func (severity Severity) AtLeast(other Severity) bool {
+	return severityLevels[severity] >= severityLevels[other]
+}
+
+// ParseSeverity parses a severity name ("info", "warning" or "error").
+func ParseSeverity(name string) (Severity, bool) {
+	severity := Severity(strings.ToLower(name))
+	_, ok := severityLevels[severity]
+	return severity, ok
+}
+
+// Finding is a problem found by a rule.
+type Finding struct {
+	Rule       string   `json:"rule"`
+	Severity   Severity `json:"severity"`
+	Zone       string   `json:"zone"`
+	Name       string   `json:"name,omitempty"`
+	Type       string   `json:"type,omitempty"`
+	Value      string   `json:"value,omitempty"`
+	Message    string   `json:"message"`
+	Suggestion string   `json:"suggestion,omitempty"`
+}
+
+// Zone is a zone to lint, with its records.
+type Zone struct {
+	Zone    hetzner_dns.Zone
+	Records []hetzner_dns.Record
+}
+
+// Rule checks a zone. index holds the records of all the zones linted
+// together, to resolve names across zones.
+type Rule struct {
+	Name        string
+	Description string
+	Check       func(zone *Zone, index *Index) []Finding
+}
+
+// Index maps fully qualified names to the records of the zones linted
+// together.
+type Index struct {
+	zones   []string
+	records map[string][]hetzner_dns.Record
+}
+
+// NewIndex indexes the records of zones by fully qualified name.
+func NewIndex(zones []Zone) *Index {
+	index := &Index{records: map[string][]hetzner_dns.Record{}}
+	for _, zone := range zones {
+		origin := Fqdn(zone.Zone.Name)
+		index.zones = append(index.zones, origin)
+		for _, record := range zone.Records {
+			name := RecordFqdn(record.Name, origin)
+			index.records[name] = append(index.records[name], record)
+		}
+	}
+	return index
+}
+
+// Lookup returns the records named name (fully qualified).
+func (index *Index) Lookup(name string) []hetzner_dns.Record {
+	return index.records[strings.ToLower(Fqdn(name))]
+}
+
+// Exists returns true if name (fully qualified) has records, directly or
+// through a wildcard.
+func (index *Index) Exists(name string) bool {
+	name = strings.ToLower(Fqdn(name))
+	if len(index.records[name]) > 0 {
+		return true
+	}
+	for i := strings.Index(name, "."); i >= 0 && i < len(name)-1; i = strings.Index(name, ".") {
+		name = name[i+1:]
+		if len(index.records["*."+name]) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Managed returns true if name (fully qualified) belongs to one of the
+// indexed zones, i.e. its records are known.
+func (index *Index) Managed(name string) bool {
+	name = strings.ToLower(Fqdn(name))
+	for _, origin := range index.zones {
+		if name == origin || strings.HasSuffix(name, "."+origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// Linter runs rules over zones.
+type Linter struct {
+	Rules []Rule
+}
+
+// NewLinter creates a Linter with the given rules, DefaultRules() if none.
+func NewLinter(rules ...Rule) *Linter {
+	if len(rules) == 0 {
+		rules = DefaultRules()
+	}
+	return &Linter{Rules: rules}
+}
+
+// Select keeps only the rules named in enable (all if empty), minus those
+// named in disable. Unknown names are returned.
+func (linter *Linter) Select(enable []string, disable []string) []string {
+	known := map[string]bool{}
+	for _, rule := range linter.Rules {
+		known[rule.Name] = true
+	}
+	var unknown []string
+	for _, name := range append(append([]string{}, enable...), disable...) {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+
+	var rules []Rule
+	for _, rule := range linter.Rules {
+		if len(enable) > 0 && !contains(enable, rule.Name) {
+			continue
+		}
+		if contains(disable, rule.Name) {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	linter.Rules = rules
+	return unknown
+}
+
+// Lint runs the rules over zones, returning the findings sorted by zone,
+// name, type and rule.
+func (linter *Linter) Lint(zones []Zone) []Finding {
+	index := NewIndex(zones)
+	var findings []Finding
+	for i := range zones {
+		zone := &zones[i]
+		for _, rule := range linter.Rules {
+			for _, finding := range rule.Check(zone, index) {
+				finding.Rule = rule.Name
+				if finding.Zone == "" {
+					finding.Zone = zone.Zone.Name
+				}
+				findings = append(findings, finding)
+			}
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := &findings[i], &findings[j]
+		if a.Zone != b.Zone {
+			return a.Zone < b.Zone
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		return a.Rule < b.Rule
+	})
+	return findings
+}
+
+// Report is the result of a lint run.
+type Report struct {
+	Findings []Finding        `json:"findings"`
+	Summary  map[Severity]int `json:"summary"`
+}
+
+// NewReport summarizes findings by severity.
+func NewReport(findings []Finding) *Report {
+	report := &Report{
+		Findings: findings,
+		Summary:  map[Severity]int{SEVERITY_INFO: 0, SEVERITY_WARNING: 0, SEVERITY_ERROR: 0},
+	}
+	if report.Findings == nil {
+		report.Findings = []Finding{}
+	}
+	for _, finding := range findings {
+		report.Summary[finding.Severity]++
+	}
+	return report
+}
+
+// Fails returns true if there are findings at least as severe as threshold.
+func (report *Report) Fails(threshold Severity) bool {
+	for _, finding := range report.Findings {
+		if finding.Severity.AtLeast(threshold) {
+			return true
+		}
+	}
+	return false
+}
+
+// Fqdn returns name fully qualified (with a trailing dot) and lowercased.
+func Fqdn(name string) string {
+	name = strings.ToLower(name)
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	return name
+}
+
+// RecordFqdn returns the fully qualified name of a record of the zone
+// origin, "@" or empty names being the apex.
+func RecordFqdn(name string, origin string) string {
+	origin = Fqdn(origin)
+	name = strings.ToLower(name)
+	switch {
+	case name == "" || name == "@":
+		return origin
+	case strings.HasSuffix(name, "."):
+		return name
+	}
+	return name + "." + origin
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
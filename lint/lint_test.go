@@ -0,0 +1,127 @@
+package lint_test
+
+import (
+	"strings"
+	"testing"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+	"github.com/panta/go-hetzner-dns/lint"
+)
+
+func newZone(name string, records ...string) lint.Zone {
+	zone := lint.Zone{Zone: hetzner_dns.Zone{ID: name, Name: name, TTL: 86400}}
+	for i, line := range records {
+		fields := strings.SplitN(line, " ", 3)
+		zone.Records = append(zone.Records, hetzner_dns.Record{
+			ID: name + "-" + string(rune('a'+i)), ZoneID: name, Name: fields[0], Type: fields[1], Value: fields[2],
+		})
+	}
+	return zone
+}
+
+func rules(findings []lint.Finding) []string {
+	var names []string
+	for _, finding := range findings {
+		names = append(names, finding.Name+" "+finding.Rule)
+	}
+	return names
+}
+
+func TestRules(t *testing.T) {
+	manyIncludes := "v=spf1"
+	for i := 0; i < 11; i++ {
+		manyIncludes += " include:spf" + string(rune('a'+i)) + ".example.net"
+	}
+	tests := []struct {
+		name     string
+		zones    []lint.Zone
+		expected []string
+	}{
+		{"clean", []lint.Zone{newZone("example.com",
+			"@ A 192.0.2.1", "www CNAME @", "@ MX 10 mail", "mail A 192.0.2.25",
+			`@ TXT "v=spf1 mx -all"`, `_dmarc TXT "v=DMARC1; p=reject"`)}, nil},
+		{"cname conflict", []lint.Zone{newZone("example.com",
+			"www CNAME example.net.", "www TXT hello", "@ CNAME example.net.")},
+			[]string{"@ cname-conflict", "www cname-conflict"}},
+		{"dangling cname", []lint.Zone{
+			newZone("example.com", "www CNAME old.example.org.", "api CNAME gone", "ext CNAME example.net.", "wild CNAME x.dyn.example.org."),
+			newZone("example.org", "*.dyn A 192.0.2.1")},
+			[]string{"api dangling-cname", "www dangling-cname"}},
+		{"spf lookups", []lint.Zone{newZone("example.com", "@ TXT "+manyIncludes, `_dmarc TXT "v=DMARC1; p=none"`)},
+			[]string{"@ spf-lookups"}},
+		{"spf nested", []lint.Zone{newZone("example.com",
+			`@ TXT "v=spf1 a mx include:_spf.example.com -all"`,
+			`_spf TXT "v=spf1 a mx ptr exists:%{i}.x.example.com include:_spf2.example.com"`,
+			`_spf2 TXT "v=spf1 a mx a:b.example.com mx:c.example.com ip4:192.0.2.0/24"`,
+			`_dmarc TXT "v=DMARC1; p=none"`)},
+			[]string{"@ spf-lookups"}},
+		{"spf duplicated", []lint.Zone{newZone("example.com", `@ TXT "v=spf1 -all"`, `@ TXT "v=spf1 mx -all"`, `_dmarc TXT "v=DMARC1; p=none"`)},
+			[]string{"@ spf-lookups"}},
+		{"missing dmarc", []lint.Zone{newZone("example.com", "@ MX 10 mx.example.net.")},
+			[]string{"_dmarc missing-dmarc"}},
+		{"duplicates and ttl", []lint.Zone{newZone("example.com", "www A 192.0.2.1", "www A 192.0.2.1", "api A 192.0.2.2", "api A 192.0.2.3")},
+			[]string{"www duplicate-record"}},
+		{"mx cname", []lint.Zone{newZone("example.com",
+			"@ MX 10 mail", "mail CNAME host", "host A 192.0.2.25", `_dmarc TXT "v=DMARC1; p=none"`)},
+			[]string{"@ mx-cname"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			findings := rules(lint.NewLinter().Lint(test.zones))
+			if strings.Join(findings, ",") != strings.Join(test.expected, ",") {
+				t.Errorf("expected findings %v, got %v", test.expected, findings)
+			}
+		})
+	}
+}
+
+func TestInconsistentTTL(t *testing.T) {
+	zone := newZone("example.com", "www A 192.0.2.1", "www A 192.0.2.2", "www A 192.0.2.3")
+	zone.Records[0].TTL = 300
+	zone.Records[1].TTL = 300
+	findings := lint.NewLinter().Lint([]lint.Zone{zone})
+	if len(findings) != 1 || findings[0].Rule != lint.RULE_TTL_INCONSISTENT {
+		t.Fatalf("expected an inconsistent TTL finding, got %v", findings)
+	}
+	if !strings.Contains(findings[0].Message, "[300 86400]") || !strings.Contains(findings[0].Suggestion, "300") {
+		t.Errorf("unexpected finding %+v", findings[0])
+	}
+}
+
+func TestRulesWithoutIDs(t *testing.T) {
+	// records of zone files and exports have no IDs
+	zone := newZone("example.com", "www CNAME x.example.net.", "www A 192.0.2.1", "api A 192.0.2.2", "api A 192.0.2.2")
+	for i := range zone.Records {
+		zone.Records[i].ID = ""
+	}
+	findings := lint.NewLinter().Lint([]lint.Zone{zone})
+	if got := strings.Join(rules(findings), ","); got != "api duplicate-record,www cname-conflict" {
+		t.Fatalf("unexpected findings %v", got)
+	}
+	if findings[0].Suggestion != "delete one of them" {
+		t.Errorf("unexpected suggestion %q", findings[0].Suggestion)
+	}
+}
+
+func TestSelectAndReport(t *testing.T) {
+	linter := lint.NewLinter()
+	if unknown := linter.Select(nil, []string{lint.RULE_MISSING_DMARC, "nope"}); len(unknown) != 1 || unknown[0] != "nope" {
+		t.Errorf("expected unknown rule nope, got %v", unknown)
+	}
+	zone := newZone("example.com", "@ MX 10 mx.example.net.", "www A 192.0.2.1", "www A 192.0.2.1")
+	report := lint.NewReport(linter.Lint([]lint.Zone{zone}))
+	if len(report.Findings) != 1 || report.Summary[lint.SEVERITY_WARNING] != 1 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if report.Fails(lint.SEVERITY_ERROR) || !report.Fails(lint.SEVERITY_WARNING) {
+		t.Errorf("expected the report to fail on warnings only")
+	}
+
+	custom := lint.Rule{Name: "no-www", Check: func(zone *lint.Zone, index *lint.Index) []lint.Finding {
+		return []lint.Finding{{Severity: lint.SEVERITY_INFO, Name: "www", Message: "www is old-fashioned"}}
+	}}
+	findings := lint.NewLinter(custom).Lint([]lint.Zone{zone})
+	if len(findings) != 1 || findings[0].Rule != "no-www" || findings[0].Zone != "example.com" {
+		t.Errorf("unexpected custom rule findings %+v", findings)
+	}
+}
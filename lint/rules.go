@@ -0,0 +1,282 @@
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+	"github.com/panta/go-hetzner-dns/mailauth"
+)
+
+const (
+	RULE_CNAME_CONFLICT   = "cname-conflict"
+	RULE_DANGLING_CNAME   = "dangling-cname"
+	RULE_SPF_LOOKUPS      = "spf-lookups"
+	RULE_MISSING_DMARC    = "missing-dmarc"
+	RULE_DUPLICATE        = "duplicate-record"
+	RULE_TTL_INCONSISTENT = "inconsistent-ttl"
+	RULE_MX_CNAME         = "mx-cname"
+)
+
+// DefaultRules returns all the built-in rules.
+func DefaultRules() []Rule {
+	return []Rule{
+		{RULE_CNAME_CONFLICT, "CNAME records must be the only record of their name", checkCNAMEConflict},
+		{RULE_DANGLING_CNAME, "CNAME records must point to existing names of the managed zones", checkDanglingCNAME},
+		{RULE_SPF_LOOKUPS, "SPF records must be unique and need at most 10 DNS lookups", checkSPF},
+		{RULE_MISSING_DMARC, "zones sending mail should publish a DMARC policy", checkDMARC},
+		{RULE_DUPLICATE, "records should not be duplicated", checkDuplicates},
+		{RULE_TTL_INCONSISTENT, "records of the same name and type should have the same TTL", checkTTLs},
+		{RULE_MX_CNAME, "MX records must not point to a CNAME", checkMXCNAME},
+	}
+}
+
+func checkCNAMEConflict(zone *Zone, index *Index) []Finding {
+	// records are told apart by index, as records of zone files and exports
+	// have no IDs
+	byName := map[string][]int{}
+	for i, record := range zone.Records {
+		name := displayName(record.Name)
+		byName[name] = append(byName[name], i)
+	}
+
+	var findings []Finding
+	for i, record := range zone.Records {
+		if record.Type != "CNAME" {
+			continue
+		}
+		name := displayName(record.Name)
+		if name == "@" {
+			findings = append(findings, newFinding(SEVERITY_ERROR, &record,
+				"CNAME at the zone apex conflicts with the SOA and NS records",
+				"replace it with A/AAAA records, or use a subdomain"))
+			continue
+		}
+		var others []string
+		for _, j := range byName[name] {
+			if j != i {
+				others = append(others, zone.Records[j].Type)
+			}
+		}
+		if len(others) > 0 {
+			findings = append(findings, newFinding(SEVERITY_ERROR, &record,
+				fmt.Sprintf("CNAME coexists with other records of the same name (%s)", strings.Join(others, ", ")),
+				"keep either the CNAME or the other records"))
+		}
+	}
+	return findings
+}
+
+func checkDanglingCNAME(zone *Zone, index *Index) []Finding {
+	var findings []Finding
+	for _, record := range zone.Records {
+		if record.Type != "CNAME" {
+			continue
+		}
+		target := RecordFqdn(strings.TrimSpace(record.Value), zone.Zone.Name)
+		if index.Managed(target) && !index.Exists(target) {
+			findings = append(findings, newFinding(SEVERITY_ERROR, &record,
+				fmt.Sprintf("CNAME points to %s, which does not exist", target),
+				"delete the CNAME or create the target"))
+		}
+	}
+	return findings
+}
+
+func checkSPF(zone *Zone, index *Index) []Finding {
+	spfByName := map[string][]hetzner_dns.Record{}
+	var names []string
+	for _, record := range zone.Records {
+		if record.Type != "TXT" || !mailauth.IsSPF(mailauth.TXTText(record.Value)) {
+			continue
+		}
+		name := displayName(record.Name)
+		if _, ok := spfByName[name]; !ok {
+			names = append(names, name)
+		}
+		spfByName[name] = append(spfByName[name], record)
+	}
+
+	var findings []Finding
+	for _, name := range names {
+		records := spfByName[name]
+		if len(records) > 1 {
+			findings = append(findings, newFinding(SEVERITY_ERROR, &records[1],
+				fmt.Sprintf("%d SPF records for the same name, SPF evaluation fails", len(records)),
+				"merge them into a single SPF record"))
+			continue
+		}
+		record := records[0]
+		spf, err := mailauth.ParseSPF(mailauth.TXTText(record.Value))
+		if err != nil {
+			findings = append(findings, newFinding(SEVERITY_ERROR, &record, err.Error(), "fix the syntax of the SPF record"))
+			continue
+		}
+		lookups, complete := spf.Lookups(index.resolveSPF)
+		if lookups > mailauth.MAX_SPF_LOOKUPS {
+			count := fmt.Sprint(lookups)
+			if !complete {
+				count = "at least " + count
+			}
+			findings = append(findings, newFinding(SEVERITY_ERROR, &record,
+				fmt.Sprintf("SPF record needs %s DNS lookups, more than %d", count, mailauth.MAX_SPF_LOOKUPS),
+				"flatten includes into ip4/ip6 mechanisms or remove unused ones"))
+		}
+	}
+	return findings
+}
+
+// resolveSPF returns the SPF policy of domain if it belongs to the indexed
+// zones, to follow includes without DNS lookups.
+func (index *Index) resolveSPF(domain string) (*mailauth.SPF, bool) {
+	if !index.Managed(domain) {
+		return nil, false
+	}
+	for _, record := range index.Lookup(domain) {
+		if text := mailauth.TXTText(record.Value); record.Type == "TXT" && mailauth.IsSPF(text) {
+			spf, err := mailauth.ParseSPF(text)
+			return spf, err == nil
+		}
+	}
+	return &mailauth.SPF{}, true
+}
+
+func checkDMARC(zone *Zone, index *Index) []Finding {
+	sendsMail := false
+	for _, record := range zone.Records {
+		if displayName(record.Name) != "@" {
+			continue
+		}
+		if record.Type == "MX" || (record.Type == "TXT" && mailauth.IsSPF(mailauth.TXTText(record.Value))) {
+			sendsMail = true
+		}
+	}
+	if !sendsMail {
+		return nil
+	}
+	for _, record := range index.Lookup(mailauth.DMARC_NAME + "." + Fqdn(zone.Zone.Name)) {
+		if record.Type == "TXT" && mailauth.IsDMARC(mailauth.TXTText(record.Value)) {
+			return nil
+		}
+	}
+	dmarc := &mailauth.DMARC{
+		Policy:           mailauth.DMARC_NONE,
+		AggregateReports: []string{"mailto:dmarc@" + strings.TrimSuffix(zone.Zone.Name, ".")},
+	}
+	return []Finding{{
+		Severity:   SEVERITY_WARNING,
+		Name:       mailauth.DMARC_NAME,
+		Type:       "TXT",
+		Message:    "the zone has MX or SPF records but no DMARC policy",
+		Suggestion: fmt.Sprintf("add %s TXT %q", mailauth.DMARC_NAME, dmarc.String()),
+	}}
+}
+
+func checkDuplicates(zone *Zone, index *Index) []Finding {
+	seen := map[string]bool{}
+	var findings []Finding
+	for _, record := range zone.Records {
+		value := strings.ToLower(strings.TrimSpace(record.Value))
+		if record.Type == "TXT" {
+			value = mailauth.TXTText(record.Value)
+		}
+		key := displayName(record.Name) + " " + record.Type + " " + value
+		if seen[key] {
+			suggestion := "delete one of them"
+			if record.ID != "" {
+				suggestion = fmt.Sprintf("delete record %s", record.ID)
+			}
+			findings = append(findings, newFinding(SEVERITY_WARNING, &record,
+				"duplicate of another record with the same name, type and value", suggestion))
+		}
+		seen[key] = true
+	}
+	return findings
+}
+
+func checkTTLs(zone *Zone, index *Index) []Finding {
+	ttls := map[string]map[int]int{}
+	var keys []string
+	for _, record := range zone.Records {
+		key := displayName(record.Name) + " " + record.Type
+		if _, ok := ttls[key]; !ok {
+			ttls[key] = map[int]int{}
+			keys = append(keys, key)
+		}
+		ttls[key][recordTTL(zone, &record)]++
+	}
+
+	var findings []Finding
+	for _, key := range keys {
+		if len(ttls[key]) < 2 {
+			continue
+		}
+		var values []int
+		common := 0
+		for ttl, count := range ttls[key] {
+			values = append(values, ttl)
+			if count > ttls[key][common] || (count == ttls[key][common] && ttl < common) {
+				common = ttl
+			}
+		}
+		sort.Ints(values)
+		parts := strings.SplitN(key, " ", 2)
+		findings = append(findings, Finding{
+			Severity:   SEVERITY_WARNING,
+			Name:       parts[0],
+			Type:       parts[1],
+			Message:    fmt.Sprintf("records of the same name and type have different TTLs %v", values),
+			Suggestion: fmt.Sprintf("use the same TTL for all of them, e.g. %d", common),
+		})
+	}
+	return findings
+}
+
+func checkMXCNAME(zone *Zone, index *Index) []Finding {
+	var findings []Finding
+	for _, record := range zone.Records {
+		if record.Type != "MX" {
+			continue
+		}
+		fields := strings.Fields(record.Value)
+		if len(fields) == 0 {
+			continue
+		}
+		target := RecordFqdn(fields[len(fields)-1], zone.Zone.Name)
+		for _, other := range index.Lookup(target) {
+			if other.Type == "CNAME" {
+				findings = append(findings, newFinding(SEVERITY_ERROR, &record,
+					fmt.Sprintf("MX points to %s, which is a CNAME", target),
+					fmt.Sprintf("point the MX to the canonical name %s", RecordFqdn(strings.TrimSpace(other.Value), target))))
+				break
+			}
+		}
+	}
+	return findings
+}
+
+func newFinding(severity Severity, record *hetzner_dns.Record, message string, suggestion string) Finding {
+	return Finding{
+		Severity:   severity,
+		Name:       displayName(record.Name),
+		Type:       record.Type,
+		Value:      record.Value,
+		Message:    message,
+		Suggestion: suggestion,
+	}
+}
+
+func displayName(name string) string {
+	if name == "" {
+		return "@"
+	}
+	return strings.ToLower(name)
+}
+
+func recordTTL(zone *Zone, record *hetzner_dns.Record) int {
+	if record.TTL == 0 {
+		return zone.Zone.TTL
+	}
+	return record.TTL
+}
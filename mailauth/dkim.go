@@ -0,0 +1,143 @@
+package mailauth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+)
+
+const (
+	DKIM_RSA     = "rsa"
+	DKIM_ED25519 = "ed25519"
+)
+
+// DKIM is a DKIM public key record (RFC 6376, section 3.6.1).
+type DKIM struct {
+	// KeyType is DKIM_RSA (the default if empty) or DKIM_ED25519.
+	KeyType string
+	// PublicKey is the DER encoded public key for RSA keys, or the raw key
+	// for Ed25519 keys (RFC 8463). It is empty for revoked keys.
+	PublicKey []byte
+	// HashAlgorithms restricts the hash algorithms (h=), e.g. "sha256".
+	HashAlgorithms []string
+	// Flags are the flags (t=), e.g. "y" for testing and "s" for strict.
+	Flags []string
+	// Services restricts the service types (s=), e.g. "email".
+	Services []string
+	Notes    string
+}
+
+// NewDKIMFromPEM creates a DKIM record for a PEM encoded RSA or Ed25519
+// public key ("PUBLIC KEY" or "RSA PUBLIC KEY" blocks).
+func NewDKIMFromPEM(data []byte) (*DKIM, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("mailauth: no PEM data found")
+	}
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrap(err, "mailauth: can't parse RSA public key")
+		}
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			return nil, errors.Wrap(err, "mailauth: can't encode RSA public key")
+		}
+		return &DKIM{KeyType: DKIM_RSA, PublicKey: der}, nil
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrap(err, "mailauth: can't parse public key")
+		}
+		switch key := key.(type) {
+		case *rsa.PublicKey:
+			return &DKIM{KeyType: DKIM_RSA, PublicKey: block.Bytes}, nil
+		case ed25519.PublicKey:
+			return &DKIM{KeyType: DKIM_ED25519, PublicKey: []byte(key)}, nil
+		default:
+			return nil, errors.Errorf("mailauth: unsupported public key type %T", key)
+		}
+	}
+	return nil, errors.Errorf("mailauth: unsupported PEM block %q", block.Type)
+}
+
+// DKIMName returns the name of the DKIM record of selector, relative to the
+// zone.
+func DKIMName(selector string) string {
+	return selector + "._domainkey"
+}
+
+// String returns the text of the DKIM record.
+func (dkim *DKIM) String() string {
+	keyType := dkim.KeyType
+	if keyType == "" {
+		keyType = DKIM_RSA
+	}
+	parts := []string{"v=DKIM1", "k=" + keyType}
+	if len(dkim.HashAlgorithms) > 0 {
+		parts = append(parts, "h="+strings.Join(dkim.HashAlgorithms, ":"))
+	}
+	if len(dkim.Services) > 0 {
+		parts = append(parts, "s="+strings.Join(dkim.Services, ":"))
+	}
+	if len(dkim.Flags) > 0 {
+		parts = append(parts, "t="+strings.Join(dkim.Flags, ":"))
+	}
+	if dkim.Notes != "" {
+		parts = append(parts, "n="+dkim.Notes)
+	}
+	parts = append(parts, "p="+base64.StdEncoding.EncodeToString(dkim.PublicKey))
+	return strings.Join(parts, "; ")
+}
+
+// Record returns the TXT record of the key for selector in the zone zoneId,
+// split into several strings if the key is long.
+func (dkim *DKIM) Record(zoneId string, selector string) hetzner_dns.RecordRequest {
+	return txtRecord(zoneId, DKIMName(selector), dkim.String())
+}
+
+// IsDKIM returns true if text looks like a DKIM key record.
+func IsDKIM(text string) bool {
+	return strings.HasPrefix(text, "v=DKIM1") || (!strings.HasPrefix(text, "v=") && strings.Contains(text, "p="))
+}
+
+// ParseDKIM parses the text of a DKIM key record.
+func ParseDKIM(text string) (*DKIM, error) {
+	if !strings.HasPrefix(strings.TrimSpace(text), "v=") {
+		// v= is optional for DKIM key records
+		text = "v=DKIM1; " + text
+	}
+	values, err := tags(text, "DKIM1")
+	if err != nil {
+		return nil, err
+	}
+	key, ok := values["p"]
+	if !ok {
+		return nil, errors.New("mailauth: DKIM record without p= tag")
+	}
+	dkim := &DKIM{KeyType: DKIM_RSA, Notes: values["n"]}
+	if keyType, ok := values["k"]; ok {
+		dkim.KeyType = keyType
+	}
+	if dkim.PublicKey, err = base64.StdEncoding.DecodeString(strings.Join(strings.Fields(key), "")); err != nil {
+		return nil, errors.Wrap(err, "mailauth: invalid DKIM public key")
+	}
+	for tag, list := range map[string]*[]string{"h": &dkim.HashAlgorithms, "s": &dkim.Services, "t": &dkim.Flags} {
+		if value, ok := values[tag]; ok {
+			for _, item := range strings.Split(value, ":") {
+				if item = strings.TrimSpace(item); item != "" {
+					*list = append(*list, item)
+				}
+			}
+		}
+	}
+	return dkim, nil
+}
@@ -0,0 +1,121 @@
+package mailauth
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+)
+
+// DMARC_NAME is the name of the DMARC record, relative to the zone.
+const DMARC_NAME = "_dmarc"
+
+const (
+	DMARC_NONE       = "none"
+	DMARC_QUARANTINE = "quarantine"
+	DMARC_REJECT     = "reject"
+
+	DMARC_RELAXED = "r"
+	DMARC_STRICT  = "s"
+)
+
+// DMARC is a DMARC policy (RFC 7489, section 6.3). Empty fields are omitted
+// from the record, leaving the defaults of the protocol.
+type DMARC struct {
+	// Policy is DMARC_NONE, DMARC_QUARANTINE or DMARC_REJECT (p=).
+	Policy string
+	// SubdomainPolicy is the policy of the subdomains (sp=).
+	SubdomainPolicy string
+	// Percent is the percentage of messages the policy applies to (pct=),
+	// nil for the default of 100. It is a pointer so that pct=0 is kept.
+	Percent *int
+	// AggregateReports and ForensicReports are the report URIs (rua= and
+	// ruf=), e.g. "mailto:dmarc@example.com".
+	AggregateReports []string
+	ForensicReports  []string
+	// DKIMAlignment and SPFAlignment are DMARC_RELAXED or DMARC_STRICT
+	// (adkim= and aspf=).
+	DKIMAlignment string
+	SPFAlignment  string
+	// FailureOptions are the failure reporting options (fo=), e.g. "1".
+	FailureOptions string
+	// ReportInterval is the interval between aggregate reports in seconds
+	// (ri=).
+	ReportInterval int
+}
+
+// String returns the text of the DMARC record.
+func (dmarc *DMARC) String() string {
+	policy := dmarc.Policy
+	if policy == "" {
+		policy = DMARC_NONE
+	}
+	parts := []string{"v=DMARC1", "p=" + policy}
+	add := func(tag string, value string) {
+		if value != "" {
+			parts = append(parts, tag+"="+value)
+		}
+	}
+	add("sp", dmarc.SubdomainPolicy)
+	if dmarc.Percent != nil {
+		add("pct", strconv.Itoa(*dmarc.Percent))
+	}
+	add("rua", strings.Join(dmarc.AggregateReports, ","))
+	add("ruf", strings.Join(dmarc.ForensicReports, ","))
+	add("adkim", dmarc.DKIMAlignment)
+	add("aspf", dmarc.SPFAlignment)
+	add("fo", dmarc.FailureOptions)
+	if dmarc.ReportInterval > 0 {
+		add("ri", strconv.Itoa(dmarc.ReportInterval))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Record returns the TXT record of the policy in the zone zoneId.
+func (dmarc *DMARC) Record(zoneId string) hetzner_dns.RecordRequest {
+	return txtRecord(zoneId, DMARC_NAME, dmarc.String())
+}
+
+// IsDMARC returns true if text is a DMARC record.
+func IsDMARC(text string) bool {
+	return strings.HasPrefix(text, "v=DMARC1")
+}
+
+// ParseDMARC parses the text of a DMARC record.
+func ParseDMARC(text string) (*DMARC, error) {
+	values, err := tags(text, "DMARC1")
+	if err != nil {
+		return nil, err
+	}
+	dmarc := &DMARC{
+		Policy:           values["p"],
+		SubdomainPolicy:  values["sp"],
+		AggregateReports: splitCommas(values["rua"]),
+		ForensicReports:  splitCommas(values["ruf"]),
+		DKIMAlignment:    values["adkim"],
+		SPFAlignment:     values["aspf"],
+		FailureOptions:   values["fo"],
+	}
+	switch dmarc.Policy {
+	case DMARC_NONE, DMARC_QUARANTINE, DMARC_REJECT:
+	case "":
+		return nil, errors.New("mailauth: DMARC record without p= tag")
+	default:
+		return nil, errors.Errorf("mailauth: invalid DMARC policy %q", dmarc.Policy)
+	}
+	if text, ok := values["pct"]; ok {
+		percent, err := strconv.Atoi(text)
+		if err != nil || percent < 0 || percent > 100 {
+			return nil, errors.Errorf("mailauth: invalid DMARC pct=%s", text)
+		}
+		dmarc.Percent = &percent
+	}
+	if text, ok := values["ri"]; ok {
+		if dmarc.ReportInterval, err = strconv.Atoi(text); err != nil {
+			return nil, errors.Errorf("mailauth: invalid DMARC ri=%s", text)
+		}
+	}
+	return dmarc, nil
+}
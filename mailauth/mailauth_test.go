@@ -0,0 +1,214 @@
+package mailauth_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+	"github.com/panta/go-hetzner-dns/mailauth"
+)
+
+func TestSPF(t *testing.T) {
+	spf := mailauth.NewSPF().MX("").IP("192.0.2.0/24").IP("2001:db8::/32").Include("_spf.example.net").All(mailauth.SPF_FAIL)
+	text := "v=spf1 mx ip4:192.0.2.0/24 ip6:2001:db8::/32 include:_spf.example.net -all"
+	if spf.String() != text {
+		t.Errorf("unexpected SPF %q", spf.String())
+	}
+	record := spf.Record("zone-1", "@")
+	if record.Type != "TXT" || record.Name != "@" || record.Value != text {
+		t.Errorf("unexpected record %+v", record)
+	}
+
+	parsed, err := mailauth.ParseSPF("v=spf1 a/24 mx:mail.example.com ~all redirect=_spf.example.com foo=bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed.Mechanisms) != 3 || parsed.Mechanisms[0].Value != "/24" || parsed.Mechanisms[2].Qualifier != mailauth.SPF_SOFTFAIL {
+		t.Errorf("unexpected mechanisms %+v", parsed.Mechanisms)
+	}
+	if parsed.Redirect != "_spf.example.com" || parsed.String() != "v=spf1 a/24 mx:mail.example.com ~all redirect=_spf.example.com" {
+		t.Errorf("unexpected SPF %q", parsed.String())
+	}
+	for _, invalid := range []string{"v=spf2", "v=spf1 include", "v=spf1 bogus", "v=spf1 all:x"} {
+		if _, err := mailauth.ParseSPF(invalid); err == nil {
+			t.Errorf("expected an error parsing %q", invalid)
+		}
+	}
+}
+
+func TestSPFLookups(t *testing.T) {
+	policies := map[string]string{
+		"_spf.example.com":  "v=spf1 a mx include:_spf2.example.com include:_spf.example.com",
+		"_spf2.example.com": "v=spf1 exists:%{i}.example.com ptr ip4:192.0.2.1",
+	}
+	resolve := func(domain string) (*mailauth.SPF, bool) {
+		text, ok := policies[domain]
+		if !ok {
+			return nil, false
+		}
+		spf, err := mailauth.ParseSPF(text)
+		return spf, err == nil
+	}
+	spf, _ := mailauth.ParseSPF("v=spf1 mx include:_spf.example.com -all")
+	if count, complete := spf.Lookups(resolve); count != 8 || !complete {
+		t.Errorf("expected 8 lookups, got %d (complete %v)", count, complete)
+	}
+	spf.Include("_spf.example.org")
+	if count, complete := spf.Lookups(resolve); count != 9 || complete {
+		t.Errorf("expected at least 9 lookups, got %d (complete %v)", count, complete)
+	}
+	if count, _ := spf.Lookups(nil); count != 3 {
+		t.Errorf("expected 3 direct lookups, got %d", count)
+	}
+}
+
+func TestDKIM(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs1 := pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey)})
+	dkim, err := mailauth.NewDKIMFromPEM(pkcs1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := dkim.Record("zone-1", "mail")
+	if record.Name != "mail._domainkey" || !strings.HasPrefix(record.Value, `"v=DKIM1; k=rsa; p=`) {
+		t.Errorf("unexpected record %+v", record)
+	}
+	for _, chunk := range strings.Split(record.Value, `" "`) {
+		if len(strings.Trim(chunk, `"`)) > mailauth.MAX_TXT_CHUNK {
+			t.Errorf("TXT chunk longer than %d characters", mailauth.MAX_TXT_CHUNK)
+		}
+	}
+	parsed, err := mailauth.ParseDKIM(mailauth.TXTText(record.Value))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed.PublicKey, dkim.PublicKey) {
+		t.Errorf("public key not read back")
+	}
+	if _, err := x509.ParsePKIXPublicKey(parsed.PublicKey); err != nil {
+		t.Errorf("expected a PKIX public key: %v", err)
+	}
+
+	edKey, _, _ := ed25519.GenerateKey(rand.Reader)
+	der, _ := x509.MarshalPKIXPublicKey(edKey)
+	dkim, err = mailauth.NewDKIMFromPEM(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dkim.KeyType != mailauth.DKIM_ED25519 || len(dkim.PublicKey) != ed25519.PublicKeySize {
+		t.Errorf("unexpected Ed25519 DKIM %+v", dkim)
+	}
+
+	parsed, err = mailauth.ParseDKIM("k=rsa; t=y:s; h=sha256; p=AQAB")
+	if err != nil || !reflect.DeepEqual(parsed.Flags, []string{"y", "s"}) || parsed.String() != "v=DKIM1; k=rsa; h=sha256; t=y:s; p=AQAB" {
+		t.Errorf("unexpected DKIM %+v (%v)", parsed, err)
+	}
+	if _, err := mailauth.NewDKIMFromPEM([]byte("not a key")); err == nil {
+		t.Errorf("expected an error for invalid PEM data")
+	}
+}
+
+func TestDMARC(t *testing.T) {
+	percent := 50
+	dmarc := &mailauth.DMARC{
+		Policy:           mailauth.DMARC_QUARANTINE,
+		Percent:          &percent,
+		AggregateReports: []string{"mailto:dmarc@example.com", "mailto:dmarc@example.net"},
+		SPFAlignment:     mailauth.DMARC_STRICT,
+	}
+	text := "v=DMARC1; p=quarantine; pct=50; rua=mailto:dmarc@example.com,mailto:dmarc@example.net; aspf=s"
+	if dmarc.String() != text {
+		t.Errorf("unexpected DMARC %q", dmarc.String())
+	}
+	if record := dmarc.Record("zone-1"); record.Name != "_dmarc" {
+		t.Errorf("unexpected record %+v", record)
+	}
+	parsed, err := mailauth.ParseDMARC(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed, dmarc) {
+		t.Errorf("expected %+v, got %+v", dmarc, parsed)
+	}
+	for _, text := range []string{"v=DMARC1; p=reject; pct=0", "v=DMARC1; p=reject"} {
+		parsed, err := mailauth.ParseDMARC(text)
+		if err != nil {
+			t.Errorf("%q: %v", text, err)
+		} else if parsed.String() != text {
+			t.Errorf("expected %q to round trip, got %q", text, parsed.String())
+		}
+	}
+	for _, invalid := range []string{"p=none", "v=DMARC1", "v=DMARC1; p=maybe", "v=DMARC1; p=none; pct=x", "v=DMARC1; p=none; pct=101", "v=DMARC1; p=none; p=reject"} {
+		if _, err := mailauth.ParseDMARC(invalid); err == nil {
+			t.Errorf("expected an error parsing %q", invalid)
+		}
+	}
+}
+
+func TestMTASTS(t *testing.T) {
+	mtasts := mailauth.NewMTASTS(time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC))
+	if mtasts.String() != "v=STSv1; id=20210301T120000" || mtasts.Record("zone-1").Name != "_mta-sts" {
+		t.Errorf("unexpected MTA-STS %q", mtasts.String())
+	}
+	if parsed, err := mailauth.ParseMTASTS("v=STSv1; id=abc;"); err != nil || parsed.ID != "abc" {
+		t.Errorf("unexpected MTA-STS %+v (%v)", parsed, err)
+	}
+
+	policy := &mailauth.MTASTSPolicy{Mode: mailauth.MTA_STS_ENFORCE, MX: []string{"mail.example.com.", "*.example.net"}, MaxAge: 7 * 24 * time.Hour}
+	text := "version: STSv1\r\nmode: enforce\r\nmx: mail.example.com\r\nmx: *.example.net\r\nmax_age: 604800\r\n"
+	if policy.String() != text {
+		t.Errorf("unexpected policy %q", policy.String())
+	}
+	parsed, err := mailauth.ParseMTASTSPolicy(text)
+	if err != nil || parsed.MaxAge != policy.MaxAge || len(parsed.MX) != 2 {
+		t.Errorf("unexpected policy %+v (%v)", parsed, err)
+	}
+	if _, err := mailauth.ParseMTASTSPolicy("version: STSv1\nmode: strict\n"); err == nil {
+		t.Errorf("expected an error for an invalid mode")
+	}
+
+	tlsrpt := &mailauth.TLSRPT{Reports: []string{"mailto:tls@example.com"}}
+	record := tlsrpt.Record("zone-1")
+	if record.Name != "_smtp._tls" || record.Value != "v=TLSRPTv1; rua=mailto:tls@example.com" {
+		t.Errorf("unexpected record %+v", record)
+	}
+}
+
+func TestFromRecords(t *testing.T) {
+	records := []hetzner_dns.Record{
+		{Name: "@", Type: "TXT", Value: `"v=spf1 mx -all"`},
+		{Name: "@", Type: "TXT", Value: "google-site-verification=abc"},
+		{Name: "@", Type: "MX", Value: "10 mail"},
+		{Name: "s1._domainkey", Type: "TXT", Value: `"v=DKIM1; k=rsa; " "p=AQAB"`},
+		{Name: "_dmarc", Type: "TXT", Value: "v=DMARC1; p=reject"},
+		{Name: "_mta-sts", Type: "TXT", Value: "v=STSv1; id=1"},
+		{Name: "_smtp._tls", Type: "TXT", Value: "v=TLSRPTv1; rua=mailto:tls@example.com"},
+	}
+	policies, err := mailauth.FromRecords(records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policies.SPF == nil || policies.DKIM["s1"] == nil || policies.DMARC.Policy != mailauth.DMARC_REJECT ||
+		policies.MTASTS.ID != "1" || policies.TLSRPT == nil {
+		t.Errorf("unexpected policies %+v", policies)
+	}
+	if generated := policies.Records("zone-1"); len(generated) != 5 || generated[1].Name != "s1._domainkey" {
+		t.Errorf("unexpected records %+v", generated)
+	}
+
+	records = append(records, hetzner_dns.Record{Name: "_dmarc", Type: "TXT", Value: "v=DMARC1; p=bogus"})
+	policies, err = mailauth.FromRecords(records)
+	if err == nil || !strings.Contains(err.Error(), "_dmarc") || policies.SPF == nil {
+		t.Errorf("expected an error for the invalid DMARC record, got %v", err)
+	}
+}
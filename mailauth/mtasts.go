@@ -0,0 +1,166 @@
+package mailauth
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+)
+
+const (
+	// MTA_STS_NAME is the name of the MTA-STS record, relative to the zone.
+	MTA_STS_NAME = "_mta-sts"
+	// MTA_STS_POLICY_HOST is the host serving the MTA-STS policy, relative
+	// to the zone, at https://mta-sts.<zone>/.well-known/mta-sts.txt.
+	MTA_STS_POLICY_HOST = "mta-sts"
+	// TLS_RPT_NAME is the name of the TLS-RPT record, relative to the zone.
+	TLS_RPT_NAME = "_smtp._tls"
+)
+
+const (
+	MTA_STS_ENFORCE = "enforce"
+	MTA_STS_TESTING = "testing"
+	MTA_STS_NONE    = "none"
+)
+
+// MTASTS is an MTA-STS record (RFC 8461, section 3.1), announcing the
+// policy served over HTTPS.
+type MTASTS struct {
+	// ID identifies the version of the policy and must change whenever the
+	// policy changes.
+	ID string
+}
+
+// NewMTASTS creates an MTA-STS record with an ID derived from t.
+func NewMTASTS(t time.Time) *MTASTS {
+	return &MTASTS{ID: t.UTC().Format("20060102T150405")}
+}
+
+// String returns the text of the MTA-STS record.
+func (mtasts *MTASTS) String() string {
+	return "v=STSv1; id=" + mtasts.ID
+}
+
+// Record returns the TXT record in the zone zoneId.
+func (mtasts *MTASTS) Record(zoneId string) hetzner_dns.RecordRequest {
+	return txtRecord(zoneId, MTA_STS_NAME, mtasts.String())
+}
+
+// IsMTASTS returns true if text is an MTA-STS record.
+func IsMTASTS(text string) bool {
+	return strings.HasPrefix(text, "v=STSv1")
+}
+
+// ParseMTASTS parses the text of an MTA-STS record.
+func ParseMTASTS(text string) (*MTASTS, error) {
+	values, err := tags(text, "STSv1")
+	if err != nil {
+		return nil, err
+	}
+	if values["id"] == "" {
+		return nil, errors.New("mailauth: MTA-STS record without id= tag")
+	}
+	return &MTASTS{ID: values["id"]}, nil
+}
+
+// MTASTSPolicy is the MTA-STS policy file (RFC 8461, section 3.2).
+type MTASTSPolicy struct {
+	// Mode is MTA_STS_ENFORCE, MTA_STS_TESTING or MTA_STS_NONE.
+	Mode string
+	// MX are the allowed MX hosts, possibly with a leading wildcard
+	// ("*.example.net").
+	MX     []string
+	MaxAge time.Duration
+}
+
+// String returns the content of the policy file.
+func (policy *MTASTSPolicy) String() string {
+	var sb strings.Builder
+	sb.WriteString("version: STSv1\r\n")
+	fmt.Fprintf(&sb, "mode: %s\r\n", policy.Mode)
+	for _, mx := range policy.MX {
+		fmt.Fprintf(&sb, "mx: %s\r\n", strings.TrimSuffix(mx, "."))
+	}
+	fmt.Fprintf(&sb, "max_age: %d\r\n", int(policy.MaxAge/time.Second))
+	return sb.String()
+}
+
+// ParseMTASTSPolicy parses the content of a policy file.
+func ParseMTASTSPolicy(text string) (*MTASTSPolicy, error) {
+	policy := &MTASTSPolicy{}
+	version := ""
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		i := strings.Index(line, ":")
+		if i < 0 {
+			return nil, errors.Errorf("mailauth: invalid MTA-STS policy line %q", line)
+		}
+		key, value := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		switch key {
+		case "version":
+			version = value
+		case "mode":
+			policy.Mode = value
+		case "mx":
+			policy.MX = append(policy.MX, value)
+		case "max_age":
+			seconds, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, errors.Errorf("mailauth: invalid MTA-STS max_age %q", value)
+			}
+			policy.MaxAge = time.Duration(seconds) * time.Second
+		}
+	}
+	if version != "STSv1" {
+		return nil, errors.Errorf("mailauth: expected MTA-STS policy version STSv1, got %q", version)
+	}
+	switch policy.Mode {
+	case MTA_STS_ENFORCE, MTA_STS_TESTING, MTA_STS_NONE:
+	default:
+		return nil, errors.Errorf("mailauth: invalid MTA-STS mode %q", policy.Mode)
+	}
+	return policy, nil
+}
+
+// TLSRPT is an SMTP TLS reporting record (RFC 8460).
+type TLSRPT struct {
+	// Reports are the report URIs (rua=), e.g. "mailto:tls@example.com".
+	Reports []string
+}
+
+// String returns the text of the TLS-RPT record.
+func (tlsrpt *TLSRPT) String() string {
+	return "v=TLSRPTv1; rua=" + strings.Join(tlsrpt.Reports, ",")
+}
+
+// Record returns the TXT record in the zone zoneId.
+func (tlsrpt *TLSRPT) Record(zoneId string) hetzner_dns.RecordRequest {
+	return txtRecord(zoneId, TLS_RPT_NAME, tlsrpt.String())
+}
+
+// IsTLSRPT returns true if text is a TLS-RPT record.
+func IsTLSRPT(text string) bool {
+	return strings.HasPrefix(text, "v=TLSRPTv1")
+}
+
+// ParseTLSRPT parses the text of a TLS-RPT record.
+func ParseTLSRPT(text string) (*TLSRPT, error) {
+	values, err := tags(text, "TLSRPTv1")
+	if err != nil {
+		return nil, err
+	}
+	tlsrpt := &TLSRPT{Reports: splitCommas(values["rua"])}
+	if len(tlsrpt.Reports) == 0 {
+		return nil, errors.New("mailauth: TLS-RPT record without rua= tag")
+	}
+	return tlsrpt, nil
+}
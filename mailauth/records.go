@@ -0,0 +1,102 @@
+package mailauth
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+)
+
+// Policies are the email authentication records of a zone.
+type Policies struct {
+	SPF *SPF
+	// DKIM are the DKIM keys by selector.
+	DKIM   map[string]*DKIM
+	DMARC  *DMARC
+	MTASTS *MTASTS
+	TLSRPT *TLSRPT
+}
+
+// FromRecords reads the email authentication records of a zone from its
+// records, as returned by GetRecords. The records which can't be parsed are
+// reported in the returned error, the others being read anyway.
+func FromRecords(records []hetzner_dns.Record) (*Policies, error) {
+	policies := &Policies{DKIM: map[string]*DKIM{}}
+	var problems []string
+	for _, record := range records {
+		if record.Type != "TXT" {
+			continue
+		}
+		name := strings.ToLower(record.Name)
+		if name == "" {
+			name = "@"
+		}
+		text := TXTText(record.Value)
+		var err error
+		switch {
+		case name == "@" && IsSPF(text):
+			if policies.SPF != nil {
+				err = errors.New("mailauth: more than one SPF record")
+			} else {
+				policies.SPF, err = ParseSPF(text)
+			}
+		case name == DMARC_NAME && IsDMARC(text):
+			policies.DMARC, err = ParseDMARC(text)
+		case name == MTA_STS_NAME && IsMTASTS(text):
+			policies.MTASTS, err = ParseMTASTS(text)
+		case name == TLS_RPT_NAME && IsTLSRPT(text):
+			policies.TLSRPT, err = ParseTLSRPT(text)
+		case strings.HasSuffix(name, "._domainkey") && IsDKIM(text):
+			var dkim *DKIM
+			if dkim, err = ParseDKIM(text); err == nil {
+				policies.DKIM[strings.TrimSuffix(name, "._domainkey")] = dkim
+			}
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s TXT: %v", name, err))
+		}
+	}
+	if len(problems) > 0 {
+		return policies, errors.Errorf("mailauth: invalid records: %s", strings.Join(problems, "; "))
+	}
+	return policies, nil
+}
+
+// Fetch reads the email authentication records of the zone zoneId.
+func Fetch(ctx context.Context, client *hetzner_dns.Client, zoneId string) (*Policies, error) {
+	records, err := client.GetAllRecords(ctx, zoneId)
+	if err != nil {
+		return nil, err
+	}
+	return FromRecords(records)
+}
+
+// Records returns the records of the policies for the zone zoneId.
+func (policies *Policies) Records(zoneId string) []hetzner_dns.RecordRequest {
+	var records []hetzner_dns.RecordRequest
+	if policies.SPF != nil {
+		records = append(records, policies.SPF.Record(zoneId, "@"))
+	}
+	selectors := make([]string, 0, len(policies.DKIM))
+	for selector := range policies.DKIM {
+		selectors = append(selectors, selector)
+	}
+	sort.Strings(selectors)
+	for _, selector := range selectors {
+		records = append(records, policies.DKIM[selector].Record(zoneId, selector))
+	}
+	if policies.DMARC != nil {
+		records = append(records, policies.DMARC.Record(zoneId))
+	}
+	if policies.MTASTS != nil {
+		records = append(records, policies.MTASTS.Record(zoneId))
+	}
+	if policies.TLSRPT != nil {
+		records = append(records, policies.TLSRPT.Record(zoneId))
+	}
+	return records
+}
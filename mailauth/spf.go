@@ -0,0 +1,237 @@
+package mailauth
+
+import (
+	"net"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+)
+
+// MAX_SPF_LOOKUPS is the limit of DNS lookups of an SPF evaluation
+// (RFC 7208, section 4.6.4).
+const MAX_SPF_LOOKUPS = 10
+
+const (
+	SPF_PASS     = "+"
+	SPF_FAIL     = "-"
+	SPF_SOFTFAIL = "~"
+	SPF_NEUTRAL  = "?"
+)
+
+// SPFMechanism is a mechanism of an SPF record, e.g. "-all" or
+// "include:_spf.example.com".
+type SPFMechanism struct {
+	// Qualifier is one of SPF_PASS (the default if empty), SPF_FAIL,
+	// SPF_SOFTFAIL and SPF_NEUTRAL.
+	Qualifier string
+	// Kind is one of "all", "include", "a", "mx", "ptr", "ip4", "ip6" and
+	// "exists".
+	Kind string
+	// Value is the domain or network of the mechanism, possibly followed by
+	// CIDR lengths for "a" and "mx" (e.g. "example.com/24").
+	Value string
+}
+
+func (mechanism SPFMechanism) String() string {
+	s := mechanism.Kind
+	if mechanism.Qualifier != "" && mechanism.Qualifier != SPF_PASS {
+		s = mechanism.Qualifier + s
+	}
+	if mechanism.Value != "" {
+		if strings.HasPrefix(mechanism.Value, "/") {
+			return s + mechanism.Value
+		}
+		return s + ":" + mechanism.Value
+	}
+	return s
+}
+
+// Lookup returns true if evaluating the mechanism needs a DNS lookup.
+func (mechanism SPFMechanism) Lookup() bool {
+	switch mechanism.Kind {
+	case "include", "a", "mx", "ptr", "exists":
+		return true
+	}
+	return false
+}
+
+// SPF is an SPF policy (RFC 7208).
+type SPF struct {
+	Mechanisms []SPFMechanism
+	// Redirect is the domain of the redirect= modifier.
+	Redirect string
+	// Explanation is the domain of the exp= modifier.
+	Explanation string
+}
+
+// NewSPF creates an empty SPF policy, to be built with its methods, e.g.
+// NewSPF().MX("").Include("_spf.example.net").All(SPF_FAIL).
+func NewSPF() *SPF {
+	return &SPF{}
+}
+
+func (spf *SPF) add(kind string, value string) *SPF {
+	spf.Mechanisms = append(spf.Mechanisms, SPFMechanism{Kind: kind, Value: value})
+	return spf
+}
+
+// Include adds an include:domain mechanism.
+func (spf *SPF) Include(domain string) *SPF { return spf.add("include", domain) }
+
+// A adds an a mechanism, for domain or the current domain if empty.
+func (spf *SPF) A(domain string) *SPF { return spf.add("a", domain) }
+
+// MX adds an mx mechanism, for domain or the current domain if empty.
+func (spf *SPF) MX(domain string) *SPF { return spf.add("mx", domain) }
+
+// IP adds an ip4 or ip6 mechanism for an address or a CIDR network.
+func (spf *SPF) IP(network string) *SPF {
+	address := network
+	if i := strings.Index(address, "/"); i >= 0 {
+		address = address[:i]
+	}
+	if ip := net.ParseIP(address); ip != nil && ip.To4() == nil {
+		return spf.add("ip6", network)
+	}
+	return spf.add("ip4", network)
+}
+
+// All adds the final all mechanism with qualifier.
+func (spf *SPF) All(qualifier string) *SPF {
+	spf.Mechanisms = append(spf.Mechanisms, SPFMechanism{Qualifier: qualifier, Kind: "all"})
+	return spf
+}
+
+// String returns the text of the SPF record.
+func (spf *SPF) String() string {
+	terms := []string{"v=spf1"}
+	for _, mechanism := range spf.Mechanisms {
+		terms = append(terms, mechanism.String())
+	}
+	if spf.Redirect != "" {
+		terms = append(terms, "redirect="+spf.Redirect)
+	}
+	if spf.Explanation != "" {
+		terms = append(terms, "exp="+spf.Explanation)
+	}
+	return strings.Join(terms, " ")
+}
+
+// Record returns the TXT record of the policy for name ("@" for the apex) in
+// the zone zoneId.
+func (spf *SPF) Record(zoneId string, name string) hetzner_dns.RecordRequest {
+	return txtRecord(zoneId, name, spf.String())
+}
+
+// Includes returns the domains of the include mechanisms and of the redirect
+// modifier.
+func (spf *SPF) Includes() []string {
+	var domains []string
+	for _, mechanism := range spf.Mechanisms {
+		if mechanism.Kind == "include" {
+			domains = append(domains, mechanism.Value)
+		}
+	}
+	if spf.Redirect != "" {
+		domains = append(domains, spf.Redirect)
+	}
+	return domains
+}
+
+// SPFResolver returns the SPF policy of domain, and false if it is unknown.
+type SPFResolver func(domain string) (*SPF, bool)
+
+// Lookups counts the DNS lookups needed to evaluate the policy, following
+// includes and redirects with resolve (if set). complete is false if some of
+// them could not be resolved: they count as one lookup each.
+func (spf *SPF) Lookups(resolve SPFResolver) (count int, complete bool) {
+	return spf.lookups(resolve, map[string]bool{})
+}
+
+func (spf *SPF) lookups(resolve SPFResolver, visited map[string]bool) (int, bool) {
+	count, complete := 0, true
+	for _, mechanism := range spf.Mechanisms {
+		if mechanism.Lookup() {
+			count++
+		}
+	}
+	if spf.Redirect != "" {
+		count++
+	}
+	for _, domain := range spf.Includes() {
+		key := strings.ToLower(strings.TrimSuffix(domain, "."))
+		if visited[key] {
+			continue
+		}
+		visited[key] = true
+		var nested *SPF
+		ok := false
+		if resolve != nil {
+			nested, ok = resolve(domain)
+		}
+		if !ok {
+			complete = false
+			continue
+		}
+		nestedCount, nestedComplete := nested.lookups(resolve, visited)
+		count += nestedCount
+		complete = complete && nestedComplete
+	}
+	return count, complete
+}
+
+// IsSPF returns true if text is an SPF record.
+func IsSPF(text string) bool {
+	return strings.EqualFold(text, "v=spf1") || strings.HasPrefix(strings.ToLower(text), "v=spf1 ")
+}
+
+// ParseSPF parses the text of an SPF record.
+func ParseSPF(text string) (*SPF, error) {
+	if !IsSPF(text) {
+		return nil, errors.Errorf("mailauth: not an SPF record: %q", text)
+	}
+	spf := &SPF{}
+	for _, term := range strings.Fields(text)[1:] {
+		lower := strings.ToLower(term)
+		if strings.HasPrefix(lower, "redirect=") {
+			spf.Redirect = term[len("redirect="):]
+			continue
+		}
+		if strings.HasPrefix(lower, "exp=") {
+			spf.Explanation = term[len("exp="):]
+			continue
+		}
+
+		mechanism := SPFMechanism{Qualifier: SPF_PASS}
+		if strings.ContainsAny(term[:1], "+-~?") {
+			mechanism.Qualifier, term = term[:1], term[1:]
+		}
+		kind := term
+		if i := strings.IndexAny(term, ":/"); i >= 0 {
+			kind, mechanism.Value = term[:i], term[i:]
+			mechanism.Value = strings.TrimPrefix(mechanism.Value, ":")
+		}
+		mechanism.Kind = strings.ToLower(kind)
+		switch mechanism.Kind {
+		case "all":
+			if mechanism.Value != "" {
+				return nil, errors.Errorf("mailauth: invalid SPF term %q", term)
+			}
+		case "include", "exists", "ip4", "ip6":
+			if mechanism.Value == "" {
+				return nil, errors.Errorf("mailauth: SPF mechanism %s needs a value", mechanism.Kind)
+			}
+		case "a", "mx", "ptr":
+		default:
+			if strings.Contains(term, "=") {
+				// unknown modifiers must be ignored (RFC 7208, section 6)
+				continue
+			}
+			return nil, errors.Errorf("mailauth: unknown SPF mechanism %q", term)
+		}
+		spf.Mechanisms = append(spf.Mechanisms, mechanism)
+	}
+	return spf, nil
+}
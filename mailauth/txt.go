@@ -0,0 +1,102 @@
+// Package mailauth builds and parses the DNS records used to authenticate
+// email: SPF, DKIM, DMARC, MTA-STS and TLS-RPT.
+package mailauth
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+)
+
+// MAX_TXT_CHUNK is the maximum length of a string of a TXT record.
+const MAX_TXT_CHUNK = 255
+
+// TXTValue returns the value of a TXT record holding text, split into quoted
+// strings of at most MAX_TXT_CHUNK characters when needed.
+func TXTValue(text string) string {
+	if len(text) <= MAX_TXT_CHUNK {
+		return text
+	}
+	var chunks []string
+	for len(text) > 0 {
+		n := MAX_TXT_CHUNK
+		if n > len(text) {
+			n = len(text)
+		}
+		chunks = append(chunks, fmt.Sprintf("%q", text[:n]))
+		text = text[n:]
+	}
+	return strings.Join(chunks, " ")
+}
+
+// TXTText returns the text of the value of a TXT record, joining its quoted
+// strings. Unquoted values are returned as they are.
+func TXTText(value string) string {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, `"`) {
+		return value
+	}
+	var sb strings.Builder
+	inQuotes, escaped := false, false
+	for _, c := range value {
+		switch {
+		case escaped:
+			sb.WriteRune(c)
+			escaped = false
+		case c == '\\' && inQuotes:
+			escaped = true
+		case c == '"':
+			inQuotes = !inQuotes
+		case inQuotes:
+			sb.WriteRune(c)
+		}
+	}
+	return sb.String()
+}
+
+func txtRecord(zoneId string, name string, text string) hetzner_dns.RecordRequest {
+	return hetzner_dns.RecordRequest{ZoneID: zoneId, Type: "TXT", Name: name, Value: TXTValue(text)}
+}
+
+// tags parses a tag list ("v=DMARC1; p=none"), as used by DKIM, DMARC,
+// MTA-STS and TLS-RPT records, checking that the first tag is v=version.
+func tags(text string, version string) (map[string]string, error) {
+	result := map[string]string{}
+	first := true
+	for _, part := range strings.Split(text, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		i := strings.Index(part, "=")
+		if i < 0 {
+			return nil, errors.Errorf("mailauth: invalid tag %q", part)
+		}
+		name, value := strings.TrimSpace(part[:i]), strings.TrimSpace(part[i+1:])
+		if first && (name != "v" || value != version) {
+			return nil, errors.Errorf("mailauth: expected v=%s, got %q", version, part)
+		}
+		if _, ok := result[name]; ok {
+			return nil, errors.Errorf("mailauth: duplicate tag %q", name)
+		}
+		result[name] = value
+		first = false
+	}
+	if first {
+		return nil, errors.Errorf("mailauth: expected v=%s, got an empty record", version)
+	}
+	return result, nil
+}
+
+func splitCommas(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
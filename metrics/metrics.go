@@ -0,0 +1,194 @@
+// Package metrics exposes Prometheus metrics about the zones of an Hetzner
+// DNS account and about the API calls performed by a Client.
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+)
+
+const (
+	NAMESPACE              = "hetzner_dns"
+	DEFAULT_SCRAPE_TIMEOUT = time.Second * 30
+)
+
+// ZoneLister lists the zones of an account. Both *hetzner_dns.Client and
+// *hetzner_dns.CachedClient satisfy it; the latter limits API usage when
+// scrapes are frequent.
+type ZoneLister interface {
+	GetAllZones(ctx context.Context) ([]hetzner_dns.Zone, error)
+}
+
+// ZoneCollector is a prometheus.Collector exposing per-zone gauges, fetching
+// the zones at each scrape.
+type ZoneCollector struct {
+	Lister  ZoneLister
+	Timeout time.Duration
+
+	up           *prometheus.Desc
+	records      *prometheus.Desc
+	paused       *prometheus.Desc
+	status       *prometheus.Desc
+	verified     *prometheus.Desc
+	modifiedAge  *prometheus.Desc
+	secondaryDNS *prometheus.Desc
+}
+
+// NewZoneCollector creates a collector for the zones listed by lister.
+func NewZoneCollector(lister ZoneLister) *ZoneCollector {
+	zoneLabels := []string{"zone", "zone_id"}
+	return &ZoneCollector{
+		Lister:  lister,
+		Timeout: DEFAULT_SCRAPE_TIMEOUT,
+
+		up: prometheus.NewDesc(prometheus.BuildFQName(NAMESPACE, "", "up"),
+			"Whether the last zone listing succeeded.", nil, nil),
+		records: prometheus.NewDesc(prometheus.BuildFQName(NAMESPACE, "zone", "records"),
+			"Number of records of the zone.", zoneLabels, nil),
+		paused: prometheus.NewDesc(prometheus.BuildFQName(NAMESPACE, "zone", "paused"),
+			"Whether the zone is paused.", zoneLabels, nil),
+		status: prometheus.NewDesc(prometheus.BuildFQName(NAMESPACE, "zone", "status"),
+			"Status of the zone (1 for the current status).", append(zoneLabels, "status"), nil),
+		verified: prometheus.NewDesc(prometheus.BuildFQName(NAMESPACE, "zone", "verified"),
+			"Whether the zone ownership has been verified.", zoneLabels, nil),
+		modifiedAge: prometheus.NewDesc(prometheus.BuildFQName(NAMESPACE, "zone", "last_modified_age_seconds"),
+			"Seconds since the zone was last modified.", zoneLabels, nil),
+		secondaryDNS: prometheus.NewDesc(prometheus.BuildFQName(NAMESPACE, "zone", "secondary_dns"),
+			"Whether the zone is a secondary zone.", zoneLabels, nil),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (collector *ZoneCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- collector.up
+	ch <- collector.records
+	ch <- collector.paused
+	ch <- collector.status
+	ch <- collector.verified
+	ch <- collector.modifiedAge
+	ch <- collector.secondaryDNS
+}
+
+// Collect implements prometheus.Collector.
+func (collector *ZoneCollector) Collect(ch chan<- prometheus.Metric) {
+	timeout := collector.Timeout
+	if timeout <= 0 {
+		timeout = DEFAULT_SCRAPE_TIMEOUT
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	zones, err := collector.Lister.GetAllZones(ctx)
+	if err != nil {
+		ch <- prometheus.MustNewConstMetric(collector.up, prometheus.GaugeValue, 0)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(collector.up, prometheus.GaugeValue, 1)
+
+	now := time.Now()
+	for _, zone := range zones {
+		labels := []string{zone.Name, zone.ID}
+		ch <- prometheus.MustNewConstMetric(collector.records, prometheus.GaugeValue, float64(zone.RecordsCount), labels...)
+		ch <- prometheus.MustNewConstMetric(collector.paused, prometheus.GaugeValue, boolValue(zone.Paused), labels...)
+		ch <- prometheus.MustNewConstMetric(collector.status, prometheus.GaugeValue, 1, append(labels, zone.Status)...)
+		ch <- prometheus.MustNewConstMetric(collector.verified, prometheus.GaugeValue, boolValue(!time.Time(zone.Verified).IsZero()), labels...)
+		ch <- prometheus.MustNewConstMetric(collector.secondaryDNS, prometheus.GaugeValue, boolValue(zone.IsSecondaryDNS), labels...)
+		if modified := time.Time(zone.Modified); !modified.IsZero() {
+			ch <- prometheus.MustNewConstMetric(collector.modifiedAge, prometheus.GaugeValue, now.Sub(modified).Seconds(), labels...)
+		}
+	}
+}
+
+// ClientMetrics counts the API calls of instrumented clients and measures
+// their latency, by HTTP method, endpoint template and status code.
+type ClientMetrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+// NewClientMetrics creates the client metrics. They must be registered with
+// a prometheus.Registerer to be exposed.
+func NewClientMetrics() *ClientMetrics {
+	labels := []string{"method", "endpoint", "code"}
+	return &ClientMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: NAMESPACE,
+			Subsystem: "client",
+			Name:      "requests_total",
+			Help:      "Number of API requests performed.",
+		}, labels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: NAMESPACE,
+			Subsystem: "client",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of API requests.",
+			Buckets:   prometheus.DefBuckets,
+		}, labels),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (clientMetrics *ClientMetrics) Describe(ch chan<- *prometheus.Desc) {
+	clientMetrics.requests.Describe(ch)
+	clientMetrics.duration.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (clientMetrics *ClientMetrics) Collect(ch chan<- prometheus.Metric) {
+	clientMetrics.requests.Collect(ch)
+	clientMetrics.duration.Collect(ch)
+}
+
+// InstrumentClient makes client report its API calls to clientMetrics, by
+// wrapping the transport of its HttpClient (created if not set).
+func (clientMetrics *ClientMetrics) InstrumentClient(client *hetzner_dns.Client) {
+	if client.HttpClient == nil {
+		client.HttpClient = &http.Client{
+			Timeout: hetzner_dns.DEFAULT_TIMEOUT,
+		}
+	}
+	client.HttpClient.Transport = clientMetrics.RoundTripper(client.HttpClient.Transport)
+}
+
+// RoundTripper returns an http.RoundTripper instrumenting next (or
+// http.DefaultTransport if nil).
+func (clientMetrics *ClientMetrics) RoundTripper(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		start := time.Now()
+		resp, err := next.RoundTrip(req)
+		code := "error"
+		if err == nil {
+			code = strconv.Itoa(resp.StatusCode)
+		}
+		labels := prometheus.Labels{
+			"method":   req.Method,
+			"endpoint": hetzner_dns.EndpointTemplate(req.URL.Path),
+			"code":     code,
+		}
+		clientMetrics.requests.With(labels).Inc()
+		clientMetrics.duration.With(labels).Observe(time.Since(start).Seconds())
+		return resp, err
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (fn roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return fn(req)
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
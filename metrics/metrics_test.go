@@ -0,0 +1,70 @@
+package metrics_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+	"github.com/panta/go-hetzner-dns/metrics"
+)
+
+func TestMetrics(t *testing.T) {
+	hs := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/zones":
+			_, _ = io.WriteString(rw, `{
+  "zones": [
+    {"id": "zone-1", "name": "example.com", "status": "verified", "paused": false, "records_count": 7, "verified": "2021-01-28T14:23:31Z"},
+    {"id": "zone-2", "name": "example.org", "status": "failed", "paused": true, "records_count": 2}
+  ],
+  "meta": {"pagination": {"page": 1, "per_page": 100, "last_page": 1, "total_entries": 2}}
+}`)
+		default:
+			http.NotFound(rw, req)
+		}
+	}))
+	defer hs.Close()
+
+	client := &hetzner_dns.Client{BaseURL: hs.URL, ApiKey: "dummy"}
+	clientMetrics := metrics.NewClientMetrics()
+	clientMetrics.InstrumentClient(client)
+
+	collector := metrics.NewZoneCollector(client)
+	expected := `
+# HELP hetzner_dns_zone_records Number of records of the zone.
+# TYPE hetzner_dns_zone_records gauge
+hetzner_dns_zone_records{zone="example.com",zone_id="zone-1"} 7
+hetzner_dns_zone_records{zone="example.org",zone_id="zone-2"} 2
+# HELP hetzner_dns_zone_paused Whether the zone is paused.
+# TYPE hetzner_dns_zone_paused gauge
+hetzner_dns_zone_paused{zone="example.com",zone_id="zone-1"} 0
+hetzner_dns_zone_paused{zone="example.org",zone_id="zone-2"} 1
+# HELP hetzner_dns_zone_verified Whether the zone ownership has been verified.
+# TYPE hetzner_dns_zone_verified gauge
+hetzner_dns_zone_verified{zone="example.com",zone_id="zone-1"} 1
+hetzner_dns_zone_verified{zone="example.org",zone_id="zone-2"} 0
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected),
+		"hetzner_dns_zone_records", "hetzner_dns_zone_paused", "hetzner_dns_zone_verified"); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := client.GetRecord(context.Background(), "rec-1"); err == nil {
+		t.Fatal("expected an error for a missing record")
+	}
+	expected = `
+# HELP hetzner_dns_client_requests_total Number of API requests performed.
+# TYPE hetzner_dns_client_requests_total counter
+hetzner_dns_client_requests_total{code="200",endpoint="/zones",method="GET"} 1
+hetzner_dns_client_requests_total{code="404",endpoint="/records/{id}",method="GET"} 1
+`
+	if err := testutil.CollectAndCompare(clientMetrics, strings.NewReader(expected), "hetzner_dns_client_requests_total"); err != nil {
+		t.Error(err)
+	}
+}
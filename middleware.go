@@ -0,0 +1,80 @@
+package hetzner_dns
+
+import "context"
+
+// Names of the logical operations seen by middlewares.
+const (
+	OP_GET_ZONES           = "GetZones"
+	OP_GET_ZONE            = "GetZone"
+	OP_CREATE_ZONE         = "CreateZone"
+	OP_UPDATE_ZONE         = "UpdateZone"
+	OP_DELETE_ZONE         = "DeleteZone"
+	OP_GET_RECORDS         = "GetRecords"
+	OP_GET_RECORD          = "GetRecord"
+	OP_CREATE_RECORD       = "CreateRecord"
+	OP_UPDATE_RECORD       = "UpdateRecord"
+	OP_DELETE_RECORD       = "DeleteRecord"
+	OP_BULK_CREATE_RECORDS = "BulkCreateRecords"
+	OP_BULK_UPDATE_RECORDS = "BulkUpdateRecords"
+)
+
+// Operation is a logical API call, as seen by middlewares. Request is the
+// payload (e.g. a *RecordRequest for CreateRecord, nil for reads and
+// deletes) and Response the value the response is decoded into (e.g. a
+// *RecordResponse); it is filled in once the call has been performed.
+type Operation struct {
+	Name     string
+	Method   string
+	Endpoint string
+	// ID is the zone or record the operation targets, if any.
+	ID       string
+	Query    interface{}
+	Request  interface{}
+	Response interface{}
+}
+
+// Mutating returns true if the operation changes zones or records.
+func (op *Operation) Mutating() bool {
+	switch op.Name {
+	case OP_CREATE_ZONE, OP_UPDATE_ZONE, OP_DELETE_ZONE,
+		OP_CREATE_RECORD, OP_UPDATE_RECORD, OP_DELETE_RECORD,
+		OP_BULK_CREATE_RECORDS, OP_BULK_UPDATE_RECORDS:
+		return true
+	}
+	return false
+}
+
+// Handler performs an operation, returning a *APIError if the API rejected
+// it.
+type Handler func(ctx context.Context, op *Operation) error
+
+// Middleware wraps a Handler. It can inspect or change the operation before
+// calling next, inspect the response or error after it, or not call next at
+// all to short-circuit the call.
+type Middleware func(next Handler) Handler
+
+// Use appends middlewares to the chain of the client. The first middleware
+// added is the outermost one: it sees operations first and results last.
+func (client *Client) Use(middlewares ...Middleware) {
+	client.Middlewares = append(client.Middlewares, middlewares...)
+}
+
+// do runs op through the middleware chain, ending with Perform (or with the
+// dry-run of mutating operations, if enabled).
+func (client *Client) do(ctx context.Context, op *Operation) error {
+	handler := Handler(func(ctx context.Context, op *Operation) error {
+		if limiter := rateLimiter(ctx); limiter != nil {
+			if err := limiter.Wait(ctx); err != nil {
+				return err
+			}
+		}
+		if client.DryRun != nil && op.Mutating() {
+			return client.performDryRun(ctx, op)
+		}
+		return client.Perform(ctx, op.Method, op.Endpoint, op.Query, op.Request, op.Response)
+	})
+	for i := len(client.Middlewares) - 1; i >= 0; i-- {
+		handler = client.Middlewares[i](handler)
+	}
+	return handler(ctx, op)
+}
@@ -0,0 +1,102 @@
+package hetzner_dns_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+	"github.com/panta/go-hetzner-dns/internal/fakeapi"
+)
+
+func TestMiddleware(t *testing.T) {
+	api := fakeapi.New(t)
+	defer api.Close()
+	api.AddZone("zone-1", "example.com")
+
+	client := api.Client()
+	var trace []string
+	var seenRequest *hetzner_dns.RecordRequest
+	var seenResponse *hetzner_dns.RecordResponse
+	var seenError error
+	client.Use(
+		func(next hetzner_dns.Handler) hetzner_dns.Handler {
+			return func(ctx context.Context, op *hetzner_dns.Operation) error {
+				trace = append(trace, "outer:"+op.Name)
+				err := next(ctx, op)
+				trace = append(trace, "outer:done")
+				seenError = err
+				return err
+			}
+		},
+		func(next hetzner_dns.Handler) hetzner_dns.Handler {
+			return func(ctx context.Context, op *hetzner_dns.Operation) error {
+				trace = append(trace, "inner:"+op.Name)
+				if request, ok := op.Request.(*hetzner_dns.RecordRequest); ok {
+					request.TTL = 600
+					seenRequest = request
+				}
+				err := next(ctx, op)
+				if response, ok := op.Response.(*hetzner_dns.RecordResponse); ok && err == nil {
+					seenResponse = response
+				}
+				trace = append(trace, "inner:done")
+				return err
+			}
+		},
+	)
+
+	recordResponse, err := client.CreateRecord(context.Background(), hetzner_dns.RecordRequest{ZoneID: "zone-1", Name: "www", Type: "A", Value: "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"outer:CreateRecord", "inner:CreateRecord", "inner:done", "outer:done"}
+	if len(trace) != len(expected) {
+		t.Fatalf("unexpected trace %v", trace)
+	}
+	for i := range expected {
+		if trace[i] != expected[i] {
+			t.Fatalf("unexpected trace %v", trace)
+		}
+	}
+	if seenRequest == nil || seenRequest.Name != "www" {
+		t.Errorf("unexpected request %+v", seenRequest)
+	}
+	if seenResponse == nil || seenResponse.Record.ID != recordResponse.Record.ID {
+		t.Errorf("unexpected response %+v", seenResponse)
+	}
+	if recordResponse.Record.TTL != 600 {
+		t.Errorf("expected the middleware to change the payload, got TTL %d", recordResponse.Record.TTL)
+	}
+
+	_, err = client.GetRecord(context.Background(), "missing")
+	var apiError *hetzner_dns.APIError
+	if !errors.As(seenError, &apiError) || !errors.As(err, &apiError) {
+		t.Fatalf("expected an APIError, got %v", err)
+	}
+	if !apiError.NotFound() || apiError.Message != "record not found" {
+		t.Errorf("unexpected error %+v", apiError)
+	}
+}
+
+func TestMiddlewareShortCircuit(t *testing.T) {
+	api := fakeapi.New(t)
+	defer api.Close()
+
+	client := api.Client()
+	client.Use(func(next hetzner_dns.Handler) hetzner_dns.Handler {
+		return func(ctx context.Context, op *hetzner_dns.Operation) error {
+			if op.Mutating() {
+				return errors.New("read-only client")
+			}
+			return next(ctx, op)
+		}
+	})
+	if err := client.DeleteRecord(context.Background(), "rec-1"); err == nil {
+		t.Error("expected the middleware to reject the call")
+	}
+	if count := api.CallCount("DELETE"); count != 0 {
+		t.Errorf("expected no API call, got %d", count)
+	}
+}
@@ -0,0 +1,309 @@
+// Package migrate copies zones into an Hetzner DNS account, from another
+// account or from other providers (BIND zone files, JSON or CSV exports),
+// verifying the result.
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+)
+
+// MIN_TTL is the minimum TTL accepted by the API.
+const MIN_TTL = 60
+
+// DEFAULT_ZONE_TTL is the default TTL of the zones created by Migrate, when
+// the source doesn't tell it.
+const DEFAULT_ZONE_TTL = 86400
+
+// Options configures a migration.
+type Options struct {
+	// Zone is the name of the destination zone (the source zone name if
+	// empty). It is created if it doesn't exist.
+	Zone string
+	// TTL is the default TTL of the destination zone, when created (the
+	// source zone TTL, or DEFAULT_ZONE_TTL, if zero).
+	TTL int
+	// DryRun only reports the changes, without applying them.
+	DryRun bool
+}
+
+// SkippedRecord is a source record not migrated.
+type SkippedRecord struct {
+	Record hetzner_dns.RecordRequest `json:"record"`
+	Reason string                    `json:"reason"`
+}
+
+// Report is the outcome of a migration.
+type Report struct {
+	Time        time.Time `json:"time"`
+	Source      string    `json:"source"`
+	SourceZone  string    `json:"source_zone"`
+	Zone        string    `json:"zone"`
+	ZoneID      string    `json:"zone_id"`
+	ZoneCreated bool      `json:"zone_created"`
+	// ZoneMissing is set by dry runs when the zone would be created.
+	ZoneMissing bool `json:"zone_missing"`
+	DryRun      bool `json:"dry_run"`
+	// Read is the number of records read from the source.
+	Read int `json:"read"`
+	// Created and Updated are the records created and updated (their TTL
+	// differing) in the destination zone, Unchanged those already there.
+	Created   []hetzner_dns.RecordRequest `json:"created"`
+	Updated   []hetzner_dns.RecordRequest `json:"updated"`
+	Unchanged []hetzner_dns.RecordRequest `json:"unchanged"`
+	Skipped   []SkippedRecord             `json:"skipped"`
+	// Missing are the records not found when verifying the destination
+	// zone.
+	Missing  []hetzner_dns.RecordRequest `json:"missing"`
+	Verified bool                        `json:"verified"`
+}
+
+// String returns a human readable report.
+func (report *Report) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Migration of %s from %s to %s", report.SourceZone, report.Source, report.Zone)
+	if report.ZoneID != "" {
+		fmt.Fprintf(&sb, " (%s)", report.ZoneID)
+	}
+	if report.DryRun {
+		sb.WriteString(" [dry run]")
+	}
+	sb.WriteString("\n")
+	switch {
+	case report.ZoneCreated:
+		sb.WriteString("zone created\n")
+	case report.ZoneMissing:
+		sb.WriteString("zone would be created\n")
+	}
+	fmt.Fprintf(&sb, "%d records read, %d created, %d updated, %d unchanged, %d skipped\n",
+		report.Read, len(report.Created), len(report.Updated), len(report.Unchanged), len(report.Skipped))
+	for _, record := range report.Created {
+		fmt.Fprintf(&sb, "+ %s\n", hetzner_dns.DescribeRecord(record.Name, record.Type, record.Value, record.TTL))
+	}
+	for _, record := range report.Updated {
+		fmt.Fprintf(&sb, "~ %s\n", hetzner_dns.DescribeRecord(record.Name, record.Type, record.Value, record.TTL))
+	}
+	for _, skipped := range report.Skipped {
+		fmt.Fprintf(&sb, "- %s (%s)\n", hetzner_dns.DescribeRecord(skipped.Record.Name, skipped.Record.Type, skipped.Record.Value, skipped.Record.TTL), skipped.Reason)
+	}
+	switch {
+	case report.DryRun:
+	case report.Verified:
+		sb.WriteString("verified: all records found in the destination zone\n")
+	default:
+		fmt.Fprintf(&sb, "VERIFICATION FAILED: %d records missing\n", len(report.Missing))
+		for _, record := range report.Missing {
+			fmt.Fprintf(&sb, "! %s\n", hetzner_dns.DescribeRecord(record.Name, record.Type, record.Value, record.TTL))
+		}
+	}
+	return sb.String()
+}
+
+// Migrate copies the records of the zone zoneName from source to client.
+// Records are normalized (names relative to the zone, TTLs equal to the
+// zone default inherited), SOA and apex NS records are skipped, and the
+// records missing in the destination zone are created with a bulk call.
+// Records of the destination zone not in the source are left untouched.
+// The destination zone is then read again to verify the migration: a
+// report is returned even if it fails.
+func Migrate(ctx context.Context, client *hetzner_dns.Client, source Source, zoneName string, options Options) (*Report, error) {
+	sourceZone, err := source.Read(ctx, zoneName)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{
+		Time:       time.Now().UTC(),
+		Source:     source.String(),
+		SourceZone: sourceZone.Name,
+		Zone:       options.Zone,
+		DryRun:     options.DryRun,
+		Read:       len(sourceZone.Records),
+	}
+	if report.Zone == "" {
+		report.Zone = sourceZone.Name
+	}
+
+	zoneTTL := options.TTL
+	if zoneTTL == 0 {
+		zoneTTL = sourceZone.TTL
+	}
+	if zoneTTL == 0 {
+		zoneTTL = DEFAULT_ZONE_TTL
+	}
+	var current []hetzner_dns.Record
+	zone, err := findZone(ctx, client, report.Zone)
+	if err != nil {
+		return nil, err
+	}
+	if zone != nil {
+		report.ZoneID, zoneTTL = zone.ID, zone.TTL
+		if current, err = client.GetAllRecords(ctx, zone.ID); err != nil {
+			return nil, errors.Wrapf(err, "can't get records for zone %s", report.Zone)
+		}
+	}
+
+	desired := normalize(sourceZone, zoneTTL, report)
+	existing := map[string]*hetzner_dns.Record{}
+	for i := range current {
+		existing[hetzner_dns.RecordKey(current[i].Name, current[i].Type, current[i].Value)] = &current[i]
+	}
+	var updates []hetzner_dns.RecordRequest
+	for _, record := range desired {
+		other, ok := existing[hetzner_dns.RecordKey(record.Name, record.Type, record.Value)]
+		switch {
+		case !ok:
+			report.Created = append(report.Created, record)
+		case other.TTL != record.TTL:
+			record.ID = other.ID
+			updates = append(updates, record)
+			report.Updated = append(report.Updated, record)
+		default:
+			report.Unchanged = append(report.Unchanged, record)
+		}
+	}
+
+	if options.DryRun {
+		report.ZoneMissing = zone == nil
+		return report, nil
+	}
+	if zone == nil {
+		zoneResponse, err := client.CreateZone(ctx, hetzner_dns.ZoneRequest{Name: report.Zone, TTL: zoneTTL})
+		if err != nil {
+			return report, errors.Wrapf(err, "can't create zone %s", report.Zone)
+		}
+		report.ZoneID, report.ZoneCreated = zoneResponse.Zone.ID, true
+	}
+	if err := apply(ctx, client, report.ZoneID, report.Created, updates); err != nil {
+		return report, errors.Wrapf(err, "can't migrate records to zone %s", report.Zone)
+	}
+	return report, verify(ctx, client, desired, report)
+}
+
+// normalize returns the records of sourceZone to migrate, adding the
+// skipped ones to report.
+func normalize(sourceZone *SourceZone, zoneTTL int, report *Report) []hetzner_dns.RecordRequest {
+	origin := hetzner_dns.NormalizeZoneName(sourceZone.Name)
+	seen := map[string]bool{}
+	var records []hetzner_dns.RecordRequest
+	for _, record := range sourceZone.Records {
+		skip := func(reason string) {
+			report.Skipped = append(report.Skipped, SkippedRecord{Record: record, Reason: reason})
+		}
+		normalized := hetzner_dns.RecordRequest{
+			Type:  strings.ToUpper(strings.TrimSpace(record.Type)),
+			Value: strings.TrimSpace(record.Value),
+			TTL:   record.TTL,
+		}
+		name, ok := relativeName(record.Name, origin)
+		if !ok {
+			skip("name outside of the zone")
+			continue
+		}
+		normalized.Name = name
+		switch {
+		case normalized.Type == "" || normalized.Value == "":
+			skip("missing type or value")
+			continue
+		case normalized.Type == "SOA":
+			skip("SOA records are managed by Hetzner")
+			continue
+		case normalized.Type == "NS" && name == "@":
+			skip("apex NS records are managed by Hetzner")
+			continue
+		}
+
+		if normalized.TTL == 0 {
+			normalized.TTL = sourceZone.TTL
+		}
+		switch {
+		case normalized.TTL == zoneTTL:
+			normalized.TTL = 0
+		case normalized.TTL > 0 && normalized.TTL < MIN_TTL:
+			normalized.TTL = MIN_TTL
+		}
+
+		key := hetzner_dns.RecordKey(normalized.Name, normalized.Type, normalized.Value)
+		if seen[key] {
+			skip("duplicate")
+			continue
+		}
+		seen[key] = true
+		records = append(records, normalized)
+	}
+	return records
+}
+
+func apply(ctx context.Context, client *hetzner_dns.Client, zoneId string, creates []hetzner_dns.RecordRequest, updates []hetzner_dns.RecordRequest) error {
+	for _, batch := range []struct {
+		records []hetzner_dns.RecordRequest
+		call    func(context.Context, *hetzner_dns.BulkRecordRequest) (*hetzner_dns.BulkRecordResponse, error)
+	}{
+		{creates, client.BulkCreateRecords},
+		{updates, client.BulkUpdateRecords},
+	} {
+		if len(batch.records) == 0 {
+			continue
+		}
+		bulk := &hetzner_dns.BulkRecordRequest{}
+		for _, record := range batch.records {
+			record.ZoneID = zoneId
+			bulk.Records = append(bulk.Records, record)
+		}
+		response, err := batch.call(ctx, bulk)
+		if err != nil {
+			return err
+		}
+		if len(response.InvalidRecords) > 0 || len(response.FailedRecords) > 0 {
+			return errors.Errorf("migrate: %d invalid and %d failed records",
+				len(response.InvalidRecords), len(response.FailedRecords))
+		}
+	}
+	return nil
+}
+
+// verify reads the destination zone again, checking that all the desired
+// records are there.
+func verify(ctx context.Context, client *hetzner_dns.Client, desired []hetzner_dns.RecordRequest, report *Report) error {
+	current, err := client.GetAllRecords(ctx, report.ZoneID)
+	if err != nil {
+		return errors.Wrapf(err, "can't verify zone %s", report.Zone)
+	}
+	found := map[string]int{}
+	for _, record := range current {
+		found[hetzner_dns.RecordKey(record.Name, record.Type, record.Value)] = record.TTL
+	}
+	for _, record := range desired {
+		if ttl, ok := found[hetzner_dns.RecordKey(record.Name, record.Type, record.Value)]; !ok || ttl != record.TTL {
+			report.Missing = append(report.Missing, record)
+		}
+	}
+	report.Verified = len(report.Missing) == 0
+	if !report.Verified {
+		return errors.Errorf("migrate: verification failed, %d records missing in zone %s", len(report.Missing), report.Zone)
+	}
+	return nil
+}
+
+// relativeName returns name relative to origin ("@" for the apex), and
+// false if it is outside of the zone. Names without a trailing dot are
+// already relative, unless they end with the origin.
+func relativeName(name string, origin string) (string, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	absolute := strings.HasSuffix(name, ".")
+	name = strings.TrimSuffix(name, ".")
+	switch {
+	case name == "" || name == "@" || name == origin:
+		return "@", true
+	case strings.HasSuffix(name, "."+origin):
+		return strings.TrimSuffix(name, "."+origin), true
+	case absolute:
+		return "", false
+	}
+	return name, true
+}
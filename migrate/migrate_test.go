@@ -0,0 +1,182 @@
+package migrate_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+	"github.com/panta/go-hetzner-dns/internal/fakeapi"
+	"github.com/panta/go-hetzner-dns/migrate"
+)
+
+const zoneFile = `$ORIGIN example.com.
+$TTL 3600
+@       IN SOA ns1.example.net. hostmaster 1 86400 10800 3600000 3600
+@       IN NS  ns1.example.net.
+@       IN A   192.0.2.1
+www     300 IN CNAME example.com.
+sub     IN NS  ns.sub.example.com.
+mail    30 IN A  192.0.2.25
+@       MX 10 mail
+`
+
+func TestMigrateZoneFile(t *testing.T) {
+	destination := fakeapi.New(t)
+	defer destination.Close()
+	client := destination.Client()
+
+	dir, err := ioutil.TempDir("", "migrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "example.com.zone")
+	if err := ioutil.WriteFile(path, []byte(zoneFile), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	source := &migrate.ZoneFileSource{Path: path}
+
+	report, err := migrate.Migrate(context.Background(), client, source, "example.com", migrate.Options{TTL: 3600, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.DryRun || report.ZoneCreated || !report.ZoneMissing || len(destination.Zones()) != 0 || len(report.Created) != 5 || len(report.Skipped) != 2 {
+		t.Fatalf("unexpected dry-run report:\n%s", report)
+	}
+	if !strings.Contains(report.String(), "zone would be created\n") {
+		t.Errorf("unexpected dry-run report:\n%s", report)
+	}
+
+	report, err = migrate.Migrate(context.Background(), client, source, "example.com", migrate.Options{TTL: 3600})
+	if err != nil {
+		t.Fatalf("migration failed: %v\n%s", err, report)
+	}
+	if !report.ZoneCreated || !report.Verified || report.Read != 7 || len(report.Created) != 5 {
+		t.Errorf("unexpected report:\n%s", report)
+	}
+	ttls := map[string]int{}
+	for _, record := range report.Created {
+		ttls[record.Name+" "+record.Type] = record.TTL
+	}
+	if ttls["@ A"] != 0 || ttls["www CNAME"] != 300 || ttls["mail A"] != migrate.MIN_TTL || ttls["sub NS"] != 0 {
+		t.Errorf("unexpected TTLs %v", ttls)
+	}
+
+	// migrating again changes nothing
+	report, err = migrate.Migrate(context.Background(), client, source, "example.com", migrate.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.ZoneCreated || len(report.Created) != 0 || len(report.Unchanged) != 5 || !report.Verified {
+		t.Errorf("unexpected report:\n%s", report)
+	}
+}
+
+func TestMigrateHetzner(t *testing.T) {
+	source := fakeapi.New(t)
+	defer source.Close()
+	source.AddZoneWithTTL("src-1", "example.com", 7200)
+	for _, request := range []hetzner_dns.RecordRequest{
+		{ZoneID: "src-1", Name: "@", Type: "NS", Value: "hydrogen.ns.hetzner.com."},
+		{ZoneID: "src-1", Name: "www", Type: "A", Value: "192.0.2.1"},
+		{ZoneID: "src-1", Name: "www.example.com.", Type: "AAAA", Value: "2001:db8::1", TTL: 600},
+		{ZoneID: "src-1", Name: "lost", Type: "A", Value: "192.0.2.2"},
+	} {
+		source.Insert(request)
+	}
+	destination := fakeapi.New(t)
+	defer destination.Close()
+	destination.AddZoneWithTTL("dst-1", "example.com", 3600)
+	destination.Insert(hetzner_dns.RecordRequest{ZoneID: "dst-1", Name: "www", Type: "AAAA", Value: "2001:db8::1", TTL: 300})
+	destination.Drop = func(request hetzner_dns.RecordRequest) bool {
+		return request.Name == "lost"
+	}
+
+	report, err := migrate.Migrate(context.Background(), destination.Client(), &migrate.HetznerSource{Client: source.Client()},
+		"example.com", migrate.Options{})
+	if err == nil || report == nil || report.Verified {
+		t.Fatalf("expected a verification failure, got %v", err)
+	}
+	if len(report.Missing) != 1 || report.Missing[0].Name != "lost" {
+		t.Errorf("expected the lost record to be missing:\n%s", report)
+	}
+	if len(report.Updated) != 1 || report.Updated[0].TTL != 600 || destination.ZoneRecords("dst-1")[0].TTL != 600 {
+		t.Errorf("expected the AAAA TTL to be updated:\n%s", report)
+	}
+	if len(report.Created) != 2 || report.Created[0].TTL != 7200 {
+		t.Errorf("expected records inheriting the source zone TTL to keep it:\n%s", report)
+	}
+	if !strings.Contains(report.String(), "VERIFICATION FAILED") {
+		t.Errorf("expected the report to show the failure:\n%s", report)
+	}
+}
+
+func TestFileSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "migrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"records.json": `[{"name": "www", "type": "A", "value": "192.0.2.1", "ttl": 300}]`,
+		"backup.json":  `{"zone": {"id": "z", "name": "example.com", "ttl": 600}, "records": [{"id": "r1", "zone_id": "z", "name": "www", "type": "A", "value": "192.0.2.1"}]}`,
+		"records.csv":  "Type,Name,Value,TTL,Comment\nA,www,192.0.2.1,300,web\nTXT,@,\"v=spf1 -all\",,\n",
+		"bad.csv":      "name,type,value,ttl\nwww,A,192.0.2.1,x\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range []string{"records.json", "backup.json", "records.csv"} {
+		zone, err := (&migrate.FileSource{Path: filepath.Join(dir, name)}).Read(context.Background(), "example.com")
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if zone.Name != "example.com" || len(zone.Records) == 0 || zone.Records[0].Name != "www" || zone.Records[0].ID != "" {
+			t.Errorf("%s: unexpected zone %+v", name, zone)
+		}
+	}
+	zone, _ := (&migrate.FileSource{Path: filepath.Join(dir, "records.csv")}).Read(context.Background(), "example.com")
+	if len(zone.Records) != 2 || zone.Records[1].Value != "v=spf1 -all" || zone.Records[0].TTL != 300 {
+		t.Errorf("unexpected CSV records %+v", zone.Records)
+	}
+	if _, err := (&migrate.FileSource{Path: filepath.Join(dir, "bad.csv")}).Read(context.Background(), "example.com"); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected an error on line 2, got %v", err)
+	}
+}
+
+func TestMigrateCSV(t *testing.T) {
+	destination := fakeapi.New(t)
+	defer destination.Close()
+	client := destination.Client()
+
+	dir, err := ioutil.TempDir("", "migrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "records.csv")
+	content := "name,type,value,ttl\nwww,A,192.0.2.1,30\nwww,A,192.0.2.1,30\nmail,A,192.0.2.25,\n"
+	if err := ioutil.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// the low TTL and the duplicate are normalized, not rejected
+	report, err := migrate.Migrate(context.Background(), client, &migrate.FileSource{Path: path}, "example.com", migrate.Options{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Read != 3 || len(report.Created) != 2 || report.Created[0].TTL != migrate.MIN_TTL {
+		t.Errorf("unexpected report:\n%s", report)
+	}
+	if len(report.Skipped) != 1 || report.Skipped[0].Reason != "duplicate" {
+		t.Errorf("expected the duplicate to be skipped:\n%s", report)
+	}
+}
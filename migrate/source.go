@@ -0,0 +1,176 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+	"github.com/panta/go-hetzner-dns/csvfile"
+	"github.com/panta/go-hetzner-dns/zonefile"
+)
+
+// SourceZone is a zone read from a source.
+type SourceZone struct {
+	Name string
+	// TTL is the default TTL of the zone, used for records without one (0
+	// if unknown).
+	TTL     int
+	Records []hetzner_dns.RecordRequest
+}
+
+// Source is where the records of a zone are migrated from.
+type Source interface {
+	// Read returns the records of the zone zoneName.
+	Read(ctx context.Context, zoneName string) (*SourceZone, error)
+	// String describes the source, for reports.
+	String() string
+}
+
+// HetznerSource reads zones from a Hetzner DNS account, typically through a
+// client with the token of another account or project.
+type HetznerSource struct {
+	Client *hetzner_dns.Client
+}
+
+func (source *HetznerSource) String() string {
+	return "hetzner"
+}
+
+func (source *HetznerSource) Read(ctx context.Context, zoneName string) (*SourceZone, error) {
+	zone, err := findZone(ctx, source.Client, zoneName)
+	if err != nil {
+		return nil, err
+	}
+	if zone == nil {
+		return nil, errors.Errorf("migrate: zone %s not found in the source account", zoneName)
+	}
+	records, err := source.Client.GetAllRecords(ctx, zone.ID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't get records for zone %s", zoneName)
+	}
+	sourceZone := &SourceZone{Name: zone.Name, TTL: zone.TTL}
+	for i := range records {
+		sourceZone.Records = append(sourceZone.Records, records[i].Request())
+	}
+	return sourceZone, nil
+}
+
+// ZoneFileSource reads a zone from a BIND zone file.
+type ZoneFileSource struct {
+	Path string
+}
+
+func (source *ZoneFileSource) String() string {
+	return "zonefile " + source.Path
+}
+
+func (source *ZoneFileSource) Read(ctx context.Context, zoneName string) (*SourceZone, error) {
+	records, err := zonefile.ParseFile(source.Path, zoneName)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't parse zone file")
+	}
+	return &SourceZone{Name: zoneName, Records: records}, nil
+}
+
+// FileSource reads a zone from a generic JSON or CSV export.
+//
+// JSON files hold either a list of records or an object with a "records"
+// list and an optional "zone" object, as written by backups and returned by
+// the API. Records have name, type, value and ttl fields.
+//
+// CSV files are read with csvfile.Read: a header line names the name, type,
+// value and optional ttl and zone columns, in any order. Rows of other zones
+// are ignored.
+type FileSource struct {
+	Path string
+	// Format is "json" or "csv", guessed from the file extension if empty.
+	Format string
+}
+
+func (source *FileSource) String() string {
+	return "file " + source.Path
+}
+
+func (source *FileSource) Read(ctx context.Context, zoneName string) (*SourceZone, error) {
+	data, err := ioutil.ReadFile(source.Path)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't read source file")
+	}
+	format := source.Format
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(source.Path)), ".")
+	}
+	var sourceZone *SourceZone
+	switch format {
+	case "json":
+		sourceZone, err = parseJSON(data)
+	case "csv":
+		sourceZone, err = parseCSV(data, zoneName)
+	default:
+		return nil, errors.Errorf("migrate: unknown source format %q", format)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't parse %s", source.Path)
+	}
+	if sourceZone.Name == "" {
+		sourceZone.Name = zoneName
+	}
+	return sourceZone, nil
+}
+
+func parseJSON(data []byte) (*SourceZone, error) {
+	data = bytes.TrimSpace(data)
+	var export struct {
+		Zone struct {
+			Name string `json:"name"`
+			TTL  int    `json:"ttl"`
+		} `json:"zone"`
+		Records []hetzner_dns.RecordRequest `json:"records"`
+	}
+	if bytes.HasPrefix(data, []byte("[")) {
+		if err := json.Unmarshal(data, &export.Records); err != nil {
+			return nil, err
+		}
+	} else if err := json.Unmarshal(data, &export); err != nil {
+		return nil, err
+	}
+	for i := range export.Records {
+		// records exported from the API carry the IDs of the source account
+		export.Records[i].ID, export.Records[i].ZoneID = "", ""
+	}
+	return &SourceZone{Name: export.Zone.Name, TTL: export.Zone.TTL, Records: export.Records}, nil
+}
+
+func parseCSV(data []byte, zoneName string) (*SourceZone, error) {
+	// records are validated by normalize, reporting the rows it skips
+	rows, err := csvfile.Read(bytes.NewReader(data), csvfile.ReadOptions{Zone: zoneName, Lenient: true})
+	if err != nil {
+		return nil, err
+	}
+	sourceZone := &SourceZone{}
+	for i := range rows {
+		if hetzner_dns.NormalizeZoneName(rows[i].Zone) == hetzner_dns.NormalizeZoneName(zoneName) {
+			sourceZone.Records = append(sourceZone.Records, rows[i].Request())
+		}
+	}
+	return sourceZone, nil
+}
+
+func findZone(ctx context.Context, client *hetzner_dns.Client, zoneName string) (*hetzner_dns.Zone, error) {
+	zones, err := client.GetAllZones(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't get zones")
+	}
+	for i := range zones {
+		if hetzner_dns.NormalizeZoneName(zones[i].Name) == hetzner_dns.NormalizeZoneName(zoneName) {
+			return &zones[i], nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,225 @@
+// Package mirror keeps an in-memory copy of the zones of an Hetzner DNS
+// account and serves them over DNS, including zone transfers (AXFR and IXFR)
+// and NOTIFY messages to downstream secondaries.
+package mirror
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+)
+
+const (
+	DEFAULT_INTERVAL       = time.Minute * 5
+	DEFAULT_TTL            = 86400
+	DEFAULT_NOTIFY_TIMEOUT = time.Second * 5
+)
+
+// Mirror periodically syncs the zones of an Hetzner DNS account into a Store
+// and answers DNS queries for them (it implements dns.Handler).
+type Mirror struct {
+	Client *hetzner_dns.Client
+	Store  *Store
+
+	// Interval between syncs (DEFAULT_INTERVAL if zero).
+	Interval time.Duration
+	// Notify lists the downstream secondaries ("host:port") to send NOTIFY
+	// messages to when a zone changes.
+	Notify []string
+	// AllowTransfer restricts zone transfers to these networks. If empty,
+	// transfers are allowed from any address.
+	AllowTransfer []string
+	// ErrorLog is used to log errors during background operations. If nil,
+	// the standard logger is used.
+	ErrorLog *log.Logger
+
+	modified map[string]time.Time
+}
+
+// NewMirror creates a Mirror using client to access the Hetzner API.
+func NewMirror(client *hetzner_dns.Client) *Mirror {
+	return &Mirror{
+		Client: client,
+		Store:  NewStore(),
+	}
+}
+
+// Run syncs the zones every Interval until ctx is cancelled.
+func (mirror *Mirror) Run(ctx context.Context) error {
+	interval := mirror.Interval
+	if interval <= 0 {
+		interval = DEFAULT_INTERVAL
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := mirror.Sync(ctx); err != nil {
+			mirror.logf("mirror: sync failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync fetches zones and records from the API and updates the store. Records
+// of a zone are fetched again only when the zone has been modified since the
+// last sync. Downstream secondaries are notified of changed zones. Zones with
+// records that can't be converted are logged and left as they were.
+func (mirror *Mirror) Sync(ctx context.Context) error {
+	if mirror.Store == nil {
+		mirror.Store = NewStore()
+	}
+	if mirror.modified == nil {
+		mirror.modified = map[string]time.Time{}
+	}
+
+	zones, err := mirror.Client.GetAllZones(ctx)
+	if err != nil {
+		return errors.Wrap(err, "can't get zones")
+	}
+
+	seen := map[string]bool{}
+	for _, zone := range zones {
+		name := canonicalName(zone.Name)
+		seen[name] = true
+
+		modified := time.Time(zone.Modified)
+		if last, ok := mirror.modified[name]; ok && last.Equal(modified) && mirror.Store.Zone(name) != nil {
+			continue
+		}
+
+		records, err := mirror.Client.GetAllRecords(ctx, zone.ID)
+		if err != nil {
+			return errors.Wrapf(err, "can't get records for zone %s", zone.Name)
+		}
+		mirrorZone, err := BuildZone(zone, records)
+		if err != nil {
+			// keep serving the previous version, and try again next sync
+			mirror.logf("mirror: can't sync zone %s: %v", zone.Name, err)
+			continue
+		}
+		mirror.modified[name] = modified
+		if mirror.Store.Put(mirrorZone) {
+			mirror.notify(mirrorZone)
+		}
+	}
+
+	for _, zone := range mirror.Store.Zones() {
+		if !seen[zone.Name] {
+			mirror.Store.Remove(zone.Name)
+			delete(mirror.modified, zone.Name)
+		}
+	}
+	return nil
+}
+
+// BuildZone converts an Hetzner zone and its records into a Zone. The SOA
+// serial is synthesized from the modification time of the zone.
+func BuildZone(zone hetzner_dns.Zone, records []hetzner_dns.Record) (*Zone, error) {
+	origin := canonicalName(zone.Name)
+	defaultTTL := zone.TTL
+	if defaultTTL <= 0 {
+		defaultTTL = DEFAULT_TTL
+	}
+
+	mirrorZone := &Zone{ID: zone.ID, Name: origin}
+	for _, record := range records {
+		rr, err := RecordToRR(origin, defaultTTL, record)
+		if err != nil {
+			return nil, errors.Wrapf(err, "zone %s", zone.Name)
+		}
+		if soa, ok := rr.(*dns.SOA); ok {
+			mirrorZone.SOA = soa
+			continue
+		}
+		mirrorZone.Records = append(mirrorZone.Records, rr)
+	}
+
+	if mirrorZone.SOA == nil {
+		mirrorZone.SOA = defaultSOA(zone, origin, defaultTTL)
+	}
+	mirrorZone.SOA.Serial = uint32(time.Time(zone.Modified).Unix())
+	return mirrorZone, nil
+}
+
+// RecordToRR converts an Hetzner record of the zone origin into a dns.RR.
+func RecordToRR(origin string, defaultTTL int, record hetzner_dns.Record) (dns.RR, error) {
+	ttl := record.TTL
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+	value := record.Value
+	if record.Type == "TXT" && !strings.HasPrefix(value, `"`) {
+		value = fmt.Sprintf("%q", value)
+	}
+	line := fmt.Sprintf("%s %d IN %s %s", ownerName(origin, record.Name), ttl, record.Type, value)
+
+	parser := dns.NewZoneParser(strings.NewReader(line), origin, "")
+	rr, ok := parser.Next()
+	if err := parser.Err(); err != nil {
+		return nil, errors.Wrapf(err, "can't convert record %s %s", record.Name, record.Type)
+	}
+	if !ok {
+		return nil, errors.Errorf("can't convert record %s %s", record.Name, record.Type)
+	}
+	return rr, nil
+}
+
+func ownerName(origin string, name string) string {
+	switch {
+	case name == "" || name == "@":
+		return origin
+	case strings.HasSuffix(name, "."):
+		return name
+	default:
+		return name + "." + origin
+	}
+}
+
+func defaultSOA(zone hetzner_dns.Zone, origin string, ttl int) *dns.SOA {
+	ns := "ns." + origin
+	if len(zone.Ns) > 0 {
+		ns = dns.Fqdn(zone.Ns[0])
+	}
+	return &dns.SOA{
+		Hdr:     dns.RR_Header{Name: origin, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: uint32(ttl)},
+		Ns:      ns,
+		Mbox:    "hostmaster." + origin,
+		Refresh: 86400,
+		Retry:   10800,
+		Expire:  3600000,
+		Minttl:  3600,
+	}
+}
+
+// notify sends NOTIFY messages for zone to all the downstream secondaries.
+func (mirror *Mirror) notify(zone *Zone) {
+	for _, addr := range mirror.Notify {
+		msg := new(dns.Msg)
+		msg.SetNotify(zone.Name)
+		msg.Answer = []dns.RR{zone.SOA}
+
+		client := dns.Client{Timeout: DEFAULT_NOTIFY_TIMEOUT}
+		if _, _, err := client.Exchange(msg, addr); err != nil {
+			mirror.logf("mirror: can't notify %s for zone %s: %v", addr, zone.Name, err)
+		}
+	}
+}
+
+func (mirror *Mirror) logf(format string, args ...interface{}) {
+	if mirror.ErrorLog != nil {
+		mirror.ErrorLog.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
@@ -0,0 +1,179 @@
+package mirror_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/miekg/dns"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+	"github.com/panta/go-hetzner-dns/mirror"
+)
+
+func TestMirror_Transfers(t *testing.T) {
+	modified := "2021-01-28T14:23:31Z"
+	wwwValue := "10.0.0.1"
+	hs := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/zones":
+			_, _ = io.WriteString(rw, fmt.Sprintf(`{
+  "zones": [
+    {"id": "zone-1", "name": "example.com", "ttl": 3600, "modified": %q, "ns": ["ns1.example.net"]}
+  ],
+  "meta": {"pagination": {"page": 1, "per_page": 100, "last_page": 1, "total_entries": 1}}
+}`, modified))
+		case "/records":
+			_, _ = io.WriteString(rw, fmt.Sprintf(`{
+  "records": [
+    {"type": "A", "id": "r1", "zone_id": "zone-1", "name": "@", "value": "10.0.0.10", "ttl": 0},
+    {"type": "A", "id": "r2", "zone_id": "zone-1", "name": "www", "value": %q, "ttl": 60},
+    {"type": "MX", "id": "r3", "zone_id": "zone-1", "name": "@", "value": "10 mail", "ttl": 0},
+    {"type": "TXT", "id": "r4", "zone_id": "zone-1", "name": "@", "value": "v=spf1 mx -all", "ttl": 0}
+  ]
+}`, wwwValue))
+		default:
+			http.NotFound(rw, req)
+		}
+	}))
+	defer hs.Close()
+
+	m := mirror.NewMirror(&hetzner_dns.Client{BaseURL: hs.URL, ApiKey: "dummy"})
+	if err := m.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	zone := m.Store.Zone("example.com")
+	if zone == nil {
+		t.Fatal("Zone not mirrored")
+	}
+	if len(zone.Records) != 4 {
+		t.Fatalf("Wrong # of records: %d", len(zone.Records))
+	}
+	firstSerial := zone.Serial()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &dns.Server{Listener: listener, Handler: m}
+	go func() { _ = server.ActivateAndServe() }()
+	defer func() { _ = server.Shutdown() }()
+	addr := listener.Addr().String()
+
+	query := new(dns.Msg).SetQuestion("www.example.com.", dns.TypeA)
+	resp, _, err := (&dns.Client{Net: "tcp"}).Exchange(query, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Authoritative || len(resp.Answer) != 1 || resp.Answer[0].(*dns.A).A.String() != "10.0.0.1" {
+		t.Errorf("Wrong answer: %v", resp)
+	}
+
+	axfr := new(dns.Msg).SetAxfr("example.com.")
+	envelopes, err := new(dns.Transfer).In(axfr, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	for envelope := range envelopes {
+		if envelope.Error != nil {
+			t.Fatal(envelope.Error)
+		}
+		count += len(envelope.RR)
+	}
+	if count != 6 {
+		t.Errorf("Wrong # of records in AXFR: %d", count)
+	}
+
+	modified = "2021-01-29T10:00:00Z"
+	wwwValue = "10.0.0.2"
+	if err := m.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if m.Store.Zone("example.com").Serial() <= firstSerial {
+		t.Error("Serial has not been increased")
+	}
+
+	ixfr := new(dns.Msg).SetIxfr("example.com.", firstSerial, "ns1.example.net.", "hostmaster.example.com.")
+	envelopes, err = new(dns.Transfer).In(ixfr, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rrs []dns.RR
+	for envelope := range envelopes {
+		if envelope.Error != nil {
+			t.Fatal(envelope.Error)
+		}
+		rrs = append(rrs, envelope.RR...)
+	}
+	// new SOA, old SOA, removed A, new SOA, added A, new SOA
+	if len(rrs) != 6 {
+		t.Fatalf("Wrong # of records in IXFR: %d", len(rrs))
+	}
+	if rrs[2].(*dns.A).A.String() != "10.0.0.1" || rrs[4].(*dns.A).A.String() != "10.0.0.2" {
+		t.Errorf("Wrong IXFR content: %v", rrs)
+	}
+}
+
+func TestMirror_SyncBadRecord(t *testing.T) {
+	modified := "2021-01-28T14:23:31Z"
+	badValue := "10.0.0.2"
+	hs := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/zones":
+			_, _ = io.WriteString(rw, fmt.Sprintf(`{
+  "zones": [
+    {"id": "zone-1", "name": "example.com", "ttl": 3600, "modified": %q},
+    {"id": "zone-2", "name": "example.org", "ttl": 3600, "modified": "2021-01-28T14:23:31Z"}
+  ],
+  "meta": {"pagination": {"page": 1, "per_page": 100, "last_page": 1, "total_entries": 2}}
+}`, modified))
+		case "/records":
+			value := "10.0.0.3"
+			if req.URL.Query().Get("zone_id") == "zone-1" {
+				value = badValue
+			}
+			_, _ = io.WriteString(rw, fmt.Sprintf(`{
+  "records": [
+    {"type": "A", "id": "r1", "zone_id": %q, "name": "www", "value": %q, "ttl": 0}
+  ]
+}`, req.URL.Query().Get("zone_id"), value))
+		default:
+			http.NotFound(rw, req)
+		}
+	}))
+	defer hs.Close()
+
+	m := mirror.NewMirror(&hetzner_dns.Client{BaseURL: hs.URL, ApiKey: "dummy"})
+	m.ErrorLog = log.New(ioutil.Discard, "", 0)
+	if err := m.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	// a record that can't be converted keeps the previous version of its zone
+	modified, badValue = "2021-01-29T10:00:00Z", "not-an-address"
+	if err := m.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	zone := m.Store.Zone("example.com")
+	if zone == nil || len(zone.Records) != 1 || zone.Records[0].(*dns.A).A.String() != "10.0.0.2" {
+		t.Errorf("Expected the previous version of the zone, got %+v", zone)
+	}
+	if m.Store.Zone("example.org") == nil {
+		t.Error("Expected the other zones to be mirrored")
+	}
+
+	m.Store.Remove("example.com")
+	if err := m.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if m.Store.Zone("example.com") != nil || m.Store.Zone("example.org") == nil {
+		t.Error("Expected only the zone without bad records to be mirrored")
+	}
+}
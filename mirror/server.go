@@ -0,0 +1,240 @@
+package mirror
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+const transferChunkSize = 100
+
+// ListenAndServe serves the mirrored zones on addr, both over UDP and TCP,
+// until ctx is cancelled or one of the servers fails.
+func (mirror *Mirror) ListenAndServe(ctx context.Context, addr string) error {
+	servers := []*dns.Server{
+		{Addr: addr, Net: "udp", Handler: mirror},
+		{Addr: addr, Net: "tcp", Handler: mirror},
+	}
+	errs := make(chan error, len(servers))
+	for _, server := range servers {
+		go func(server *dns.Server) {
+			errs <- server.ListenAndServe()
+		}(server)
+	}
+
+	var err error
+	select {
+	case <-ctx.Done():
+		err = ctx.Err()
+	case err = <-errs:
+	}
+	for _, server := range servers {
+		_ = server.Shutdown()
+	}
+	return err
+}
+
+// ServeDNS implements dns.Handler.
+func (mirror *Mirror) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	if len(req.Question) != 1 || req.Opcode != dns.OpcodeQuery {
+		reply(w, new(dns.Msg).SetRcode(req, dns.RcodeFormatError))
+		return
+	}
+	question := req.Question[0]
+	zone := mirror.Store.findZone(question.Name)
+	if zone == nil {
+		reply(w, new(dns.Msg).SetRcode(req, dns.RcodeRefused))
+		return
+	}
+
+	switch question.Qtype {
+	case dns.TypeAXFR, dns.TypeIXFR:
+		if !mirror.transferAllowed(w.RemoteAddr()) {
+			reply(w, new(dns.Msg).SetRcode(req, dns.RcodeRefused))
+			return
+		}
+		if question.Qtype == dns.TypeIXFR {
+			mirror.serveIXFR(w, req, zone)
+		} else {
+			mirror.serveAXFR(w, req, zone)
+		}
+	default:
+		reply(w, answer(req, zone))
+	}
+}
+
+// answer builds an authoritative answer for a standard query.
+func answer(req *dns.Msg, zone *Zone) *dns.Msg {
+	question := req.Question[0]
+	msg := new(dns.Msg)
+	msg.SetReply(req)
+	msg.Authoritative = true
+
+	name := canonicalName(question.Name)
+	owned := lookupName(zone, name)
+	if len(owned) == 0 {
+		owned = lookupWildcard(zone, name)
+	}
+	if len(owned) == 0 && name != zone.Name {
+		msg.Rcode = dns.RcodeNameError
+		msg.Ns = []dns.RR{zone.SOA}
+		return msg
+	}
+
+	for _, rr := range owned {
+		rrtype := rr.Header().Rrtype
+		if question.Qtype == dns.TypeANY || rrtype == question.Qtype || (rrtype == dns.TypeCNAME && question.Qtype != dns.TypeCNAME) {
+			rr = dns.Copy(rr)
+			rr.Header().Name = question.Name
+			msg.Answer = append(msg.Answer, rr)
+		}
+	}
+	if name == zone.Name && (question.Qtype == dns.TypeSOA || question.Qtype == dns.TypeANY) {
+		msg.Answer = append(msg.Answer, zone.SOA)
+	}
+	if len(msg.Answer) == 0 {
+		msg.Ns = []dns.RR{zone.SOA}
+	}
+	return msg
+}
+
+func lookupName(zone *Zone, name string) []dns.RR {
+	var owned []dns.RR
+	for _, rr := range zone.Records {
+		if strings.EqualFold(rr.Header().Name, name) {
+			owned = append(owned, rr)
+		}
+	}
+	return owned
+}
+
+// lookupWildcard looks for the closest wildcard matching name.
+func lookupWildcard(zone *Zone, name string) []dns.RR {
+	for name != zone.Name {
+		idx := strings.Index(name, ".")
+		if idx < 0 {
+			return nil
+		}
+		name = name[idx+1:]
+		if owned := lookupName(zone, "*."+name); len(owned) > 0 {
+			return owned
+		}
+		if len(lookupName(zone, name)) > 0 {
+			return nil
+		}
+	}
+	return nil
+}
+
+// serveAXFR sends the whole zone, enclosed between two SOA records.
+func (mirror *Mirror) serveAXFR(w dns.ResponseWriter, req *dns.Msg, zone *Zone) {
+	if !isTCP(w) {
+		reply(w, new(dns.Msg).SetRcode(req, dns.RcodeRefused))
+		return
+	}
+	rrs := make([]dns.RR, 0, len(zone.Records)+2)
+	rrs = append(rrs, zone.SOA)
+	rrs = append(rrs, zone.Records...)
+	rrs = append(rrs, zone.SOA)
+	mirror.transfer(w, req, rrs)
+}
+
+// serveIXFR sends the differences between the serial of the client and the
+// current version of the zone (RFC 1995), falling back to a full transfer
+// when the history isn't long enough.
+func (mirror *Mirror) serveIXFR(w dns.ResponseWriter, req *dns.Msg, zone *Zone) {
+	var clientSOA *dns.SOA
+	for _, rr := range req.Ns {
+		if soa, ok := rr.(*dns.SOA); ok {
+			clientSOA = soa
+			break
+		}
+	}
+	if clientSOA == nil {
+		reply(w, new(dns.Msg).SetRcode(req, dns.RcodeFormatError))
+		return
+	}
+
+	if clientSOA.Serial == zone.Serial() || !isTCP(w) {
+		// up to date, or asking the client to retry over TCP
+		msg := new(dns.Msg)
+		msg.SetReply(req)
+		msg.Authoritative = true
+		msg.Answer = []dns.RR{zone.SOA}
+		reply(w, msg)
+		return
+	}
+
+	deltas, ok := mirror.Store.deltasSince(zone.Name, clientSOA.Serial)
+	if !ok {
+		mirror.serveAXFR(w, req, zone)
+		return
+	}
+	rrs := []dns.RR{zone.SOA}
+	for _, d := range deltas {
+		rrs = append(rrs, d.fromSOA)
+		rrs = append(rrs, d.removed...)
+		rrs = append(rrs, d.toSOA)
+		rrs = append(rrs, d.added...)
+	}
+	rrs = append(rrs, zone.SOA)
+	mirror.transfer(w, req, rrs)
+}
+
+func (mirror *Mirror) transfer(w dns.ResponseWriter, req *dns.Msg, rrs []dns.RR) {
+	ch := make(chan *dns.Envelope)
+	transfer := new(dns.Transfer)
+	done := make(chan error, 1)
+	go func() {
+		done <- transfer.Out(w, req, ch)
+	}()
+	for len(rrs) > 0 {
+		n := transferChunkSize
+		if n > len(rrs) {
+			n = len(rrs)
+		}
+		ch <- &dns.Envelope{RR: rrs[:n]}
+		rrs = rrs[n:]
+	}
+	close(ch)
+	if err := <-done; err != nil {
+		mirror.logf("mirror: transfer to %s failed: %v", w.RemoteAddr(), err)
+	}
+	_ = w.Close()
+}
+
+func (mirror *Mirror) transferAllowed(addr net.Addr) bool {
+	if len(mirror.AllowTransfer) == 0 {
+		return true
+	}
+	var ip net.IP
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		ip = a.IP
+	case *net.UDPAddr:
+		ip = a.IP
+	default:
+		return false
+	}
+	for _, allowed := range mirror.AllowTransfer {
+		if strings.Contains(allowed, "/") {
+			if _, network, err := net.ParseCIDR(allowed); err == nil && network.Contains(ip) {
+				return true
+			}
+		} else if allowedIP := net.ParseIP(allowed); allowedIP != nil && allowedIP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func isTCP(w dns.ResponseWriter) bool {
+	_, ok := w.RemoteAddr().(*net.TCPAddr)
+	return ok
+}
+
+func reply(w dns.ResponseWriter, msg *dns.Msg) {
+	_ = w.WriteMsg(msg)
+}
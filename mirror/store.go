@@ -0,0 +1,180 @@
+package mirror
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+const DEFAULT_MAX_HISTORY = 16
+
+// Zone is an in-memory copy of an Hetzner zone, ready to be served.
+type Zone struct {
+	ID      string
+	Name    string // fully qualified, lowercase zone name
+	SOA     *dns.SOA
+	Records []dns.RR // all the records of the zone, except SOA
+}
+
+// Serial returns the serial of the zone.
+func (zone *Zone) Serial() uint32 {
+	return zone.SOA.Serial
+}
+
+// delta holds the changes needed to bring a zone from one serial to the next.
+type delta struct {
+	fromSOA *dns.SOA
+	toSOA   *dns.SOA
+	removed []dns.RR
+	added   []dns.RR
+}
+
+type storedZone struct {
+	zone    *Zone
+	history []delta
+}
+
+// Store is a concurrency-safe in-memory zone store, keeping a bounded history
+// of differences between versions of each zone to answer IXFR queries.
+type Store struct {
+	// MaxHistory is the number of diffs kept per zone (DEFAULT_MAX_HISTORY if zero).
+	MaxHistory int
+
+	mu    sync.RWMutex
+	zones map[string]*storedZone
+}
+
+// NewStore creates an empty zone store.
+func NewStore() *Store {
+	return &Store{zones: map[string]*storedZone{}}
+}
+
+// Zone returns the zone with the given name, or nil.
+func (store *Store) Zone(name string) *Zone {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+	stored, ok := store.zones[canonicalName(name)]
+	if !ok {
+		return nil
+	}
+	return stored.zone
+}
+
+// Zones returns all the stored zones.
+func (store *Store) Zones() []*Zone {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+	zones := make([]*Zone, 0, len(store.zones))
+	for _, stored := range store.zones {
+		zones = append(zones, stored.zone)
+	}
+	return zones
+}
+
+// Put stores a new version of a zone, recording the differences with the
+// previous version. It returns true if the zone content changed.
+func (store *Store) Put(zone *Zone) bool {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if store.zones == nil {
+		store.zones = map[string]*storedZone{}
+	}
+	zone.Name = canonicalName(zone.Name)
+
+	stored, ok := store.zones[zone.Name]
+	if !ok {
+		store.zones[zone.Name] = &storedZone{zone: zone}
+		return true
+	}
+
+	removed, added := diffRRs(stored.zone.Records, zone.Records)
+	if len(removed) == 0 && len(added) == 0 {
+		return false
+	}
+	if zone.SOA.Serial <= stored.zone.SOA.Serial {
+		zone.SOA.Serial = stored.zone.SOA.Serial + 1
+	}
+
+	maxHistory := store.MaxHistory
+	if maxHistory <= 0 {
+		maxHistory = DEFAULT_MAX_HISTORY
+	}
+	stored.history = append(stored.history, delta{
+		fromSOA: stored.zone.SOA,
+		toSOA:   zone.SOA,
+		removed: removed,
+		added:   added,
+	})
+	if len(stored.history) > maxHistory {
+		stored.history = stored.history[len(stored.history)-maxHistory:]
+	}
+	stored.zone = zone
+	return true
+}
+
+// Remove deletes a zone from the store.
+func (store *Store) Remove(name string) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	delete(store.zones, canonicalName(name))
+}
+
+// findZone returns the most specific zone containing name, or nil.
+func (store *Store) findZone(name string) *Zone {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+	name = canonicalName(name)
+	for {
+		if stored, ok := store.zones[name]; ok {
+			return stored.zone
+		}
+		idx := strings.Index(name, ".")
+		if idx < 0 || idx == len(name)-1 {
+			return nil
+		}
+		name = name[idx+1:]
+	}
+}
+
+// deltasSince returns the chain of diffs bringing the zone from serial to the
+// current version. The boolean is false if the history doesn't reach serial.
+func (store *Store) deltasSince(name string, serial uint32) ([]delta, bool) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+	stored, ok := store.zones[canonicalName(name)]
+	if !ok {
+		return nil, false
+	}
+	for i, d := range stored.history {
+		if d.fromSOA.Serial == serial {
+			return append([]delta(nil), stored.history[i:]...), true
+		}
+	}
+	return nil, false
+}
+
+// diffRRs returns the records present only in a (removed) and only in b (added).
+func diffRRs(a, b []dns.RR) (removed []dns.RR, added []dns.RR) {
+	inA := make(map[string]bool, len(a))
+	for _, rr := range a {
+		inA[rr.String()] = true
+	}
+	inB := make(map[string]bool, len(b))
+	for _, rr := range b {
+		inB[rr.String()] = true
+		if !inA[rr.String()] {
+			added = append(added, rr)
+		}
+	}
+	for _, rr := range a {
+		if !inB[rr.String()] {
+			removed = append(removed, rr)
+		}
+	}
+	return removed, added
+}
+
+func canonicalName(name string) string {
+	return strings.ToLower(dns.Fqdn(name))
+}
@@ -0,0 +1,343 @@
+package hetzner_dns
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ErrUnknownZone is returned when no account of a MultiClient owns a zone.
+var ErrUnknownZone = errors.New("hetzner_dns: zone not found in any account")
+
+// AccountZone is a zone labelled with the account owning it.
+type AccountZone struct {
+	Account string `json:"account"`
+	Zone
+}
+
+// AccountRecord is a record labelled with the account owning it.
+type AccountRecord struct {
+	Account string `json:"account"`
+	Record
+}
+
+// MultiClient holds the clients of several named accounts (e.g. Hetzner
+// projects with different API tokens) and routes each operation to the
+// account owning the zone, discovered by listing the zones of all accounts.
+type MultiClient struct {
+	Clients map[string]*Client
+
+	mu          sync.Mutex
+	zoneAccount map[string]string // zone ID -> account
+	zoneNames   map[string]string // zone name -> zone ID
+	records     map[string]string // record ID -> account
+}
+
+// NewMultiClient creates a MultiClient for clients, by account name.
+func NewMultiClient(clients map[string]*Client) *MultiClient {
+	if clients == nil {
+		clients = map[string]*Client{}
+	}
+	return &MultiClient{Clients: clients}
+}
+
+// Add adds the client of an account.
+func (multi *MultiClient) Add(account string, client *Client) {
+	multi.mu.Lock()
+	defer multi.mu.Unlock()
+	if multi.Clients == nil {
+		multi.Clients = map[string]*Client{}
+	}
+	multi.Clients[account] = client
+}
+
+// Accounts returns the account names, sorted.
+func (multi *MultiClient) Accounts() []string {
+	multi.mu.Lock()
+	defer multi.mu.Unlock()
+	accounts := make([]string, 0, len(multi.Clients))
+	for account := range multi.Clients {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+	return accounts
+}
+
+// Client returns the client of an account.
+func (multi *MultiClient) Client(account string) (*Client, bool) {
+	multi.mu.Lock()
+	defer multi.mu.Unlock()
+	client, ok := multi.Clients[account]
+	return client, ok
+}
+
+// GetAllZones returns the zones of all the accounts, labelled with their
+// account, and refreshes the routing table.
+func (multi *MultiClient) GetAllZones(ctx context.Context) ([]AccountZone, error) {
+	var zones []AccountZone
+	zoneAccount, zoneNames := map[string]string{}, map[string]string{}
+	for _, account := range multi.Accounts() {
+		client, _ := multi.Client(account)
+		accountZones, err := client.GetAllZones(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "can't get zones of account %s", account)
+		}
+		for _, zone := range accountZones {
+			zones = append(zones, AccountZone{Account: account, Zone: zone})
+			zoneAccount[zone.ID] = account
+			zoneNames[NormalizeZoneName(zone.Name)] = zone.ID
+		}
+	}
+
+	multi.mu.Lock()
+	multi.zoneAccount, multi.zoneNames = zoneAccount, zoneNames
+	multi.mu.Unlock()
+	return zones, nil
+}
+
+// Discover lists the zones of all the accounts to learn which account owns
+// each zone.
+func (multi *MultiClient) Discover(ctx context.Context) error {
+	_, err := multi.GetAllZones(ctx)
+	return err
+}
+
+// ZoneAccount returns the account owning a zone, discovering the zones if
+// it is unknown.
+func (multi *MultiClient) ZoneAccount(ctx context.Context, zoneId string) (string, *Client, error) {
+	if zoneId == "" {
+		return "", nil, ErrMissingZone
+	}
+	lookup := func() (string, bool) {
+		multi.mu.Lock()
+		defer multi.mu.Unlock()
+		account, ok := multi.zoneAccount[zoneId]
+		return account, ok
+	}
+	account, ok := lookup()
+	if !ok {
+		if err := multi.Discover(ctx); err != nil {
+			return "", nil, err
+		}
+		if account, ok = lookup(); !ok {
+			return "", nil, errors.Wrap(ErrUnknownZone, zoneId)
+		}
+	}
+	client, _ := multi.Client(account)
+	return account, client, nil
+}
+
+// ZoneByName returns the ID and the account of a zone by name.
+func (multi *MultiClient) ZoneByName(ctx context.Context, name string) (string, string, error) {
+	lookup := func() (string, bool) {
+		multi.mu.Lock()
+		defer multi.mu.Unlock()
+		zoneId, ok := multi.zoneNames[NormalizeZoneName(name)]
+		return zoneId, ok
+	}
+	zoneId, ok := lookup()
+	if !ok {
+		if err := multi.Discover(ctx); err != nil {
+			return "", "", err
+		}
+		if zoneId, ok = lookup(); !ok {
+			return "", "", errors.Wrap(ErrUnknownZone, name)
+		}
+	}
+	account, _, err := multi.ZoneAccount(ctx, zoneId)
+	return zoneId, account, err
+}
+
+// GetAllRecords returns the records of a zone, from the account owning it.
+func (multi *MultiClient) GetAllRecords(ctx context.Context, zoneId string) ([]Record, error) {
+	account, client, err := multi.ZoneAccount(ctx, zoneId)
+	if err != nil {
+		return nil, err
+	}
+	records, err := client.GetAllRecords(ctx, zoneId)
+	if err != nil {
+		return nil, err
+	}
+	multi.learnRecords(account, records...)
+	return records, nil
+}
+
+// GetAllAccountRecords returns the records of all the zones of all the
+// accounts, labelled with their account.
+func (multi *MultiClient) GetAllAccountRecords(ctx context.Context) ([]AccountRecord, error) {
+	zones, err := multi.GetAllZones(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var records []AccountRecord
+	for _, zone := range zones {
+		client, _ := multi.Client(zone.Account)
+		zoneRecords, err := client.GetAllRecords(ctx, zone.ID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "can't get records of zone %s (account %s)", zone.Name, zone.Account)
+		}
+		multi.learnRecords(zone.Account, zoneRecords...)
+		for _, record := range zoneRecords {
+			records = append(records, AccountRecord{Account: zone.Account, Record: record})
+		}
+	}
+	return records, nil
+}
+
+func (multi *MultiClient) CreateRecord(ctx context.Context, record RecordRequest) (*RecordResponse, error) {
+	account, client, err := multi.ZoneAccount(ctx, record.ZoneID)
+	if err != nil {
+		return nil, err
+	}
+	recordResponse, err := client.CreateRecord(ctx, record)
+	if err == nil {
+		multi.learnRecords(account, recordResponse.Record)
+	}
+	return recordResponse, err
+}
+
+// UpdateRecord updates a record in the account owning its zone, or the
+// record itself when ZoneID is not set.
+func (multi *MultiClient) UpdateRecord(ctx context.Context, record RecordRequest) (*RecordResponse, error) {
+	var client *Client
+	var err error
+	if record.ZoneID != "" {
+		_, client, err = multi.ZoneAccount(ctx, record.ZoneID)
+	} else {
+		_, client, err = multi.recordAccount(ctx, record.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return client.UpdateRecord(ctx, record)
+}
+
+func (multi *MultiClient) CreateOrUpdateRecord(ctx context.Context, record RecordRequest) (*RecordResponse, error) {
+	account, client, err := multi.ZoneAccount(ctx, record.ZoneID)
+	if err != nil {
+		return nil, err
+	}
+	recordResponse, err := client.CreateOrUpdateRecord(ctx, record)
+	if err == nil {
+		multi.learnRecords(account, recordResponse.Record)
+	}
+	return recordResponse, err
+}
+
+// GetRecord returns a record by ID. Records not seen before are looked up in
+// each account in turn.
+func (multi *MultiClient) GetRecord(ctx context.Context, recordId string) (*RecordResponse, error) {
+	_, client, err := multi.recordAccount(ctx, recordId)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetRecord(ctx, recordId)
+}
+
+func (multi *MultiClient) DeleteRecord(ctx context.Context, recordId string) error {
+	_, client, err := multi.recordAccount(ctx, recordId)
+	if err != nil {
+		return err
+	}
+	if err := client.DeleteRecord(ctx, recordId); err != nil {
+		return err
+	}
+	multi.mu.Lock()
+	delete(multi.records, recordId)
+	multi.mu.Unlock()
+	return nil
+}
+
+// BulkCreateRecords splits the records by account and creates them with one
+// bulk call per account.
+func (multi *MultiClient) BulkCreateRecords(ctx context.Context, bulkRecordsRequest *BulkRecordRequest) (*BulkRecordResponse, error) {
+	return multi.bulk(ctx, bulkRecordsRequest, (*Client).BulkCreateRecords)
+}
+
+// BulkUpdateRecords splits the records by account and updates them with one
+// bulk call per account.
+func (multi *MultiClient) BulkUpdateRecords(ctx context.Context, bulkRecordsRequest *BulkRecordRequest) (*BulkRecordResponse, error) {
+	return multi.bulk(ctx, bulkRecordsRequest, (*Client).BulkUpdateRecords)
+}
+
+func (multi *MultiClient) bulk(ctx context.Context, bulkRecordsRequest *BulkRecordRequest,
+	fn func(*Client, context.Context, *BulkRecordRequest) (*BulkRecordResponse, error)) (*BulkRecordResponse, error) {
+	byAccount := map[string]*BulkRecordRequest{}
+	var accounts []string
+	for _, record := range bulkRecordsRequest.Records {
+		account, _, err := multi.ZoneAccount(ctx, record.ZoneID)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := byAccount[account]; !ok {
+			byAccount[account] = &BulkRecordRequest{}
+			accounts = append(accounts, account)
+		}
+		byAccount[account].Records = append(byAccount[account].Records, record)
+	}
+
+	bulkRecordResponse := &BulkRecordResponse{}
+	for _, account := range accounts {
+		client, _ := multi.Client(account)
+		response, err := fn(client, ctx, byAccount[account])
+		if err != nil {
+			return bulkRecordResponse, errors.Wrapf(err, "account %s", account)
+		}
+		multi.learnRecords(account, response.Records...)
+		bulkRecordResponse.Records = append(bulkRecordResponse.Records, response.Records...)
+		bulkRecordResponse.ValidRecords = append(bulkRecordResponse.ValidRecords, response.ValidRecords...)
+		bulkRecordResponse.InvalidRecords = append(bulkRecordResponse.InvalidRecords, response.InvalidRecords...)
+		bulkRecordResponse.FailedRecords = append(bulkRecordResponse.FailedRecords, response.FailedRecords...)
+	}
+	return bulkRecordResponse, nil
+}
+
+// recordAccount returns the account owning a record, trying each account
+// when the record has not been seen before.
+func (multi *MultiClient) recordAccount(ctx context.Context, recordId string) (string, *Client, error) {
+	if recordId == "" {
+		return "", nil, ErrMissingID
+	}
+	multi.mu.Lock()
+	account, ok := multi.records[recordId]
+	multi.mu.Unlock()
+	if ok {
+		client, _ := multi.Client(account)
+		return account, client, nil
+	}
+
+	for _, account := range multi.Accounts() {
+		client, _ := multi.Client(account)
+		recordResponse, err := client.GetRecord(ctx, recordId)
+		var apiError *APIError
+		if errors.As(err, &apiError) && apiError.NotFound() {
+			continue
+		}
+		if err != nil {
+			return "", nil, errors.Wrapf(err, "account %s", account)
+		}
+		multi.learnRecords(account, recordResponse.Record)
+		return account, client, nil
+	}
+	return "", nil, errors.Errorf("hetzner_dns: record %s not found in any account", recordId)
+}
+
+func (multi *MultiClient) learnRecords(account string, records ...Record) {
+	multi.mu.Lock()
+	defer multi.mu.Unlock()
+	if multi.records == nil {
+		multi.records = map[string]string{}
+	}
+	for _, record := range records {
+		multi.records[record.ID] = account
+	}
+}
+
+// NormalizeZoneName lowercases a zone name and drops the trailing dot, to
+// compare zone names given by users with those of the API.
+func NormalizeZoneName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(name), ".")
+}
@@ -0,0 +1,82 @@
+package hetzner_dns_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+	"github.com/panta/go-hetzner-dns/internal/fakeapi"
+)
+
+func TestMultiClient(t *testing.T) {
+	ctx := context.Background()
+	prod, staging := fakeapi.New(t), fakeapi.New(t)
+	defer prod.Close()
+	defer staging.Close()
+	prod.AddZone("zone-prod", "example.com")
+	staging.AddZone("zone-staging", "example.dev")
+	staging.IDPrefix = "staging-rec-" // record IDs are unique across accounts
+	stagingRecord := staging.AddRecord("zone-staging", "www", "A", "10.0.0.1")
+
+	multi := hetzner_dns.NewMultiClient(map[string]*hetzner_dns.Client{
+		"prod":    prod.Client(),
+		"staging": staging.Client(),
+	})
+
+	zones, err := multi.GetAllZones(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zones) != 2 || zones[0].Account != "prod" || zones[1].Account != "staging" {
+		t.Fatalf("unexpected zones %+v", zones)
+	}
+
+	if _, err := multi.CreateRecord(ctx, hetzner_dns.RecordRequest{ZoneID: "zone-prod", Name: "www", Type: "A", Value: "127.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(prod.ZoneRecords("zone-prod")) != 1 || staging.CallCount("POST") != 0 {
+		t.Error("expected the record to be created in the prod account only")
+	}
+
+	zoneId, account, err := multi.ZoneByName(ctx, "example.dev.")
+	if err != nil || zoneId != "zone-staging" || account != "staging" {
+		t.Errorf("unexpected zone %s (%s): %v", zoneId, account, err)
+	}
+
+	if err := multi.DeleteRecord(ctx, stagingRecord); err != nil {
+		t.Fatal(err)
+	}
+	if len(staging.ZoneRecords("zone-staging")) != 0 {
+		t.Error("expected the staging record to be deleted")
+	}
+
+	response, err := multi.BulkCreateRecords(ctx, &hetzner_dns.BulkRecordRequest{Records: []hetzner_dns.RecordRequest{
+		{ZoneID: "zone-prod", Name: "mail", Type: "A", Value: "127.0.0.2"},
+		{ZoneID: "zone-staging", Name: "mail", Type: "A", Value: "10.0.0.2"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Records) != 2 || prod.CallCount("POST /records/bulk") != 1 || staging.CallCount("POST /records/bulk") != 1 {
+		t.Errorf("expected one bulk call per account, got %+v", response)
+	}
+
+	records, err := multi.GetAllAccountRecords(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byAccount := map[string]int{}
+	for _, record := range records {
+		byAccount[record.Account]++
+	}
+	if byAccount["prod"] != 2 || byAccount["staging"] != 1 {
+		t.Errorf("unexpected records by account %v", byAccount)
+	}
+
+	_, err = multi.CreateRecord(ctx, hetzner_dns.RecordRequest{ZoneID: "zone-unknown", Name: "www", Type: "A", Value: "127.0.0.1"})
+	if errors.Cause(err) != hetzner_dns.ErrUnknownZone {
+		t.Errorf("expected ErrUnknownZone, got %v", err)
+	}
+}
@@ -0,0 +1,264 @@
+package hetzner_dns
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Rules checked by a Policy.
+const (
+	POLICY_ZONE        = "zone"
+	POLICY_NAME        = "name"
+	POLICY_TYPE        = "type"
+	POLICY_TTL         = "ttl"
+	POLICY_APEX        = "apex"
+	POLICY_NS          = "ns"
+	POLICY_MAX_RECORDS = "max_records"
+)
+
+// PolicyViolation is returned when an operation is rejected by a Policy,
+// before any request is sent.
+type PolicyViolation struct {
+	Rule      string
+	Operation string
+	ZoneID    string
+	Name      string
+	Type      string
+	Reason    string
+}
+
+func (violation *PolicyViolation) Error() string {
+	target := violation.ZoneID
+	if violation.Name != "" || violation.Type != "" {
+		target = fmt.Sprintf("%s %s in zone %s", normalizeName(violation.Name), violation.Type, violation.ZoneID)
+	}
+	return fmt.Sprintf("hetzner_dns: policy violation (%s) on %s of %s: %s",
+		violation.Rule, violation.Operation, target, violation.Reason)
+}
+
+// Policy restricts the changes a client may make. Empty lists and zero
+// values don't restrict anything. Reads are always allowed.
+type Policy struct {
+	// AllowedZones are glob patterns matching the zone names or IDs that
+	// may be modified, e.g. "*.example.com".
+	AllowedZones []string `yaml:"allowed_zones" json:"allowed_zones"`
+	// NamePatterns are glob patterns matching the record names (relative to
+	// the zone, "@" for the apex) that may be modified, e.g. "app-*".
+	NamePatterns []string `yaml:"name_patterns" json:"name_patterns"`
+	// AllowedTypes are the record types that may be modified.
+	AllowedTypes []string `yaml:"allowed_types" json:"allowed_types"`
+	// MinTTL and MaxTTL bound the TTL of records; records without a TTL use
+	// the zone default and are always allowed.
+	MinTTL int `yaml:"min_ttl" json:"min_ttl"`
+	MaxTTL int `yaml:"max_ttl" json:"max_ttl"`
+	// ForbidApex forbids changing records at the zone apex.
+	ForbidApex bool `yaml:"forbid_apex" json:"forbid_apex"`
+	// ForbidNS forbids changing NS records.
+	ForbidNS bool `yaml:"forbid_ns" json:"forbid_ns"`
+	// MaxRecordsPerOperation limits the records changed by a single
+	// (bulk) operation.
+	MaxRecordsPerOperation int `yaml:"max_records_per_operation" json:"max_records_per_operation"`
+
+	zoneNames sync.Map // zone ID -> name
+}
+
+// LoadPolicy reads a policy from a YAML or JSON file.
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't read policy")
+	}
+	policy := &Policy{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(policy); err != nil {
+		return nil, errors.Wrapf(err, "can't parse policy %s", path)
+	}
+	return policy, nil
+}
+
+// Enforce makes client check its mutating operations against the policy.
+// The records targeted by updates and deletions are fetched first, so that
+// both their current and their new content are checked.
+func (policy *Policy) Enforce(client *Client) {
+	client.Use(policy.middleware(client))
+}
+
+func (policy *Policy) middleware(client *Client) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, op *Operation) error {
+			if op.Mutating() {
+				if err := policy.Check(ctx, client, op); err != nil {
+					return err
+				}
+			}
+			if op.Name == OP_UPDATE_ZONE || op.Name == OP_DELETE_ZONE {
+				defer policy.zoneNames.Delete(op.ID)
+			}
+			return next(ctx, op)
+		}
+	}
+}
+
+// Check returns a *PolicyViolation if op is not allowed. client is used to
+// fetch the records and zones the operation refers to by ID.
+func (policy *Policy) Check(ctx context.Context, client *Client, op *Operation) error {
+	switch op.Name {
+	case OP_CREATE_ZONE:
+		return policy.checkZone(ctx, client, op.Name, "", op.Request.(*ZoneRequest).Name)
+	case OP_UPDATE_ZONE:
+		if err := policy.checkZone(ctx, client, op.Name, op.ID, ""); err != nil {
+			return err
+		}
+		return policy.checkZone(ctx, client, op.Name, "", op.Request.(*ZoneRequest).Name)
+	case OP_DELETE_ZONE:
+		return policy.checkZone(ctx, client, op.Name, op.ID, "")
+	case OP_CREATE_RECORD:
+		return policy.checkRecords(ctx, client, op.Name, []RecordRequest{*op.Request.(*RecordRequest)}, false)
+	case OP_UPDATE_RECORD:
+		return policy.checkRecords(ctx, client, op.Name, []RecordRequest{*op.Request.(*RecordRequest)}, true)
+	case OP_DELETE_RECORD:
+		current, err := client.GetRecord(ctx, op.ID)
+		if err != nil {
+			return errors.Wrap(err, "can't get record to check policy")
+		}
+		return policy.checkRecords(ctx, client, op.Name, nil, false, current.Record)
+	case OP_BULK_CREATE_RECORDS:
+		return policy.checkRecords(ctx, client, op.Name, op.Request.(*BulkRecordRequest).Records, false)
+	case OP_BULK_UPDATE_RECORDS:
+		return policy.checkRecords(ctx, client, op.Name, op.Request.(*BulkRecordRequest).Records, true)
+	}
+	return nil
+}
+
+// checkRecords checks the desired records and the existing ones: either
+// given, or fetched by ID when fetchCurrent is set.
+func (policy *Policy) checkRecords(ctx context.Context, client *Client, opName string, desired []RecordRequest, fetchCurrent bool, current ...Record) error {
+	if policy.MaxRecordsPerOperation > 0 && len(desired)+len(current) > policy.MaxRecordsPerOperation {
+		return &PolicyViolation{
+			Rule:      POLICY_MAX_RECORDS,
+			Operation: opName,
+			Reason:    fmt.Sprintf("%d records changed, at most %d allowed", len(desired)+len(current), policy.MaxRecordsPerOperation),
+		}
+	}
+	if fetchCurrent {
+		for _, request := range desired {
+			if request.ID == "" {
+				continue
+			}
+			recordResponse, err := client.GetRecord(ctx, request.ID)
+			if err != nil {
+				return errors.Wrap(err, "can't get record to check policy")
+			}
+			current = append(current, recordResponse.Record)
+		}
+	}
+
+	records := append([]RecordRequest(nil), desired...)
+	for _, record := range current {
+		records = append(records, record.Request())
+	}
+	for _, record := range records {
+		if err := policy.checkRecord(ctx, client, opName, record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (policy *Policy) checkRecord(ctx context.Context, client *Client, opName string, record RecordRequest) error {
+	violation := func(rule string, format string, args ...interface{}) error {
+		return &PolicyViolation{
+			Rule:      rule,
+			Operation: opName,
+			ZoneID:    record.ZoneID,
+			Name:      record.Name,
+			Type:      record.Type,
+			Reason:    fmt.Sprintf(format, args...),
+		}
+	}
+
+	if err := policy.checkZone(ctx, client, opName, record.ZoneID, ""); err != nil {
+		return err
+	}
+	name := normalizeName(record.Name)
+	if policy.ForbidApex && name == "@" {
+		return violation(POLICY_APEX, "changes at the zone apex are forbidden")
+	}
+	if policy.ForbidNS && strings.EqualFold(record.Type, "NS") {
+		return violation(POLICY_NS, "NS changes are forbidden")
+	}
+	if len(policy.NamePatterns) > 0 && !matchAny(policy.NamePatterns, name) {
+		return violation(POLICY_NAME, "name doesn't match %s", strings.Join(policy.NamePatterns, ", "))
+	}
+	if len(policy.AllowedTypes) > 0 && !containsFold(policy.AllowedTypes, record.Type) {
+		return violation(POLICY_TYPE, "type not in %s", strings.Join(policy.AllowedTypes, ", "))
+	}
+	if record.TTL > 0 {
+		if policy.MinTTL > 0 && record.TTL < policy.MinTTL {
+			return violation(POLICY_TTL, "TTL %d below %d", record.TTL, policy.MinTTL)
+		}
+		if policy.MaxTTL > 0 && record.TTL > policy.MaxTTL {
+			return violation(POLICY_TTL, "TTL %d above %d", record.TTL, policy.MaxTTL)
+		}
+	}
+	return nil
+}
+
+// checkZone checks that a zone, given by ID and/or name, may be modified.
+// The name of zones given by ID only is fetched when needed.
+func (policy *Policy) checkZone(ctx context.Context, client *Client, opName string, zoneId string, zoneName string) error {
+	if len(policy.AllowedZones) == 0 {
+		return nil
+	}
+	if zoneId != "" && matchAny(policy.AllowedZones, zoneId) {
+		return nil
+	}
+	if zoneName == "" && zoneId != "" {
+		if name, ok := policy.zoneNames.Load(zoneId); ok {
+			zoneName = name.(string)
+		} else {
+			zoneResponse, err := client.GetZone(ctx, zoneId)
+			if err != nil {
+				return errors.Wrap(err, "can't get zone to check policy")
+			}
+			zoneName = zoneResponse.Zone.Name
+			policy.zoneNames.Store(zoneId, zoneName)
+		}
+	}
+	if zoneName != "" && matchAny(policy.AllowedZones, NormalizeZoneName(zoneName)) {
+		return nil
+	}
+	return &PolicyViolation{
+		Rule:      POLICY_ZONE,
+		Operation: opName,
+		ZoneID:    zoneId,
+		Reason:    fmt.Sprintf("zone %s is not allowed", zoneName),
+	}
+}
+
+func matchAny(patterns []string, s string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(strings.ToLower(pattern), strings.ToLower(s)); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
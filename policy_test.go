@@ -0,0 +1,124 @@
+package hetzner_dns_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+	"github.com/panta/go-hetzner-dns/internal/fakeapi"
+)
+
+func TestPolicy(t *testing.T) {
+	ctx := context.Background()
+	api := fakeapi.New(t)
+	defer api.Close()
+	api.AddZone("zone-1", "dev.example.com")
+	api.AddZone("zone-2", "example.com")
+	apexNS := api.AddRecord("zone-1", "@", "NS", "hydrogen.ns.hetzner.com.")
+	www := api.AddRecord("zone-1", "app-www", "A", "127.0.0.1")
+
+	policy := &hetzner_dns.Policy{
+		AllowedZones:           []string{"*.example.com"},
+		NamePatterns:           []string{"app-*", "@"},
+		AllowedTypes:           []string{"A", "AAAA", "CNAME", "TXT", "NS"},
+		MinTTL:                 60,
+		MaxTTL:                 3600,
+		ForbidApex:             true,
+		ForbidNS:               true,
+		MaxRecordsPerOperation: 2,
+	}
+	client := api.Client()
+	policy.Enforce(client)
+
+	violations := []struct {
+		rule string
+		call func() error
+	}{
+		{hetzner_dns.POLICY_ZONE, func() error {
+			_, err := client.CreateRecord(ctx, hetzner_dns.RecordRequest{ZoneID: "zone-2", Name: "app-x", Type: "A", Value: "127.0.0.1"})
+			return err
+		}},
+		{hetzner_dns.POLICY_NAME, func() error {
+			_, err := client.CreateRecord(ctx, hetzner_dns.RecordRequest{ZoneID: "zone-1", Name: "www", Type: "A", Value: "127.0.0.1"})
+			return err
+		}},
+		{hetzner_dns.POLICY_TYPE, func() error {
+			_, err := client.CreateRecord(ctx, hetzner_dns.RecordRequest{ZoneID: "zone-1", Name: "app-mx", Type: "MX", Value: "10 mail"})
+			return err
+		}},
+		{hetzner_dns.POLICY_TTL, func() error {
+			_, err := client.CreateRecord(ctx, hetzner_dns.RecordRequest{ZoneID: "zone-1", Name: "app-x", Type: "A", Value: "127.0.0.1", TTL: 30})
+			return err
+		}},
+		{hetzner_dns.POLICY_APEX, func() error {
+			return client.DeleteRecord(ctx, apexNS)
+		}},
+		{hetzner_dns.POLICY_NS, func() error {
+			_, err := client.CreateRecord(ctx, hetzner_dns.RecordRequest{ZoneID: "zone-1", Name: "app-sub", Type: "NS", Value: "ns1.example.net."})
+			return err
+		}},
+		{hetzner_dns.POLICY_APEX, func() error {
+			// the current content of the record is checked too
+			_, err := client.UpdateRecord(ctx, hetzner_dns.RecordRequest{ID: apexNS, ZoneID: "zone-1", Name: "app-ns", Type: "A", Value: "127.0.0.1"})
+			return err
+		}},
+		{hetzner_dns.POLICY_MAX_RECORDS, func() error {
+			_, err := client.BulkCreateRecords(ctx, &hetzner_dns.BulkRecordRequest{Records: []hetzner_dns.RecordRequest{
+				{ZoneID: "zone-1", Name: "app-1", Type: "A", Value: "127.0.0.1"},
+				{ZoneID: "zone-1", Name: "app-2", Type: "A", Value: "127.0.0.1"},
+				{ZoneID: "zone-1", Name: "app-3", Type: "A", Value: "127.0.0.1"},
+			}})
+			return err
+		}},
+	}
+	for i, violation := range violations {
+		err := violation.call()
+		var policyViolation *hetzner_dns.PolicyViolation
+		if !errors.As(err, &policyViolation) {
+			t.Errorf("%d: expected a policy violation, got %v", i, err)
+			continue
+		}
+		if policyViolation.Rule != violation.rule {
+			t.Errorf("%d: expected rule %s, got %s (%v)", i, violation.rule, policyViolation.Rule, err)
+		}
+	}
+	if count := api.CallCount("POST") + api.CallCount("PUT") + api.CallCount("DELETE"); count != 0 {
+		t.Errorf("expected no mutating request, got %d", count)
+	}
+
+	if _, err := client.UpdateRecord(ctx, hetzner_dns.RecordRequest{ID: www, ZoneID: "zone-1", Name: "app-www", Type: "A", Value: "127.0.0.2", TTL: 300}); err != nil {
+		t.Errorf("expected the update to be allowed, got %v", err)
+	}
+}
+
+func TestLoadPolicy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "policy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "policy.yaml")
+	data := "allowed_zones: [\"*.example.com\"]\nforbid_ns: true\nmax_ttl: 3600\n"
+	if err := ioutil.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := hetzner_dns.LoadPolicy(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(policy.AllowedZones) != 1 || !policy.ForbidNS || policy.MaxTTL != 3600 {
+		t.Errorf("unexpected policy %+v", policy)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("forbid_nss: true\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hetzner_dns.LoadPolicy(path); err == nil {
+		t.Error("expected an error for an unknown field")
+	}
+}
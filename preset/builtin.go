@@ -0,0 +1,89 @@
+package preset
+
+// dmarcRecord is the DMARC record shared by the mail presets.
+const dmarcRecord = `
+  - name: _dmarc
+    type: TXT
+    txt: "v=DMARC1; p={{.dmarc_policy}}{{if .dmarc_rua}}; rua=mailto:{{.dmarc_rua}}{{end}}"
+`
+
+// dmarcVariables are the variables of dmarcRecord.
+const dmarcVariables = `
+  - name: dmarc_policy
+    description: DMARC policy (none, quarantine or reject)
+    default: none
+  - name: dmarc_rua
+    description: address receiving the DMARC aggregate reports
+`
+
+var builtinPresets = []string{`
+name: web
+description: website on an IPv4 (and optionally IPv6) address, with www, CAA and a verification TXT
+variables:
+  - name: ipv4
+    description: IPv4 address of the web server
+    required: true
+  - name: ipv6
+    description: IPv6 address of the web server
+  - name: ca
+    description: certificate authority allowed to issue certificates
+    default: letsencrypt.org
+  - name: txt_verification
+    description: content of a verification TXT record on the apex
+records:
+  - {name: "@", type: A, value: "{{.ipv4}}"}
+  - {name: "@", type: AAAA, value: "{{.ipv6}}", when: ipv6}
+  - {name: www, type: CNAME, value: "{{.zone}}."}
+  - {name: "@", type: CAA, value: "0 issue \"{{.ca}}\""}
+  - {name: "@", type: TXT, txt: "{{.txt_verification}}", when: txt_verification}
+`, `
+name: google-workspace
+description: Google Workspace mail, with SPF, DMARC and site verification
+variables:
+  - name: verification
+    description: google-site-verification token
+` + dmarcVariables + `
+records:
+  - {name: "@", type: MX, mx: {priority: 1, host: smtp.google.com.}}
+  - {name: "@", type: TXT, txt: "v=spf1 include:_spf.google.com ~all"}
+  - {name: "@", type: TXT, txt: "google-site-verification={{.verification}}", when: verification}
+` + dmarcRecord, `
+name: microsoft-365
+description: Microsoft 365 mail, with autodiscover, SPF, DMARC and domain verification
+variables:
+  - name: verification
+    description: MS=msXXXXXXXX verification token, without the MS= prefix
+` + dmarcVariables + `
+records:
+  - {name: "@", type: MX, mx: {priority: 0, host: "{{replace \".\" \"-\" .zone}}.mail.protection.outlook.com."}}
+  - {name: "@", type: TXT, txt: "v=spf1 include:spf.protection.outlook.com -all"}
+  - {name: autodiscover, type: CNAME, value: autodiscover.outlook.com.}
+  - {name: "@", type: TXT, txt: "MS={{.verification}}", when: verification}
+` + dmarcRecord, `
+name: fastmail
+description: Fastmail mail, with SPF, DKIM and DMARC
+variables:
+` + dmarcVariables + `
+records:
+  - {name: "@", type: MX, mx: {priority: 10, host: in1-smtp.messagingengine.com.}}
+  - {name: "@", type: MX, mx: {priority: 20, host: in2-smtp.messagingengine.com.}}
+  - {name: "@", type: TXT, txt: "v=spf1 include:spf.messagingengine.com ?all"}
+  - {name: fm1._domainkey, type: CNAME, value: "fm1.{{.zone}}.dkim.fmhosted.com."}
+  - {name: fm2._domainkey, type: CNAME, value: "fm2.{{.zone}}.dkim.fmhosted.com."}
+  - {name: fm3._domainkey, type: CNAME, value: "fm3.{{.zone}}.dkim.fmhosted.com."}
+` + dmarcRecord,
+}
+
+// Builtin returns a library with the built-in presets: web, google-workspace,
+// microsoft-365 and fastmail.
+func Builtin() Library {
+	library := Library{}
+	for _, data := range builtinPresets {
+		preset, err := Parse([]byte(data))
+		if err != nil {
+			panic(err)
+		}
+		library.Add(preset)
+	}
+	return library
+}
@@ -0,0 +1,330 @@
+// Package preset provisions zones from presets: templates of records with
+// variables (addresses, tokens, ...), rendered into record requests and
+// created with a bulk call, creating the zone if needed.
+package preset
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+	"github.com/panta/go-hetzner-dns/config"
+)
+
+// ZONE_VARIABLE is the variable set to the name of the zone being
+// provisioned.
+const ZONE_VARIABLE = "zone"
+
+var variableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// templateFuncs are the functions available to the templates, besides the
+// text/template builtins.
+var templateFuncs = template.FuncMap{
+	"replace": func(old string, new string, s string) string { return strings.ReplaceAll(s, old, new) },
+	"lower":   strings.ToLower,
+	"upper":   strings.ToUpper,
+}
+
+// Variable is a variable of a preset, referenced in its records as
+// {{.name}}.
+type Variable struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	Default     string `yaml:"default"`
+	Required    bool   `yaml:"required"`
+}
+
+// Record is a record of a preset. All its strings are templates.
+type Record struct {
+	config.RecordConfig `yaml:",inline"`
+	// When is the name of a variable: the record is skipped if it is empty.
+	When string `yaml:"when"`
+}
+
+// Preset is a set of records to provision zones with.
+type Preset struct {
+	Name        string     `yaml:"name"`
+	Description string     `yaml:"description"`
+	Variables   []Variable `yaml:"variables"`
+	Records     []Record   `yaml:"records"`
+}
+
+// Parse parses a preset (YAML or JSON).
+func Parse(data []byte) (*Preset, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	preset := &Preset{}
+	if err := decoder.Decode(preset); err != nil {
+		return nil, errors.Wrap(err, "can't parse preset")
+	}
+	if err := preset.Validate(); err != nil {
+		return nil, err
+	}
+	return preset, nil
+}
+
+// LoadFile reads a preset file. The preset name defaults to the file name
+// without extension.
+func LoadFile(path string) (*Preset, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't read preset")
+	}
+	preset, err := Parse(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "preset %s", path)
+	}
+	if preset.Name == "" {
+		preset.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return preset, nil
+}
+
+// Validate checks the variables and the templates of the preset.
+func (preset *Preset) Validate() error {
+	declared := map[string]bool{ZONE_VARIABLE: true}
+	for _, variable := range preset.Variables {
+		if !variableName.MatchString(variable.Name) {
+			return errors.Errorf("preset %s: invalid variable name %q", preset.Name, variable.Name)
+		}
+		if declared[variable.Name] {
+			return errors.Errorf("preset %s: variable %s declared more than once", preset.Name, variable.Name)
+		}
+		declared[variable.Name] = true
+	}
+	for i, record := range preset.Records {
+		if record.When != "" && !declared[record.When] {
+			return errors.Errorf("preset %s: record #%d: unknown variable %s", preset.Name, i+1, record.When)
+		}
+		for _, text := range record.templates() {
+			if _, err := template.New("").Funcs(templateFuncs).Parse(*text); err != nil {
+				return errors.Wrapf(err, "preset %s: record #%d", preset.Name, i+1)
+			}
+		}
+	}
+	return nil
+}
+
+// Render renders the records of the preset for the zone zoneName, with the
+// given variables (extra ones are ignored). Records with an empty TTL get
+// ttl.
+func (preset *Preset) Render(zoneName string, variables map[string]string, ttl int) ([]hetzner_dns.RecordRequest, error) {
+	values := map[string]string{}
+	var missing []string
+	for _, variable := range preset.Variables {
+		value, ok := variables[variable.Name]
+		if !ok || value == "" {
+			value = variable.Default
+		}
+		if value == "" && variable.Required {
+			missing = append(missing, variable.Name)
+		}
+		values[variable.Name] = value
+	}
+	if len(missing) > 0 {
+		return nil, errors.Errorf("preset %s: missing variables: %s", preset.Name, strings.Join(missing, ", "))
+	}
+	values[ZONE_VARIABLE] = strings.TrimSuffix(zoneName, ".")
+
+	zone := config.ZoneConfig{Name: zoneName, DefaultTTL: ttl}
+	for i, record := range preset.Records {
+		if record.When != "" && values[record.When] == "" {
+			continue
+		}
+		// MX and SRV are copied, not to render the preset itself
+		if record.MX != nil {
+			mx := *record.MX
+			record.MX = &mx
+		}
+		if record.SRV != nil {
+			srv := *record.SRV
+			record.SRV = &srv
+		}
+		for _, text := range record.templates() {
+			var err error
+			if *text, err = render(*text, values); err != nil {
+				return nil, errors.Wrapf(err, "preset %s: record #%d", preset.Name, i+1)
+			}
+		}
+		zone.Records = append(zone.Records, record.RecordConfig)
+	}
+
+	zonesConfig := &config.Config{Zones: []config.ZoneConfig{zone}}
+	if err := zonesConfig.Validate(); err != nil {
+		return nil, errors.Wrapf(err, "preset %s", preset.Name)
+	}
+	return zonesConfig.RecordRequests(zone)
+}
+
+// templates returns the strings of the record which are templates.
+func (record *Record) templates() []*string {
+	texts := []*string{&record.Name, &record.Type, &record.Value, &record.TXT}
+	if record.MX != nil {
+		texts = append(texts, &record.MX.Host)
+	}
+	if record.SRV != nil {
+		texts = append(texts, &record.SRV.Target)
+	}
+	return texts
+}
+
+func render(text string, values map[string]string) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	tmpl, err := template.New("").Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, values); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// Library is a set of presets by name.
+type Library map[string]*Preset
+
+// Add adds preset to the library, replacing any preset with the same name.
+func (library Library) Add(preset *Preset) {
+	library[preset.Name] = preset
+}
+
+// LoadDir adds the presets of the .yaml, .yml and .json files of dir.
+func (library Library) LoadDir(dir string) error {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return errors.Wrap(err, "can't read presets directory")
+	}
+	for _, entry := range entries {
+		switch filepath.Ext(entry.Name()) {
+		case ".yaml", ".yml", ".json":
+		default:
+			continue
+		}
+		preset, err := LoadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+		library.Add(preset)
+	}
+	return nil
+}
+
+// Names returns the names of the presets, sorted.
+func (library Library) Names() []string {
+	names := make([]string, 0, len(library))
+	for name := range library {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Render renders the named presets for the zone zoneName, dropping records
+// rendered more than once.
+func (library Library) Render(names []string, zoneName string, variables map[string]string, ttl int) ([]hetzner_dns.RecordRequest, error) {
+	var records []hetzner_dns.RecordRequest
+	seen := map[string]bool{}
+	for _, name := range names {
+		preset, ok := library[name]
+		if !ok {
+			return nil, errors.Errorf("preset: unknown preset %s", name)
+		}
+		rendered, err := preset.Render(zoneName, variables, ttl)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range rendered {
+			if key := hetzner_dns.RecordKey(record.Name, record.Type, record.Value); !seen[key] {
+				seen[key] = true
+				records = append(records, record)
+			}
+		}
+	}
+	return records, nil
+}
+
+// Result is the outcome of Provision.
+type Result struct {
+	ZoneID      string
+	ZoneCreated bool
+	// Created are the records created (with their IDs), Skipped those
+	// already existing.
+	Created []hetzner_dns.RecordRequest
+	Skipped []hetzner_dns.RecordRequest
+}
+
+// Provision creates records in the zone zoneName, creating the zone first
+// (with the default TTL ttl) if it doesn't exist. Records already existing
+// with the same name, type and value are skipped; the others are created
+// with a single BulkCreateRecords call.
+func Provision(ctx context.Context, client *hetzner_dns.Client, zoneName string, ttl int, records []hetzner_dns.RecordRequest) (*Result, error) {
+	zones, err := client.GetAllZones(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't get zones")
+	}
+	result := &Result{}
+	for _, zone := range zones {
+		if strings.EqualFold(strings.TrimSuffix(zone.Name, "."), strings.TrimSuffix(zoneName, ".")) {
+			result.ZoneID = zone.ID
+			break
+		}
+	}
+	if result.ZoneID == "" {
+		zoneResponse, err := client.CreateZone(ctx, hetzner_dns.ZoneRequest{Name: zoneName, TTL: ttl})
+		if err != nil {
+			return nil, errors.Wrapf(err, "can't create zone %s", zoneName)
+		}
+		result.ZoneID = zoneResponse.Zone.ID
+		result.ZoneCreated = true
+	}
+
+	// new zones only have their default SOA and NS records
+	existing := map[string]bool{}
+	if !result.ZoneCreated {
+		current, err := client.GetAllRecords(ctx, result.ZoneID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "can't get records for zone %s", zoneName)
+		}
+		for _, record := range current {
+			existing[hetzner_dns.RecordKey(record.Name, record.Type, record.Value)] = true
+		}
+	}
+
+	bulk := &hetzner_dns.BulkRecordRequest{}
+	for _, record := range records {
+		record.ZoneID = result.ZoneID
+		if existing[hetzner_dns.RecordKey(record.Name, record.Type, record.Value)] {
+			result.Skipped = append(result.Skipped, record)
+			continue
+		}
+		bulk.Records = append(bulk.Records, record)
+	}
+	if len(bulk.Records) == 0 {
+		return result, nil
+	}
+	response, err := client.BulkCreateRecords(ctx, bulk)
+	if err != nil {
+		return result, errors.Wrapf(err, "can't create records in zone %s", zoneName)
+	}
+	for _, record := range response.Records {
+		created := record.Request()
+		created.ID = record.ID
+		result.Created = append(result.Created, created)
+	}
+	if n := len(response.InvalidRecords) + len(response.FailedRecords); n > 0 {
+		return result, errors.Errorf("preset: %d records of zone %s failed to create", n, zoneName)
+	}
+	return result, nil
+}
@@ -0,0 +1,159 @@
+package preset_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+	"github.com/panta/go-hetzner-dns/internal/fakeapi"
+	"github.com/panta/go-hetzner-dns/preset"
+)
+
+func describe(records []hetzner_dns.RecordRequest) []string {
+	var lines []string
+	for _, record := range records {
+		lines = append(lines, record.Name+" "+record.Type+" "+record.Value)
+	}
+	return lines
+}
+
+func TestBuiltin(t *testing.T) {
+	library := preset.Builtin()
+	if names := strings.Join(library.Names(), ","); names != "fastmail,google-workspace,microsoft-365,web" {
+		t.Errorf("unexpected builtin presets %s", names)
+	}
+
+	records, err := library.Render([]string{"web", "google-workspace"}, "example.com", map[string]string{
+		"ipv4":         "192.0.2.1",
+		"verification": "abc",
+		"dmarc_rua":    "dmarc@example.com",
+	}, 3600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"@ A 192.0.2.1",
+		"www CNAME example.com.",
+		`@ CAA 0 issue "letsencrypt.org"`,
+		"@ MX 1 smtp.google.com.",
+		"@ TXT v=spf1 include:_spf.google.com ~all",
+		"@ TXT google-site-verification=abc",
+		"_dmarc TXT v=DMARC1; p=none; rua=mailto:dmarc@example.com",
+	}
+	if got := describe(records); strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected records\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+	for _, record := range records {
+		if record.TTL != 3600 {
+			t.Errorf("expected TTL 3600, got %d", record.TTL)
+		}
+	}
+
+	records, err = library.Render([]string{"microsoft-365"}, "example.co.uk", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records[0].Value != "0 example-co-uk.mail.protection.outlook.com." || len(records) != 4 {
+		t.Errorf("unexpected records %v", describe(records))
+	}
+
+	if _, err := library.Render([]string{"web"}, "example.com", nil, 0); err == nil || !strings.Contains(err.Error(), "ipv4") {
+		t.Errorf("expected a missing variable error, got %v", err)
+	}
+	if _, err := library.Render([]string{"nope"}, "example.com", nil, 0); err == nil {
+		t.Errorf("expected an unknown preset error")
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "presets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	custom := `
+variables:
+  - {name: host, required: true}
+records:
+  - {name: "{{.host}}", type: A, value: 192.0.2.10}
+  - {name: "_srv._tcp", type: SRV, srv: {priority: 10, weight: 5, port: 443, target: "{{.host}}.{{.zone}}."}}
+`
+	if err := ioutil.WriteFile(filepath.Join(dir, "custom.yaml"), []byte(custom), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	library := preset.Builtin()
+	if err := library.LoadDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	records, err := library.Render([]string{"custom"}, "example.com", map[string]string{"host": "app"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(describe(records), ","); got != "app A 192.0.2.10,_srv._tcp SRV 10 5 443 app.example.com." {
+		t.Errorf("unexpected records %s", got)
+	}
+
+	for _, invalid := range []string{
+		"records:\n  - {name: x, type: A, value: \"{{.ip\"}\n",
+		"variables:\n  - {name: bad-name}\n",
+		"records:\n  - {name: x, type: A, value: 1.2.3.4, when: nope}\n",
+		"unknown: field\n",
+	} {
+		if _, err := preset.Parse([]byte(invalid)); err == nil {
+			t.Errorf("expected an error parsing %q", invalid)
+		}
+	}
+	undeclared, _ := preset.Parse([]byte("records:\n  - {name: x, type: A, value: \"{{.ip}}\"}\n"))
+	if _, err := undeclared.Render("example.com", nil, 0); err == nil {
+		t.Errorf("expected an error for an undeclared variable")
+	}
+}
+
+func TestProvision(t *testing.T) {
+	api := fakeapi.New(t)
+	defer api.Close()
+	client := api.Client()
+
+	records, err := preset.Builtin().Render([]string{"web"}, "example.com", map[string]string{"ipv4": "192.0.2.1"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := preset.Provision(context.Background(), client, "example.com", 3600, records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.ZoneCreated || result.ZoneID == "" || len(api.Zones()) != 1 {
+		t.Errorf("expected the zone to be created, got %+v", result)
+	}
+	// the records of a new zone are not fetched
+	if len(result.Skipped) != 0 || len(result.Created) != 3 || result.Created[0].ID == "" || api.CallCount("GET /records") != 0 {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	// provisioning an existing zone skips the existing records
+	existing := fakeapi.New(t)
+	defer existing.Close()
+	existing.AddZone("zone-1", "example.com")
+	existing.AddRecord("zone-1", "@", "A", "192.0.2.1")
+	result, err = preset.Provision(context.Background(), existing.Client(), "example.com.", 3600, records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ZoneCreated || len(result.Skipped) != 1 || len(result.Created) != 2 || len(existing.ZoneRecords("zone-1")) != 3 {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	// records rejected by the API are errors, and not reported as created
+	records = append(records, hetzner_dns.RecordRequest{Name: "bad", Type: "TXT"})
+	result, err = preset.Provision(context.Background(), client, "example.org", 3600, records)
+	if err == nil || !strings.Contains(err.Error(), "1 records") {
+		t.Errorf("expected an error for the invalid record, got %v", err)
+	}
+	if result == nil || len(result.Created) != 3 {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
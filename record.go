@@ -9,6 +9,19 @@ type RecordRequest struct {
 	TTL    int    `json:"ttl"`
 }
 
+// Record returns a Record with the same content as the request, e.g. to
+// compare a desired state with live records.
+func (request *RecordRequest) Record() Record {
+	return Record{
+		ID:     request.ID,
+		ZoneID: request.ZoneID,
+		Type:   request.Type,
+		Name:   request.Name,
+		Value:  request.Value,
+		TTL:    request.TTL,
+	}
+}
+
 type BulkRecordRequest struct {
 	Records []RecordRequest `json:"records"`
 }
@@ -24,12 +37,25 @@ type Record struct {
 	TTL      int         `json:"ttl"`
 }
 
+// Request returns a RecordRequest with the same content as the record,
+// without its ID.
+func (record *Record) Request() RecordRequest {
+	return RecordRequest{
+		ZoneID: record.ZoneID,
+		Type:   record.Type,
+		Name:   record.Name,
+		Value:  record.Value,
+		TTL:    record.TTL,
+	}
+}
+
 type RecordResponse struct {
 	Record Record `json:"record"`
 }
 
 type RecordsResponse struct {
 	Records []Record `json:"records"`
+	Meta    Meta     `json:"meta"`
 }
 
 type BulkRecordResponse struct {
@@ -0,0 +1,396 @@
+package hetzner_dns
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	DEFAULT_REGISTRY_PREFIX = "_hdns-owner"
+	REGISTRY_HERITAGE       = "go-hetzner-dns"
+)
+
+var ErrNotOwner = errors.New("hetzner_dns: record not owned by this owner")
+
+// OwnershipError is returned when a Registry refuses to touch records owned
+// by someone else. Owner is empty for records not managed by any registry.
+type OwnershipError struct {
+	Name  string
+	Type  string
+	Owner string
+}
+
+func (e *OwnershipError) Error() string {
+	if e.Owner == "" {
+		return fmt.Sprintf("hetzner_dns: %s %s records are not managed by any owner", e.Name, e.Type)
+	}
+	return fmt.Sprintf("hetzner_dns: %s %s records are owned by %q", e.Name, e.Type, e.Owner)
+}
+
+// Is makes errors.Is(err, ErrNotOwner) true for ownership errors.
+func (e *OwnershipError) Is(target error) bool {
+	return target == ErrNotOwner
+}
+
+// Registry tracks the owner of records through companion TXT records, in the
+// spirit of the external-dns TXT registry, so that several systems can share
+// a zone without overwriting each other's records.
+//
+// Ownership applies to all the records with the same name and type: the
+// companion of the "www" A records is a TXT record named "_hdns-owner-a.www"
+// with value "heritage=go-hetzner-dns,owner=<OwnerID>".
+type Registry struct {
+	Client  *Client
+	OwnerID string
+	// Prefix of the companion TXT record names (DEFAULT_REGISTRY_PREFIX if empty).
+	Prefix string
+	// AdoptUnowned allows taking ownership of existing records not managed
+	// by any owner, instead of refusing to touch them.
+	AdoptUnowned bool
+}
+
+// NewRegistry creates a registry managing records on behalf of ownerId.
+func NewRegistry(client *Client, ownerId string) *Registry {
+	return &Registry{Client: client, OwnerID: ownerId}
+}
+
+// ZoneOwnership is a snapshot of the records of a zone and their owners.
+type ZoneOwnership struct {
+	registry *Registry
+	// Records are the records of the zone, without companion TXT records.
+	Records    []Record
+	owners     map[string]string
+	companions map[string][]Record
+}
+
+// Owner returns the owner of the records with the given name and type, or
+// the empty string if they are not managed.
+func (ownership *ZoneOwnership) Owner(name string, recordType string) string {
+	return ownership.owners[ownershipKey(name, recordType)]
+}
+
+// Owned returns the records owned by the registry owner.
+func (ownership *ZoneOwnership) Owned() []Record {
+	var owned []Record
+	for _, record := range ownership.Records {
+		if ownership.Owner(record.Name, record.Type) == ownership.registry.OwnerID {
+			owned = append(owned, record)
+		}
+	}
+	return owned
+}
+
+// check returns an error if the registry owner can't modify the records with
+// the given name and type.
+func (ownership *ZoneOwnership) check(name string, recordType string) error {
+	owner := ownership.Owner(name, recordType)
+	if owner == ownership.registry.OwnerID {
+		return nil
+	}
+	if owner == "" {
+		exists := false
+		for _, record := range ownership.Records {
+			if sameNameType(record.Name, record.Type, name, recordType) {
+				exists = true
+				break
+			}
+		}
+		if !exists || ownership.registry.AdoptUnowned {
+			return nil
+		}
+	}
+	return &OwnershipError{Name: normalizeName(name), Type: strings.ToUpper(recordType), Owner: owner}
+}
+
+// Ownership fetches the records of a zone along with their owners.
+func (registry *Registry) Ownership(ctx context.Context, zoneId string) (*ZoneOwnership, error) {
+	records, err := registry.Client.GetAllRecords(ctx, zoneId)
+	if err != nil {
+		return nil, err
+	}
+	ownership := &ZoneOwnership{
+		registry:   registry,
+		owners:     map[string]string{},
+		companions: map[string][]Record{},
+	}
+	for _, record := range records {
+		if record.Type == "TXT" {
+			if name, recordType, ok := registry.parseCompanionName(record.Name); ok {
+				key := ownershipKey(name, recordType)
+				ownership.companions[key] = append(ownership.companions[key], record)
+				if owner, ok := parseOwnershipValue(record.Value); ok {
+					ownership.owners[key] = owner
+				}
+				continue
+			}
+		}
+		ownership.Records = append(ownership.Records, record)
+	}
+	return ownership, nil
+}
+
+// CreateRecord creates a record, refusing if records with the same name and
+// type are owned by someone else.
+func (registry *Registry) CreateRecord(ctx context.Context, record RecordRequest) (*RecordResponse, error) {
+	ownership, err := registry.Ownership(ctx, record.ZoneID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ownership.check(record.Name, record.Type); err != nil {
+		return nil, err
+	}
+	recordResponse, err := registry.Client.CreateRecord(ctx, record)
+	if err != nil {
+		return nil, err
+	}
+	return recordResponse, registry.claim(ctx, ownership, record.ZoneID, record.Name, record.Type)
+}
+
+// UpdateRecord updates a record owned by the registry owner.
+func (registry *Registry) UpdateRecord(ctx context.Context, record RecordRequest) (*RecordResponse, error) {
+	if record.ID == "" {
+		return nil, ErrMissingID
+	}
+	current, err := registry.Client.GetRecord(ctx, record.ID)
+	if err != nil {
+		return nil, err
+	}
+	ownership, err := registry.Ownership(ctx, current.Record.ZoneID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ownership.check(current.Record.Name, current.Record.Type); err != nil {
+		return nil, err
+	}
+	if !sameNameType(current.Record.Name, current.Record.Type, record.Name, record.Type) {
+		if err := ownership.check(record.Name, record.Type); err != nil {
+			return nil, err
+		}
+	}
+	recordResponse, err := registry.Client.UpdateRecord(ctx, record)
+	if err != nil {
+		return nil, err
+	}
+	return recordResponse, registry.claim(ctx, ownership, current.Record.ZoneID, record.Name, record.Type)
+}
+
+// CreateOrUpdateRecord is the ownership-aware version of
+// Client.CreateOrUpdateRecord.
+func (registry *Registry) CreateOrUpdateRecord(ctx context.Context, record RecordRequest) (*RecordResponse, error) {
+	if record.ID != "" {
+		return registry.UpdateRecord(ctx, record)
+	}
+	ownership, err := registry.Ownership(ctx, record.ZoneID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ownership.check(record.Name, record.Type); err != nil {
+		return nil, err
+	}
+	for _, item := range ownership.Records {
+		if sameNameType(item.Name, item.Type, record.Name, record.Type) {
+			record.ID = item.ID
+			break
+		}
+	}
+	var recordResponse *RecordResponse
+	if record.ID != "" {
+		recordResponse, err = registry.Client.UpdateRecord(ctx, record)
+	} else {
+		recordResponse, err = registry.Client.CreateRecord(ctx, record)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return recordResponse, registry.claim(ctx, ownership, record.ZoneID, record.Name, record.Type)
+}
+
+// DeleteRecord deletes a record owned by the registry owner. The companion
+// TXT record is deleted along with the last record of its name and type.
+func (registry *Registry) DeleteRecord(ctx context.Context, recordId string) error {
+	if recordId == "" {
+		return ErrMissingID
+	}
+	current, err := registry.Client.GetRecord(ctx, recordId)
+	if err != nil {
+		return err
+	}
+	ownership, err := registry.Ownership(ctx, current.Record.ZoneID)
+	if err != nil {
+		return err
+	}
+	if err := ownership.check(current.Record.Name, current.Record.Type); err != nil {
+		return err
+	}
+	if err := registry.Client.DeleteRecord(ctx, recordId); err != nil {
+		return err
+	}
+	for _, record := range ownership.Records {
+		if record.ID != recordId && sameNameType(record.Name, record.Type, current.Record.Name, current.Record.Type) {
+			return nil
+		}
+	}
+	return registry.release(ctx, ownership, current.Record.Name, current.Record.Type)
+}
+
+// Plan computes the changes needed to reconcile the records owned by the
+// registry owner with desired. Records of other owners are never changed:
+// the plan fails if desired includes records owned by someone else. With
+// AdoptUnowned, the unowned records with the name and type of desired
+// records are adopted, i.e. updated or deleted like owned records.
+func (registry *Registry) Plan(ctx context.Context, zoneId string, desired []RecordRequest) (*ChangeSet, error) {
+	ownership, err := registry.Ownership(ctx, zoneId)
+	if err != nil {
+		return nil, err
+	}
+	adopted := map[string]bool{}
+	for _, request := range desired {
+		if err := ownership.check(request.Name, request.Type); err != nil {
+			return nil, err
+		}
+		if registry.AdoptUnowned && ownership.Owner(request.Name, request.Type) == "" {
+			adopted[ownershipKey(request.Name, request.Type)] = true
+		}
+	}
+	current := ownership.Owned()
+	for _, record := range ownership.Records {
+		if adopted[ownershipKey(record.Name, record.Type)] {
+			current = append(current, record)
+		}
+	}
+	return PlanChanges(zoneId, current, desired), nil
+}
+
+// Reconcile plans and applies the changes needed to reconcile the records
+// owned by the registry owner with desired, pruning only owned records, and
+// keeps the companion TXT records up to date.
+func (registry *Registry) Reconcile(ctx context.Context, zoneId string, desired []RecordRequest) (*ChangeSet, error) {
+	changeSet, err := registry.Plan(ctx, zoneId, desired)
+	if err != nil {
+		return nil, err
+	}
+	if changeSet.Empty() {
+		return changeSet, nil
+	}
+	if err := registry.Client.ApplyChanges(ctx, changeSet); err != nil {
+		return changeSet, err
+	}
+
+	ownership, err := registry.Ownership(ctx, zoneId)
+	if err != nil {
+		return changeSet, err
+	}
+	wanted := map[string]bool{}
+	for _, request := range desired {
+		key := ownershipKey(request.Name, request.Type)
+		if !wanted[key] {
+			wanted[key] = true
+			if err := registry.claim(ctx, ownership, zoneId, request.Name, request.Type); err != nil {
+				return changeSet, err
+			}
+		}
+	}
+	for key, owner := range ownership.owners {
+		if owner == registry.OwnerID && !wanted[key] {
+			parts := strings.SplitN(key, "\x00", 2)
+			if err := registry.release(ctx, ownership, parts[0], parts[1]); err != nil {
+				return changeSet, err
+			}
+		}
+	}
+	return changeSet, nil
+}
+
+// claim creates the companion TXT record marking name and type as owned, if
+// not already present.
+func (registry *Registry) claim(ctx context.Context, ownership *ZoneOwnership, zoneId string, name string, recordType string) error {
+	key := ownershipKey(name, recordType)
+	if ownership.owners[key] == registry.OwnerID {
+		return nil
+	}
+	recordResponse, err := registry.Client.CreateRecord(ctx, RecordRequest{
+		ZoneID: zoneId,
+		Type:   "TXT",
+		Name:   registry.companionName(name, recordType),
+		Value:  registry.ownershipValue(),
+	})
+	if err != nil {
+		return errors.Wrap(err, "can't create ownership record")
+	}
+	ownership.owners[key] = registry.OwnerID
+	ownership.companions[key] = append(ownership.companions[key], recordResponse.Record)
+	return nil
+}
+
+// release deletes the companion TXT records of name and type.
+func (registry *Registry) release(ctx context.Context, ownership *ZoneOwnership, name string, recordType string) error {
+	key := ownershipKey(name, recordType)
+	for _, companion := range ownership.companions[key] {
+		if err := registry.Client.DeleteRecord(ctx, companion.ID); err != nil {
+			return errors.Wrap(err, "can't delete ownership record")
+		}
+	}
+	delete(ownership.companions, key)
+	delete(ownership.owners, key)
+	return nil
+}
+
+func (registry *Registry) prefix() string {
+	if registry.Prefix == "" {
+		return DEFAULT_REGISTRY_PREFIX
+	}
+	return registry.Prefix
+}
+
+func (registry *Registry) companionName(name string, recordType string) string {
+	companion := registry.prefix() + "-" + strings.ToLower(recordType)
+	if name = normalizeName(name); name != "@" {
+		companion += "." + name
+	}
+	return companion
+}
+
+func (registry *Registry) parseCompanionName(companion string) (name string, recordType string, ok bool) {
+	prefix := registry.prefix() + "-"
+	if !strings.HasPrefix(companion, prefix) {
+		return "", "", false
+	}
+	rest := strings.TrimPrefix(companion, prefix)
+	name = "@"
+	if idx := strings.Index(rest, "."); idx >= 0 {
+		rest, name = rest[:idx], rest[idx+1:]
+	}
+	if rest == "" {
+		return "", "", false
+	}
+	return name, strings.ToUpper(rest), true
+}
+
+func (registry *Registry) ownershipValue() string {
+	return fmt.Sprintf("heritage=%s,owner=%s", REGISTRY_HERITAGE, registry.OwnerID)
+}
+
+func parseOwnershipValue(value string) (string, bool) {
+	value = strings.Trim(value, `"`)
+	heritage, owner := "", ""
+	for _, field := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(field), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		switch parts[0] {
+		case "heritage":
+			heritage = parts[1]
+		case "owner":
+			owner = parts[1]
+		}
+	}
+	return owner, heritage == REGISTRY_HERITAGE && owner != ""
+}
+
+func ownershipKey(name string, recordType string) string {
+	return normalizeName(name) + "\x00" + strings.ToUpper(recordType)
+}
@@ -0,0 +1,109 @@
+package hetzner_dns_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+	"github.com/panta/go-hetzner-dns/internal/fakeapi"
+)
+
+func TestRegistry(t *testing.T) {
+	api := fakeapi.New(t)
+	defer api.Close()
+	api.AddZone("zone-1", "example.com")
+	manualId := api.AddRecord("zone-1", "manual", "A", "10.0.0.1")
+
+	ctx := context.Background()
+	terraform := hetzner_dns.NewRegistry(api.Client(), "terraform")
+	acme := hetzner_dns.NewRegistry(api.Client(), "acme")
+
+	recordResponse, err := terraform.CreateRecord(ctx, hetzner_dns.RecordRequest{ZoneID: "zone-1", Name: "www", Type: "A", Value: "10.0.0.2"})
+	if err != nil {
+		t.Fatalf("CreateRecord failed: %v", err)
+	}
+	ownership, err := acme.Ownership(ctx, "zone-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owner := ownership.Owner("www", "A"); owner != "terraform" {
+		t.Errorf("Wrong owner: %q", owner)
+	}
+	if len(ownership.Records) != 2 {
+		t.Errorf("Companion records should be hidden: %+v", ownership.Records)
+	}
+
+	_, err = acme.CreateOrUpdateRecord(ctx, hetzner_dns.RecordRequest{ZoneID: "zone-1", Name: "www", Type: "A", Value: "10.0.0.3"})
+	if !errors.Is(err, hetzner_dns.ErrNotOwner) {
+		t.Errorf("Expected ErrNotOwner, got %v", err)
+	}
+	if err := acme.DeleteRecord(ctx, recordResponse.Record.ID); !errors.Is(err, hetzner_dns.ErrNotOwner) {
+		t.Errorf("Expected ErrNotOwner, got %v", err)
+	}
+	if err := terraform.DeleteRecord(ctx, manualId); !errors.Is(err, hetzner_dns.ErrNotOwner) {
+		t.Errorf("Unmanaged records should not be deleted, got %v", err)
+	}
+
+	// reconciling only prunes owned records
+	changeSet, err := terraform.Reconcile(ctx, "zone-1", []hetzner_dns.RecordRequest{
+		{Name: "api", Type: "A", Value: "10.0.0.4"},
+	})
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if changeSet.Count(hetzner_dns.CHANGE_UPDATE) != 0 || changeSet.Count(hetzner_dns.CHANGE_CREATE) != 1 || changeSet.Count(hetzner_dns.CHANGE_DELETE) != 1 {
+		t.Errorf("Wrong changes:\n%s", changeSet)
+	}
+	ownership, err = terraform.Ownership(ctx, "zone-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ownership.Owner("www", "A") != "" || ownership.Owner("api", "A") != "terraform" {
+		t.Error("Ownership records not updated after reconcile")
+	}
+	names := map[string]bool{}
+	for _, record := range api.ZoneRecords("zone-1") {
+		names[record.Name] = true
+	}
+	if !names["manual"] || names["www"] || !names["_hdns-owner-a.api"] || names["_hdns-owner-a.www"] {
+		t.Errorf("Wrong records after reconcile: %v", names)
+	}
+}
+
+func TestRegistryAdoptUnowned(t *testing.T) {
+	api := fakeapi.New(t)
+	defer api.Close()
+	api.AddZone("zone-1", "example.com")
+	manualId := api.AddRecord("zone-1", "manual", "A", "10.0.0.1")
+	api.AddRecord("zone-1", "other", "A", "10.0.0.2")
+
+	ctx := context.Background()
+	registry := hetzner_dns.NewRegistry(api.Client(), "terraform")
+	registry.AdoptUnowned = true
+
+	// the unowned record is updated in place, not duplicated, and unowned
+	// records of other names are kept
+	changeSet, err := registry.Reconcile(ctx, "zone-1", []hetzner_dns.RecordRequest{
+		{Name: "manual", Type: "A", Value: "10.0.0.5"},
+	})
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if changeSet.Count(hetzner_dns.CHANGE_UPDATE) != 1 || changeSet.Count(hetzner_dns.CHANGE_CREATE) != 0 || changeSet.Count(hetzner_dns.CHANGE_DELETE) != 0 {
+		t.Errorf("Wrong changes:\n%s", changeSet)
+	}
+	ownership, err := registry.Ownership(ctx, "zone-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ownership.Owner("manual", "A") != "terraform" || len(ownership.Records) != 2 {
+		t.Errorf("Expected the record to be adopted: %+v", ownership.Records)
+	}
+	for _, record := range ownership.Records {
+		if record.Name == "manual" && (record.ID != manualId || record.Value != "10.0.0.5") {
+			t.Errorf("Expected the record to be updated: %+v", record)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package hetzner_dns
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNoValueMatcher is returned by a Replacement with neither OldValue nor
+// Pattern.
+var ErrNoValueMatcher = errors.New("hetzner_dns: replacement needs an old value or a pattern")
+
+// Replacement rewrites the values of the records selected by Query.
+type Replacement struct {
+	// Query selects the records (zones, names, types, ...).
+	Query RecordQuery
+	// OldValue selects the records with exactly this value, which is
+	// replaced by Template.
+	OldValue string
+	// Pattern selects the records whose value matches it; the matches are
+	// replaced by Template, which can refer to submatches as in
+	// regexp.Expand (e.g. "$1").
+	Pattern  *regexp.Regexp
+	Template string
+}
+
+// newValue returns the new value of a record, and false if the record is
+// not affected.
+func (replacement *Replacement) newValue(value string) (string, bool) {
+	var newValue string
+	switch {
+	case replacement.Pattern != nil:
+		if !replacement.Pattern.MatchString(value) {
+			return "", false
+		}
+		newValue = replacement.Pattern.ReplaceAllString(value, replacement.Template)
+	case replacement.OldValue != "":
+		if value != replacement.OldValue {
+			return "", false
+		}
+		newValue = replacement.Template
+	default:
+		return "", false
+	}
+	return newValue, newValue != value
+}
+
+// ValueChange is the change of the value of a record.
+type ValueChange struct {
+	ZoneName string `json:"zone_name"`
+	Record   Record `json:"record"`
+	NewValue string `json:"new_value"`
+}
+
+// ReplacePlan is the preview of a replacement.
+type ReplacePlan struct {
+	Changes []ValueChange `json:"changes"`
+}
+
+// Empty returns true if no record is affected.
+func (plan *ReplacePlan) Empty() bool {
+	return len(plan.Changes) == 0
+}
+
+// String returns the affected records, one per line, with their old and new
+// values.
+func (plan *ReplacePlan) String() string {
+	var sb strings.Builder
+	for _, change := range plan.Changes {
+		fmt.Fprintf(&sb, "%s: %s %s %q -> %q\n", change.ZoneName, normalizeName(change.Record.Name),
+			change.Record.Type, change.Record.Value, change.NewValue)
+	}
+	return sb.String()
+}
+
+// PlanReplaceValues returns the records affected by replacement and their
+// new values, without changing anything.
+func (client *Client) PlanReplaceValues(ctx context.Context, replacement *Replacement, fanOut *FanOut) (*ReplacePlan, error) {
+	if replacement.OldValue == "" && replacement.Pattern == nil {
+		return nil, ErrNoValueMatcher
+	}
+	matches, err := client.SearchRecords(ctx, &replacement.Query, fanOut)
+	if err != nil {
+		return nil, err
+	}
+	plan := &ReplacePlan{}
+	for _, match := range matches {
+		if newValue, ok := replacement.newValue(match.Value); ok {
+			plan.Changes = append(plan.Changes, ValueChange{ZoneName: match.ZoneName, Record: match.Record, NewValue: newValue})
+		}
+	}
+	return plan, nil
+}
+
+// RollbackFile is the content of the file written before applying a
+// replacement, holding the original records.
+type RollbackFile struct {
+	Time    time.Time `json:"time"`
+	Records []Record  `json:"records"`
+}
+
+// ApplyReplaceValues applies plan with one BulkUpdateRecords call per zone.
+// The original records are written to rollbackPath (if set) before any
+// change, to allow restoring them with RollbackReplaceValues.
+func (client *Client) ApplyReplaceValues(ctx context.Context, plan *ReplacePlan, rollbackPath string) error {
+	if plan.Empty() {
+		return nil
+	}
+	if rollbackPath != "" {
+		rollback := RollbackFile{Time: time.Now().UTC()}
+		for _, change := range plan.Changes {
+			rollback.Records = append(rollback.Records, change.Record)
+		}
+		if err := writeRollback(rollbackPath, &rollback); err != nil {
+			return err
+		}
+	}
+
+	var zoneIds []string
+	byZone := map[string]*BulkRecordRequest{}
+	for _, change := range plan.Changes {
+		request := change.Record.Request()
+		request.ID, request.Value = change.Record.ID, change.NewValue
+		if _, ok := byZone[request.ZoneID]; !ok {
+			byZone[request.ZoneID] = &BulkRecordRequest{}
+			zoneIds = append(zoneIds, request.ZoneID)
+		}
+		byZone[request.ZoneID].Records = append(byZone[request.ZoneID].Records, request)
+	}
+	return client.bulkUpdateByZone(ctx, zoneIds, byZone)
+}
+
+// ReplaceValues plans and applies replacement, returning the applied plan.
+func (client *Client) ReplaceValues(ctx context.Context, replacement *Replacement, rollbackPath string) (*ReplacePlan, error) {
+	plan, err := client.PlanReplaceValues(ctx, replacement, nil)
+	if err != nil {
+		return nil, err
+	}
+	return plan, client.ApplyReplaceValues(ctx, plan, rollbackPath)
+}
+
+// LoadRollback reads a rollback file written by ApplyReplaceValues.
+func LoadRollback(path string) (*RollbackFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't read rollback file")
+	}
+	rollback := &RollbackFile{}
+	if err := json.Unmarshal(data, rollback); err != nil {
+		return nil, errors.Wrapf(err, "can't parse rollback file %s", path)
+	}
+	return rollback, nil
+}
+
+// RollbackReplaceValues restores the records saved in a rollback file.
+func (client *Client) RollbackReplaceValues(ctx context.Context, rollbackPath string) error {
+	rollback, err := LoadRollback(rollbackPath)
+	if err != nil {
+		return err
+	}
+	var zoneIds []string
+	byZone := map[string]*BulkRecordRequest{}
+	for _, record := range rollback.Records {
+		request := record.Request()
+		request.ID = record.ID
+		if _, ok := byZone[request.ZoneID]; !ok {
+			byZone[request.ZoneID] = &BulkRecordRequest{}
+			zoneIds = append(zoneIds, request.ZoneID)
+		}
+		byZone[request.ZoneID].Records = append(byZone[request.ZoneID].Records, request)
+	}
+	return client.bulkUpdateByZone(ctx, zoneIds, byZone)
+}
+
+func (client *Client) bulkUpdateByZone(ctx context.Context, zoneIds []string, byZone map[string]*BulkRecordRequest) error {
+	for _, zoneId := range zoneIds {
+		response, err := client.BulkUpdateRecords(ctx, byZone[zoneId])
+		if err != nil {
+			return errors.Wrapf(err, "can't update records of zone %s", zoneId)
+		}
+		if n := len(response.InvalidRecords) + len(response.FailedRecords); n > 0 {
+			return errors.Errorf("hetzner_dns: %d records of zone %s failed to update", n, zoneId)
+		}
+	}
+	return nil
+}
+
+func writeRollback(path string, rollback *RollbackFile) error {
+	data, err := json.MarshalIndent(rollback, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "can't encode rollback file")
+	}
+	if _, err := os.Stat(path); err == nil {
+		return errors.Errorf("hetzner_dns: rollback file %s already exists", path)
+	}
+	if err := ioutil.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return errors.Wrap(err, "can't write rollback file")
+	}
+	return nil
+}
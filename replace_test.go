@@ -0,0 +1,115 @@
+package hetzner_dns_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+	"github.com/panta/go-hetzner-dns/internal/fakeapi"
+)
+
+func TestReplaceValues(t *testing.T) {
+	ctx := context.Background()
+	api := fakeapi.New(t)
+	defer api.Close()
+	api.AddZone("zone-1", "example.com")
+	api.AddZone("zone-2", "example.org")
+	api.AddRecord("zone-1", "www", "CNAME", "old-lb.example.net.")
+	api.AddRecord("zone-1", "@", "MX", "10 mx1.old-mail.net.")
+	api.AddRecord("zone-2", "shop", "CNAME", "old-lb.example.net.")
+	api.AddRecord("zone-2", "@", "MX", "20 mx2.old-mail.net.")
+	client := api.Client()
+
+	dir, err := ioutil.TempDir("", "replace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	rollbackPath := filepath.Join(dir, "rollback.json")
+
+	if _, err := client.PlanReplaceValues(ctx, &hetzner_dns.Replacement{}, nil); err != hetzner_dns.ErrNoValueMatcher {
+		t.Errorf("expected ErrNoValueMatcher, got %v", err)
+	}
+
+	replacement := &hetzner_dns.Replacement{
+		Query:    hetzner_dns.RecordQuery{Types: []string{"MX"}},
+		Pattern:  regexp.MustCompile(`^(\d+) mx(\d)\.old-mail\.net\.$`),
+		Template: "$1 mx$2.new-mail.net.",
+	}
+	plan, err := client.PlanReplaceValues(ctx, replacement, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Changes) != 2 || plan.Changes[0].NewValue != "10 mx1.new-mail.net." || plan.Changes[1].NewValue != "20 mx2.new-mail.net." {
+		t.Fatalf("unexpected plan:\n%s", plan)
+	}
+	if count := api.CallCount("PUT"); count != 0 {
+		t.Errorf("expected the preview not to change anything, got %d updates", count)
+	}
+
+	if err := client.ApplyReplaceValues(ctx, plan, rollbackPath); err != nil {
+		t.Fatal(err)
+	}
+	if count := api.CallCount("PUT /records/bulk"); count != 2 {
+		t.Errorf("expected a bulk update per zone, got %d", count)
+	}
+	if records := api.ZoneRecords("zone-2"); records[1].Value != "20 mx2.new-mail.net." {
+		t.Errorf("unexpected records %+v", records)
+	}
+
+	rollback, err := hetzner_dns.LoadRollback(rollbackPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rollback.Records) != 2 || rollback.Records[0].Value != "10 mx1.old-mail.net." {
+		t.Errorf("unexpected rollback %+v", rollback)
+	}
+	if err := client.ApplyReplaceValues(ctx, plan, rollbackPath); err == nil {
+		t.Error("expected an error for an existing rollback file")
+	}
+
+	if err := client.RollbackReplaceValues(ctx, rollbackPath); err != nil {
+		t.Fatal(err)
+	}
+	if records := api.ZoneRecords("zone-2"); records[1].Value != "20 mx2.old-mail.net." {
+		t.Errorf("expected the records to be restored, got %+v", records)
+	}
+
+	plan, err = client.ReplaceValues(ctx, &hetzner_dns.Replacement{
+		Query:    hetzner_dns.RecordQuery{Types: []string{"CNAME"}},
+		OldValue: "old-lb.example.net.",
+		Template: "new-lb.example.net.",
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Changes) != 2 || api.ZoneRecords("zone-1")[0].Value != "new-lb.example.net." {
+		t.Errorf("unexpected plan:\n%s", plan)
+	}
+}
+
+func TestReplaceValuesRejected(t *testing.T) {
+	ctx := context.Background()
+	api := fakeapi.New(t)
+	defer api.Close()
+	api.AddZone("zone-1", "example.com")
+	api.AddRecord("zone-1", "@", "TXT", "v=spf1 include:old-mail.net ~all")
+	client := api.Client()
+
+	// the template renders an empty value, which the API rejects
+	plan, err := client.ReplaceValues(ctx, &hetzner_dns.Replacement{
+		Query:    hetzner_dns.RecordQuery{Types: []string{"TXT"}},
+		Pattern:  regexp.MustCompile(`^v=spf1 include:old-mail\.net ~all$`),
+		Template: "",
+	}, "")
+	if err == nil {
+		t.Fatal("expected an error for a record rejected by the API")
+	}
+	if len(plan.Changes) != 1 || api.ZoneRecords("zone-1")[0].Value != "v=spf1 include:old-mail.net ~all" {
+		t.Errorf("unexpected plan:\n%s", plan)
+	}
+}
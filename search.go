@@ -0,0 +1,135 @@
+package hetzner_dns
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RecordQuery selects records across zones. Empty fields match everything.
+type RecordQuery struct {
+	// Zones are glob patterns matching zone names, e.g. "*.example.com".
+	Zones []string
+	// Name is a glob pattern matching record names ("@" for the apex).
+	Name string
+	// NameRegexp matches record names.
+	NameRegexp *regexp.Regexp
+	// Types are the record types to select.
+	Types []string
+	// ValueContains selects the values containing it (case-insensitive).
+	ValueContains string
+	// ValueRegexp matches record values.
+	ValueRegexp *regexp.Regexp
+	// MinTTL and MaxTTL bound the TTL, the zone TTL being used for records
+	// without one.
+	MinTTL int
+	MaxTTL int
+
+	CreatedAfter   time.Time
+	CreatedBefore  time.Time
+	ModifiedAfter  time.Time
+	ModifiedBefore time.Time
+}
+
+// RecordMatch is a record selected by a query, with its zone.
+type RecordMatch struct {
+	ZoneName string `json:"zone_name"`
+	Record
+}
+
+// MatchZone returns true if the records of zone may be selected.
+func (query *RecordQuery) MatchZone(zone *Zone) bool {
+	return len(query.Zones) == 0 || matchAny(query.Zones, NormalizeZoneName(zone.Name))
+}
+
+// Match returns true if record, of zone, is selected by the query.
+func (query *RecordQuery) Match(zone *Zone, record *Record) bool {
+	if !query.MatchZone(zone) {
+		return false
+	}
+	name := normalizeName(record.Name)
+	if query.Name != "" && !matchAny([]string{query.Name}, name) {
+		return false
+	}
+	if query.NameRegexp != nil && !query.NameRegexp.MatchString(name) {
+		return false
+	}
+	if len(query.Types) > 0 && !containsFold(query.Types, record.Type) {
+		return false
+	}
+	if query.ValueContains != "" && !strings.Contains(strings.ToLower(record.Value), strings.ToLower(query.ValueContains)) {
+		return false
+	}
+	if query.ValueRegexp != nil && !query.ValueRegexp.MatchString(record.Value) {
+		return false
+	}
+	ttl := record.TTL
+	if ttl == 0 {
+		ttl = zone.TTL
+	}
+	if (query.MinTTL > 0 && ttl < query.MinTTL) || (query.MaxTTL > 0 && ttl > query.MaxTTL) {
+		return false
+	}
+	return inWindow(time.Time(record.Created), query.CreatedAfter, query.CreatedBefore) &&
+		inWindow(time.Time(record.Modified), query.ModifiedAfter, query.ModifiedBefore)
+}
+
+// SearchRecords returns the records of all the zones selected by query,
+// sorted by zone, name and type. The zones are fetched concurrently with
+// fanOut (a default one if nil).
+func (client *Client) SearchRecords(ctx context.Context, query *RecordQuery, fanOut *FanOut) ([]RecordMatch, error) {
+	zones, err := client.GetAllZones(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var selected []Zone
+	for i := range zones {
+		if query.MatchZone(&zones[i]) {
+			selected = append(selected, zones[i])
+		}
+	}
+	if fanOut == nil {
+		fanOut = &FanOut{}
+	}
+
+	var mu sync.Mutex
+	var matches []RecordMatch
+	err = fanOut.Run(ctx, selected, func(ctx context.Context, zone Zone) error {
+		records, err := client.GetAllRecords(ctx, zone.ID)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for i := range records {
+			if query.Match(&zone, &records[i]) {
+				matches = append(matches, RecordMatch{ZoneName: zone.Name, Record: records[i]})
+			}
+		}
+		return nil
+	})
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].ZoneName != matches[j].ZoneName {
+			return matches[i].ZoneName < matches[j].ZoneName
+		}
+		if matches[i].Name != matches[j].Name {
+			return matches[i].Name < matches[j].Name
+		}
+		return matches[i].Type < matches[j].Type
+	})
+	return matches, err
+}
+
+func inWindow(t time.Time, after time.Time, before time.Time) bool {
+	if !after.IsZero() && !t.After(after) {
+		return false
+	}
+	if !before.IsZero() && !t.Before(before) {
+		return false
+	}
+	return true
+}
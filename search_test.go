@@ -0,0 +1,57 @@
+package hetzner_dns_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+	"github.com/panta/go-hetzner-dns/internal/fakeapi"
+)
+
+func TestSearchRecords(t *testing.T) {
+	api := fakeapi.New(t)
+	defer api.Close()
+	api.AddZone("zone-1", "example.com")
+	api.AddZone("zone-2", "example.org")
+	api.AddRecord("zone-1", "www", "CNAME", "old-lb.example.net.")
+	api.AddRecord("zone-1", "@", "TXT", "v=spf1 include:_spf.example.net ~all")
+	api.AddRecord("zone-2", "shop", "CNAME", "old-lb.example.net.")
+	api.AddRecord("zone-2", "api", "CNAME", "new-lb.example.net.")
+	api.AddRecord("zone-2", "@", "TXT", "google-site-verification=abc")
+	client := api.Client()
+
+	tests := []struct {
+		query    hetzner_dns.RecordQuery
+		expected []string
+	}{
+		{hetzner_dns.RecordQuery{Types: []string{"cname"}, ValueContains: "old-lb.example.net"}, []string{"example.com www", "example.org shop"}},
+		{hetzner_dns.RecordQuery{Types: []string{"TXT"}, ValueRegexp: regexp.MustCompile(`^v=spf1 `)}, []string{"example.com @"}},
+		{hetzner_dns.RecordQuery{Zones: []string{"*.org"}, Name: "@"}, []string{"example.org @"}},
+		{hetzner_dns.RecordQuery{NameRegexp: regexp.MustCompile(`^(api|shop)$`)}, []string{"example.org api", "example.org shop"}},
+		{hetzner_dns.RecordQuery{Zones: []string{"example.com"}, MinTTL: 3600}, []string{"example.com @", "example.com www"}},
+		{hetzner_dns.RecordQuery{MaxTTL: 60}, nil},
+		{hetzner_dns.RecordQuery{ModifiedBefore: time.Now().Add(-time.Hour)}, nil},
+	}
+	for i, test := range tests {
+		matches, err := client.SearchRecords(context.Background(), &test.query, hetzner_dns.NewFanOut(2, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var found []string
+		for _, match := range matches {
+			found = append(found, match.ZoneName+" "+match.Name)
+		}
+		if len(found) != len(test.expected) {
+			t.Errorf("%d: expected %v, got %v", i, test.expected, found)
+			continue
+		}
+		for j := range found {
+			if found[j] != test.expected[j] {
+				t.Errorf("%d: expected %v, got %v", i, test.expected, found)
+				break
+			}
+		}
+	}
+}
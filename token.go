@@ -0,0 +1,261 @@
+package hetzner_dns
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	API_KEY_ENV             = "HETZNER_API_KEY"
+	DEFAULT_TOKEN_CACHE_TTL = time.Minute * 5
+)
+
+// TokenProvider provides the API token, which is requested before each API
+// call, so implementations can rotate it at any time.
+type TokenProvider interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// StaticToken is a fixed token.
+type StaticToken string
+
+func (token StaticToken) Token(ctx context.Context) (string, error) {
+	return string(token), nil
+}
+
+// EnvToken reads the token from the named environment variable.
+type EnvToken string
+
+func (name EnvToken) Token(ctx context.Context) (string, error) {
+	return os.Getenv(string(name)), nil
+}
+
+// DefaultTokenProvider is used by clients with neither ApiKey nor
+// TokenProvider set.
+var DefaultTokenProvider TokenProvider = EnvToken(API_KEY_ENV)
+
+// FileToken reads the token from a file, such as a Docker or Kubernetes
+// secret, reloading it when the file changes.
+type FileToken struct {
+	Path string
+
+	mu      sync.Mutex
+	token   string
+	modTime time.Time
+	size    int64
+}
+
+// NewFileToken creates a provider reading the token from path.
+func NewFileToken(path string) *FileToken {
+	return &FileToken{Path: path}
+}
+
+func (fileToken *FileToken) Token(ctx context.Context) (string, error) {
+	fileToken.mu.Lock()
+	defer fileToken.mu.Unlock()
+	info, err := os.Stat(fileToken.Path)
+	if err != nil {
+		return "", errors.Wrap(err, "can't stat token file")
+	}
+	if fileToken.token != "" && info.ModTime().Equal(fileToken.modTime) && info.Size() == fileToken.size {
+		return fileToken.token, nil
+	}
+	data, err := ioutil.ReadFile(fileToken.Path)
+	if err != nil {
+		return "", errors.Wrap(err, "can't read token file")
+	}
+	fileToken.token = strings.TrimSpace(string(data))
+	fileToken.modTime, fileToken.size = info.ModTime(), info.Size()
+	return fileToken.token, nil
+}
+
+// cachedToken holds a token fetched by a provider for a limited time.
+type cachedToken struct {
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func (cached *cachedToken) get(ttl time.Duration, fetch func() (string, error)) (string, error) {
+	cached.mu.Lock()
+	defer cached.mu.Unlock()
+	if cached.token != "" && time.Now().Before(cached.expires) {
+		return cached.token, nil
+	}
+	token, err := fetch()
+	if err != nil {
+		return "", err
+	}
+	if ttl <= 0 {
+		ttl = DEFAULT_TOKEN_CACHE_TTL
+	}
+	cached.token, cached.expires = token, time.Now().Add(ttl)
+	return token, nil
+}
+
+// CommandToken runs a command, such as `pass show hetzner/dns`, and uses its
+// trimmed standard output as the token.
+type CommandToken struct {
+	Command []string
+	// CacheTTL is how long the token is kept before running the command
+	// again (DEFAULT_TOKEN_CACHE_TTL if zero).
+	CacheTTL time.Duration
+
+	cached cachedToken
+}
+
+// NewCommandToken creates a provider running name with args.
+func NewCommandToken(name string, args ...string) *CommandToken {
+	return &CommandToken{Command: append([]string{name}, args...)}
+}
+
+func (commandToken *CommandToken) Token(ctx context.Context) (string, error) {
+	return commandToken.cached.get(commandToken.CacheTTL, func() (string, error) {
+		if len(commandToken.Command) == 0 {
+			return "", errors.New("hetzner_dns: empty token command")
+		}
+		cmd := exec.CommandContext(ctx, commandToken.Command[0], commandToken.Command[1:]...)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		output, err := cmd.Output()
+		if err != nil {
+			return "", errors.Wrapf(err, "token command failed: %s", strings.TrimSpace(stderr.String()))
+		}
+		return strings.TrimSpace(string(output)), nil
+	})
+}
+
+// VaultToken reads the token from a secret of a Vault-compatible HTTP API,
+// supporting both KV version 1 and 2 secret engines.
+type VaultToken struct {
+	// Address of the server, e.g. "http://127.0.0.1:8200".
+	Address string
+	// Path of the secret, e.g. "secret/data/hetzner".
+	Path string
+	// Field of the secret holding the token ("token" if empty).
+	Field string
+	// VaultToken authenticates to the server (VAULT_TOKEN if empty).
+	VaultToken string
+	// CacheTTL is how long the token is kept before reading the secret again
+	// (DEFAULT_TOKEN_CACHE_TTL if zero).
+	CacheTTL time.Duration
+
+	HttpClient *http.Client
+
+	cached cachedToken
+}
+
+func (vaultToken *VaultToken) Token(ctx context.Context) (string, error) {
+	return vaultToken.cached.get(vaultToken.CacheTTL, func() (string, error) {
+		return vaultToken.fetch(ctx)
+	})
+}
+
+func (vaultToken *VaultToken) fetch(ctx context.Context) (string, error) {
+	url := fmt.Sprintf("%s/v1/%s", strings.TrimSuffix(vaultToken.Address, "/"), strings.TrimPrefix(vaultToken.Path, "/"))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", errors.Wrap(err, "can't create vault request")
+	}
+	authToken := vaultToken.VaultToken
+	if authToken == "" {
+		authToken = os.Getenv("VAULT_TOKEN")
+	}
+	req.Header.Set("X-Vault-Token", authToken)
+
+	httpClient := vaultToken.HttpClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: DEFAULT_TIMEOUT}
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, "can't perform vault request")
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", errors.Wrap(err, "can't read vault response")
+	}
+	if !isHttpSuccess(resp.StatusCode) {
+		return "", errors.Errorf("hetzner_dns: vault returned %d for %s", resp.StatusCode, vaultToken.Path)
+	}
+
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return "", errors.Wrap(err, "can't parse vault response")
+	}
+	data := secret.Data
+	// KV version 2 nests the secret in data.data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		data = nested
+	}
+	field := vaultToken.Field
+	if field == "" {
+		field = "token"
+	}
+	token, ok := data[field].(string)
+	if !ok || token == "" {
+		return "", errors.Errorf("hetzner_dns: field %q not found in vault secret %s", field, vaultToken.Path)
+	}
+	return token, nil
+}
+
+// RotatingToken is a token that can be replaced at runtime, e.g. by a
+// rotation job, without recreating the client.
+type RotatingToken struct {
+	mu    sync.RWMutex
+	token string
+}
+
+// NewRotatingToken creates a rotating token with an initial value.
+func NewRotatingToken(token string) *RotatingToken {
+	return &RotatingToken{token: token}
+}
+
+// Set replaces the token used by the next API calls.
+func (rotatingToken *RotatingToken) Set(token string) {
+	rotatingToken.mu.Lock()
+	defer rotatingToken.mu.Unlock()
+	rotatingToken.token = token
+}
+
+func (rotatingToken *RotatingToken) Token(ctx context.Context) (string, error) {
+	rotatingToken.mu.RLock()
+	defer rotatingToken.mu.RUnlock()
+	return rotatingToken.token, nil
+}
+
+// ChainToken tries each provider in order, returning the first non-empty
+// token. Errors are only returned if no provider has a token.
+type ChainToken []TokenProvider
+
+func (chain ChainToken) Token(ctx context.Context) (string, error) {
+	var errs []string
+	for _, provider := range chain {
+		token, err := provider.Token(ctx)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if token != "" {
+			return token, nil
+		}
+	}
+	if len(errs) > 0 {
+		return "", errors.Errorf("hetzner_dns: no token provided: %s", strings.Join(errs, "; "))
+	}
+	return "", nil
+}
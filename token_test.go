@@ -0,0 +1,112 @@
+package hetzner_dns_test
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+)
+
+func TestFileToken(t *testing.T) {
+	dir, err := ioutil.TempDir("", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "token")
+	if err := ioutil.WriteFile(path, []byte("first\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	provider := hetzner_dns.NewFileToken(path)
+	if token, err := provider.Token(context.Background()); err != nil || token != "first" {
+		t.Fatalf("expected first, got %q (%v)", token, err)
+	}
+	if err := ioutil.WriteFile(path, []byte("second-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, time.Now(), time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if token, err := provider.Token(context.Background()); err != nil || token != "second-token" {
+		t.Fatalf("expected the file to be reloaded, got %q (%v)", token, err)
+	}
+}
+
+func TestCommandToken(t *testing.T) {
+	provider := hetzner_dns.NewCommandToken("echo", "from-command")
+	if token, err := provider.Token(context.Background()); err != nil || token != "from-command" {
+		t.Fatalf("expected from-command, got %q (%v)", token, err)
+	}
+	if _, err := hetzner_dns.NewCommandToken("false").Token(context.Background()); err == nil {
+		t.Error("expected an error for a failing command")
+	}
+}
+
+func TestVaultToken(t *testing.T) {
+	hs := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("X-Vault-Token") != "vault-secret" {
+			http.Error(rw, "forbidden", http.StatusForbidden)
+			return
+		}
+		switch req.URL.Path {
+		case "/v1/secret/data/hetzner":
+			_, _ = io.WriteString(rw, `{"data": {"data": {"token": "from-vault-v2"}, "metadata": {}}}`)
+		case "/v1/kv/hetzner":
+			_, _ = io.WriteString(rw, `{"data": {"api_token": "from-vault-v1"}}`)
+		default:
+			http.NotFound(rw, req)
+		}
+	}))
+	defer hs.Close()
+
+	provider := &hetzner_dns.VaultToken{Address: hs.URL, Path: "secret/data/hetzner", VaultToken: "vault-secret"}
+	if token, err := provider.Token(context.Background()); err != nil || token != "from-vault-v2" {
+		t.Fatalf("expected from-vault-v2, got %q (%v)", token, err)
+	}
+	provider = &hetzner_dns.VaultToken{Address: hs.URL, Path: "kv/hetzner", Field: "api_token", VaultToken: "vault-secret"}
+	if token, err := provider.Token(context.Background()); err != nil || token != "from-vault-v1" {
+		t.Fatalf("expected from-vault-v1, got %q (%v)", token, err)
+	}
+	provider = &hetzner_dns.VaultToken{Address: hs.URL, Path: "kv/hetzner", VaultToken: "wrong"}
+	if _, err := provider.Token(context.Background()); err == nil {
+		t.Error("expected an error for a rejected vault token")
+	}
+}
+
+func TestTokenProviderClient(t *testing.T) {
+	var seen []string
+	hs := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		seen = append(seen, req.Header.Get("Auth-API-Token"))
+		_, _ = io.WriteString(rw, `{"zones": []}`)
+	}))
+	defer hs.Close()
+
+	rotating := hetzner_dns.NewRotatingToken("")
+	client := &hetzner_dns.Client{
+		BaseURL:       hs.URL,
+		TokenProvider: hetzner_dns.ChainToken{rotating, hetzner_dns.StaticToken("fallback")},
+	}
+	if _, err := client.GetZones(context.Background(), "", "", 1, 10); err != nil {
+		t.Fatal(err)
+	}
+	rotating.Set("rotated")
+	if _, err := client.GetZones(context.Background(), "", "", 1, 10); err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 2 || seen[0] != "fallback" || seen[1] != "rotated" {
+		t.Errorf("unexpected tokens %v", seen)
+	}
+
+	client.TokenProvider = hetzner_dns.StaticToken("")
+	if _, err := client.GetZones(context.Background(), "", "", 1, 10); err != hetzner_dns.ErrAPIKeyNotSet {
+		t.Errorf("expected ErrAPIKeyNotSet, got %v", err)
+	}
+}
@@ -0,0 +1,172 @@
+// Package tracing instruments a Client with OpenTelemetry, starting a span
+// for each API call.
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+)
+
+const INSTRUMENTATION_NAME = "github.com/panta/go-hetzner-dns/tracing"
+
+// Span attributes.
+const (
+	ATTR_METHOD      = attribute.Key("http.method")
+	ATTR_ENDPOINT    = attribute.Key("hetzner_dns.endpoint")
+	ATTR_ZONE_ID     = attribute.Key("hetzner_dns.zone_id")
+	ATTR_STATUS_CODE = attribute.Key("http.status_code")
+	ATTR_RETRY_COUNT = attribute.Key("hetzner_dns.retry_count")
+	ATTR_ERROR_TYPE  = attribute.Key("error.type")
+)
+
+type retryKey struct{}
+
+// WithRetry returns a context marking the request as the retry-th retry of
+// an API call. Retrying transports and callers use it so that the spans
+// report the retry count.
+func WithRetry(ctx context.Context, retry int) context.Context {
+	return context.WithValue(ctx, retryKey{}, retry)
+}
+
+// Retry returns the retry count set with WithRetry (0 if not set).
+func Retry(ctx context.Context) int {
+	retry, _ := ctx.Value(retryKey{}).(int)
+	return retry
+}
+
+// Tracer starts the spans of the API calls.
+type Tracer struct {
+	// TracerProvider provides the tracer (the global one if nil).
+	TracerProvider trace.TracerProvider
+	// Propagator injects the span context in the request headers (the
+	// global one if nil).
+	Propagator propagation.TextMapPropagator
+}
+
+// NewTracer creates a Tracer using the global provider and propagator.
+func NewTracer() *Tracer {
+	return &Tracer{}
+}
+
+// InstrumentClient makes client trace its API calls, by wrapping the
+// transport of its HttpClient (created if not set).
+func (tracer *Tracer) InstrumentClient(client *hetzner_dns.Client) {
+	if client.HttpClient == nil {
+		client.HttpClient = &http.Client{
+			Timeout: hetzner_dns.DEFAULT_TIMEOUT,
+		}
+	}
+	client.HttpClient.Transport = tracer.RoundTripper(client.HttpClient.Transport)
+}
+
+// RoundTripper returns an http.RoundTripper starting a span around each
+// request sent through next (or http.DefaultTransport if nil). The span is a
+// child of the span in the request context, if any.
+func (tracer *Tracer) RoundTripper(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		provider := tracer.TracerProvider
+		if provider == nil {
+			provider = otel.GetTracerProvider()
+		}
+		propagator := tracer.Propagator
+		if propagator == nil {
+			propagator = otel.GetTextMapPropagator()
+		}
+
+		endpoint := hetzner_dns.EndpointTemplate(req.URL.Path)
+		ctx, span := provider.Tracer(INSTRUMENTATION_NAME).Start(req.Context(),
+			fmt.Sprintf("%s %s", req.Method, endpoint),
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				ATTR_METHOD.String(req.Method),
+				ATTR_ENDPOINT.String(endpoint),
+				ATTR_RETRY_COUNT.Int(Retry(req.Context())),
+			))
+		defer span.End()
+		if zoneId := requestZoneID(req); zoneId != "" {
+			span.SetAttributes(ATTR_ZONE_ID.String(zoneId))
+		}
+
+		req = req.Clone(ctx)
+		propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+		resp, err := next.RoundTrip(req)
+		if err != nil {
+			span.RecordError(err)
+			span.SetAttributes(ATTR_ERROR_TYPE.String(fmt.Sprintf("%T", err)))
+			span.SetStatus(codes.Error, err.Error())
+			return resp, err
+		}
+		span.SetAttributes(ATTR_STATUS_CODE.Int(resp.StatusCode))
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			span.SetAttributes(ATTR_ERROR_TYPE.String(fmt.Sprint(resp.StatusCode)))
+			span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+		}
+		return resp, nil
+	})
+}
+
+// requestZoneID finds the zone of a request in the zone_id query parameter,
+// the path of zone endpoints or the zone_id field of the JSON body.
+func requestZoneID(req *http.Request) string {
+	if zoneId := req.URL.Query().Get("zone_id"); zoneId != "" {
+		return zoneId
+	}
+	if strings.HasPrefix(hetzner_dns.EndpointTemplate(req.URL.Path), "/zones/{id}") {
+		parts := strings.Split(strings.TrimSuffix(req.URL.Path, "/"), "/")
+		for i, part := range parts {
+			if part == "zones" && i+1 < len(parts) {
+				return parts[i+1]
+			}
+		}
+	}
+	if req.GetBody == nil {
+		return ""
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return ""
+	}
+	defer body.Close()
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return ""
+	}
+	var payload struct {
+		ZoneID  string `json:"zone_id"`
+		Records []struct {
+			ZoneID string `json:"zone_id"`
+		} `json:"records"`
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return ""
+	}
+	if payload.ZoneID != "" {
+		return payload.ZoneID
+	}
+	if len(payload.Records) > 0 {
+		return payload.Records[0].ZoneID
+	}
+	return ""
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (fn roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return fn(req)
+}
@@ -0,0 +1,96 @@
+package tracing_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+	"github.com/panta/go-hetzner-dns/tracing"
+)
+
+func TestTracer(t *testing.T) {
+	var traceparent string
+	hs := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		traceparent = req.Header.Get("traceparent")
+		switch req.URL.Path {
+		case "/records":
+			_, _ = io.WriteString(rw, `{"record": {"id": "rec-1", "zone_id": "zone-1", "name": "www", "type": "A", "value": "127.0.0.1"}}`)
+		default:
+			http.NotFound(rw, req)
+		}
+	}))
+	defer hs.Close()
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	tracer := &tracing.Tracer{TracerProvider: provider, Propagator: propagation.TraceContext{}}
+
+	client := &hetzner_dns.Client{BaseURL: hs.URL, ApiKey: "dummy"}
+	tracer.InstrumentClient(client)
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "parent")
+	_, err := client.CreateRecord(ctx, hetzner_dns.RecordRequest{ZoneID: "zone-1", Name: "www", Type: "A", Value: "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if traceparent == "" {
+		t.Error("expected the span context to be propagated")
+	}
+	_, _ = client.GetRecord(tracing.WithRetry(ctx, 2), "rec-2")
+	parent.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, got %d", len(spans))
+	}
+
+	create := spans[0]
+	if create.Name != "POST /records" {
+		t.Errorf("unexpected span name %q", create.Name)
+	}
+	if create.Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Error("expected the span to be a child of the context span")
+	}
+	assertAttributes(t, create.Attributes, map[attribute.Key]string{
+		tracing.ATTR_METHOD:      "POST",
+		tracing.ATTR_ENDPOINT:    "/records",
+		tracing.ATTR_ZONE_ID:     "zone-1",
+		tracing.ATTR_STATUS_CODE: "200",
+		tracing.ATTR_RETRY_COUNT: "0",
+	})
+
+	get := spans[1]
+	if get.Name != "GET /records/{id}" {
+		t.Errorf("unexpected span name %q", get.Name)
+	}
+	if get.Status.Code != codes.Error {
+		t.Errorf("expected error status, got %v", get.Status.Code)
+	}
+	assertAttributes(t, get.Attributes, map[attribute.Key]string{
+		tracing.ATTR_STATUS_CODE: "404",
+		tracing.ATTR_RETRY_COUNT: "2",
+		tracing.ATTR_ERROR_TYPE:  "404",
+	})
+}
+
+func assertAttributes(t *testing.T, attributes []attribute.KeyValue, expected map[attribute.Key]string) {
+	t.Helper()
+	actual := map[attribute.Key]string{}
+	for _, kv := range attributes {
+		actual[kv.Key] = kv.Value.Emit()
+	}
+	for key, value := range expected {
+		if actual[key] != value {
+			t.Errorf("attribute %s: expected %q, got %q", key, value, actual[key])
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package hetzner_dns
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const DEFAULT_WATCH_INTERVAL = time.Minute
+
+// EventType is the kind of change reported by a Watcher.
+type EventType string
+
+const (
+	EVENT_CREATED EventType = "created"
+	EVENT_UPDATED EventType = "updated"
+	EVENT_DELETED EventType = "deleted"
+)
+
+// RecordEvent is a change of a record detected by a Watcher. Previous is the
+// former state of the record for EVENT_UPDATED events.
+type RecordEvent struct {
+	Type     EventType `json:"type"`
+	ZoneID   string    `json:"zone_id"`
+	ZoneName string    `json:"zone_name"`
+	Record   Record    `json:"record"`
+	Previous *Record   `json:"previous,omitempty"`
+}
+
+// WatchedZone is the last known state of a zone.
+type WatchedZone struct {
+	Name         string             `json:"name"`
+	Modified     HetznerTime        `json:"modified"`
+	RecordsCount int                `json:"records_count"`
+	Records      map[string]*Record `json:"records"`
+}
+
+// WatcherState is the state of a Watcher, which can be saved and loaded to
+// resume watching without missing or repeating events.
+type WatcherState struct {
+	Zones map[string]*WatchedZone `json:"zones"`
+}
+
+// LoadWatcherState reads a state saved with SaveWatcherState.
+func LoadWatcherState(path string) (*WatcherState, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't read watcher state")
+	}
+	state := &WatcherState{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, errors.Wrap(err, "can't parse watcher state")
+	}
+	return state, nil
+}
+
+// SaveWatcherState writes state to path, atomically.
+func SaveWatcherState(path string, state *WatcherState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return errors.Wrap(err, "can't encode watcher state")
+	}
+	tmpPath := path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, 0o600); err != nil {
+		return errors.Wrap(err, "can't write watcher state")
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// Watcher polls zones and records and reports record-level changes, such as
+// the ones made from the Hetzner console. Records of a zone are fetched only
+// when its modification time or record count changed since the last poll.
+type Watcher struct {
+	Client *Client
+	// Interval between polls (DEFAULT_WATCH_INTERVAL if zero).
+	Interval time.Duration
+	// State is the last known state. If nil, the first poll only records the
+	// current state, without emitting events.
+	State *WatcherState
+	// OnError is called when a poll fails. If nil, errors are logged.
+	OnError func(error)
+	// OnPoll is called after each successful poll, e.g. to save the state.
+	OnPoll func(*WatcherState)
+}
+
+// NewWatcher creates a Watcher using client.
+func NewWatcher(client *Client) *Watcher {
+	return &Watcher{Client: client}
+}
+
+// Poll fetches the zones and records changed since the previous poll and
+// returns the corresponding events.
+func (watcher *Watcher) Poll(ctx context.Context) ([]RecordEvent, error) {
+	zones, err := watcher.Client.GetAllZones(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't get zones")
+	}
+
+	initial := watcher.State == nil
+	state := &WatcherState{Zones: map[string]*WatchedZone{}}
+	previous := watcher.State
+	if previous == nil {
+		previous = &WatcherState{}
+	}
+
+	var events []RecordEvent
+	for _, zone := range zones {
+		known := previous.Zones[zone.ID]
+		if known != nil && time.Time(known.Modified).Equal(time.Time(zone.Modified)) && known.RecordsCount == zone.RecordsCount {
+			state.Zones[zone.ID] = known
+			continue
+		}
+
+		records, err := watcher.Client.GetAllRecords(ctx, zone.ID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "can't get records for zone %s", zone.Name)
+		}
+		watched := &WatchedZone{
+			Name:         zone.Name,
+			Modified:     zone.Modified,
+			RecordsCount: zone.RecordsCount,
+			Records:      make(map[string]*Record, len(records)),
+		}
+		for i := range records {
+			watched.Records[records[i].ID] = &records[i]
+		}
+		state.Zones[zone.ID] = watched
+		if !initial {
+			events = append(events, diffWatchedZone(zone.ID, known, watched)...)
+		}
+	}
+
+	if !initial {
+		var deletedZoneIds []string
+		for zoneId := range previous.Zones {
+			if _, ok := state.Zones[zoneId]; !ok {
+				deletedZoneIds = append(deletedZoneIds, zoneId)
+			}
+		}
+		sort.Strings(deletedZoneIds)
+		for _, zoneId := range deletedZoneIds {
+			events = append(events, diffWatchedZone(zoneId, previous.Zones[zoneId], nil)...)
+		}
+	}
+
+	watcher.State = state
+	return events, nil
+}
+
+// Run polls every Interval, calling handler for each event, until ctx is
+// cancelled.
+func (watcher *Watcher) Run(ctx context.Context, handler func(RecordEvent)) error {
+	interval := watcher.Interval
+	if interval <= 0 {
+		interval = DEFAULT_WATCH_INTERVAL
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		events, err := watcher.Poll(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if watcher.OnError != nil {
+				watcher.OnError(err)
+			} else {
+				log.Printf("watcher: poll failed: %v", err)
+			}
+		} else {
+			for _, event := range events {
+				handler(event)
+			}
+			if watcher.OnPoll != nil {
+				watcher.OnPoll(watcher.State)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Watch runs the watcher in background and delivers the events on the
+// returned channel, which is closed when ctx is cancelled.
+func (watcher *Watcher) Watch(ctx context.Context) <-chan RecordEvent {
+	events := make(chan RecordEvent, 64)
+	go func() {
+		defer close(events)
+		_ = watcher.Run(ctx, func(event RecordEvent) {
+			select {
+			case events <- event:
+			case <-ctx.Done():
+			}
+		})
+	}()
+	return events
+}
+
+// diffWatchedZone returns the events turning known into current, sorted by
+// record. Either may be nil for a created or deleted zone.
+func diffWatchedZone(zoneId string, known *WatchedZone, current *WatchedZone) []RecordEvent {
+	var events []RecordEvent
+	knownRecords, currentRecords := map[string]*Record{}, map[string]*Record{}
+	zoneName := ""
+	if known != nil {
+		knownRecords = known.Records
+		zoneName = known.Name
+	}
+	if current != nil {
+		currentRecords = current.Records
+		zoneName = current.Name
+	}
+
+	for id, record := range currentRecords {
+		before, ok := knownRecords[id]
+		switch {
+		case !ok:
+			events = append(events, RecordEvent{Type: EVENT_CREATED, ZoneID: zoneId, ZoneName: zoneName, Record: *record})
+		case recordChanged(before, record):
+			previous := *before
+			events = append(events, RecordEvent{Type: EVENT_UPDATED, ZoneID: zoneId, ZoneName: zoneName, Record: *record, Previous: &previous})
+		}
+	}
+	for id, record := range knownRecords {
+		if _, ok := currentRecords[id]; !ok {
+			events = append(events, RecordEvent{Type: EVENT_DELETED, ZoneID: zoneId, ZoneName: zoneName, Record: *record})
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		a, b := &events[i].Record, &events[j].Record
+		if keyA, keyB := RecordKey(a.Name, a.Type, a.Value), RecordKey(b.Name, b.Type, b.Value); keyA != keyB {
+			return keyA < keyB
+		}
+		return a.ID < b.ID
+	})
+	return events
+}
+
+func recordChanged(before *Record, after *Record) bool {
+	return !time.Time(before.Modified).Equal(time.Time(after.Modified)) ||
+		before.Name != after.Name || before.Type != after.Type ||
+		before.Value != after.Value || before.TTL != after.TTL
+}
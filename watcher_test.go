@@ -0,0 +1,94 @@
+package hetzner_dns_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+	"github.com/panta/go-hetzner-dns/internal/fakeapi"
+)
+
+func TestWatcher(t *testing.T) {
+	api := fakeapi.New(t)
+	defer api.Close()
+	api.AddZone("zone-1", "example.com")
+	api.AddZone("zone-2", "example.org")
+	wwwId := api.AddRecord("zone-1", "www", "A", "10.0.0.1")
+	oldId := api.AddRecord("zone-1", "old", "A", "10.0.0.2")
+	api.AddRecord("zone-2", "www", "A", "10.0.0.3")
+
+	ctx := context.Background()
+	client := api.Client()
+	watcher := hetzner_dns.NewWatcher(client)
+
+	events, err := watcher.Poll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Errorf("First poll should not emit events: %+v", events)
+	}
+
+	// nothing changed: no records are fetched
+	before := api.CallCount("GET /records")
+	if _, err := watcher.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if api.CallCount("GET /records") != before {
+		t.Error("Records fetched for unchanged zones")
+	}
+
+	// resume from a saved state
+	dir, err := ioutil.TempDir("", "watcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	statePath := filepath.Join(dir, "state.json")
+	if err := hetzner_dns.SaveWatcherState(statePath, watcher.State); err != nil {
+		t.Fatal(err)
+	}
+	state, err := hetzner_dns.LoadWatcherState(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	watcher = hetzner_dns.NewWatcher(client)
+	watcher.State = state
+
+	if _, err := client.UpdateRecord(ctx, hetzner_dns.RecordRequest{ID: wwwId, ZoneID: "zone-1", Name: "www", Type: "A", Value: "10.0.0.9"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.DeleteRecord(ctx, oldId); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CreateRecord(ctx, hetzner_dns.RecordRequest{ZoneID: "zone-1", Name: "api", Type: "A", Value: "10.0.0.4"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the record count is unchanged, the modification time tells the change
+	api.SetModified("zone-1", time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	before = api.CallCount("GET /records")
+	events, err = watcher.Poll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if api.CallCount("GET /records") != before+1 {
+		t.Error("Only the changed zone should be fetched")
+	}
+	// events are sorted by record
+	if len(events) != 3 ||
+		events[0].Type != hetzner_dns.EVENT_CREATED || events[0].Record.Name != "api" ||
+		events[1].Type != hetzner_dns.EVENT_DELETED || events[1].Record.Name != "old" ||
+		events[2].Type != hetzner_dns.EVENT_UPDATED || events[2].Record.Name != "www" {
+		t.Fatalf("Wrong events: %+v", events)
+	}
+	updated := events[2]
+	if updated.Previous == nil || updated.Previous.Value != "10.0.0.1" || updated.Record.Value != "10.0.0.9" {
+		t.Errorf("Wrong update event: %+v", updated)
+	}
+}
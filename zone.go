@@ -40,6 +40,10 @@ type ZonesResponse struct {
 	Meta  Meta   `json:"meta"`
 }
 
+type ZoneResponse struct {
+	Zone Zone `json:"zone"`
+}
+
 type ZoneRequest struct {
 	Name string `json:"name"`
 	TTL  int    `json:"ttl"`
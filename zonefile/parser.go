@@ -0,0 +1,437 @@
+// Package zonefile reads and writes RFC 1035 master (BIND zone) files,
+// converting them from and to Hetzner DNS records.
+package zonefile
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+)
+
+const MAX_INCLUDE_DEPTH = 10
+
+// ParseError is a syntax error in a zone file.
+type ParseError struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (e *ParseError) Error() string {
+	if e.File != "" {
+		return fmt.Sprintf("zonefile: %s:%d: %s", e.File, e.Line, e.Msg)
+	}
+	return fmt.Sprintf("zonefile: line %d: %s", e.Line, e.Msg)
+}
+
+// Parse parses a zone file for the zone origin, returning its records with
+// names relative to origin ("@" for the apex). $INCLUDE directives are
+// resolved relative to the current directory.
+func Parse(r io.Reader, origin string) ([]hetzner_dns.RecordRequest, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{zone: fqdn(origin), origin: fqdn(origin)}
+	if err := p.parse(data, "", 0); err != nil {
+		return nil, err
+	}
+	return p.records, nil
+}
+
+// ParseFile parses the zone file at path for the zone origin. $INCLUDE
+// directives are resolved relative to the directory of the including file.
+func ParseFile(path string, origin string) ([]hetzner_dns.RecordRequest, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{zone: fqdn(origin), origin: fqdn(origin)}
+	if err := p.parse(data, path, 0); err != nil {
+		return nil, err
+	}
+	return p.records, nil
+}
+
+type parser struct {
+	zone      string // zone apex, fully qualified
+	origin    string // current $ORIGIN, fully qualified
+	ttl       int    // current $TTL
+	lastTTL   int
+	lastOwner string
+	records   []hetzner_dns.RecordRequest
+}
+
+// entry is a logical line of a zone file: parentheses may span several lines.
+type entry struct {
+	line       int
+	tokens     []string
+	quoted     []bool
+	blankOwner bool // the line starts with whitespace, the owner is omitted
+}
+
+func (p *parser) parse(data []byte, file string, depth int) error {
+	entries, err := tokenize(string(data))
+	if err != nil {
+		if parseErr, ok := err.(*ParseError); ok {
+			parseErr.File = file
+		}
+		return err
+	}
+	for _, e := range entries {
+		if err := p.parseEntry(e, file, depth); err != nil {
+			return &ParseError{File: file, Line: e.line, Msg: err.Error()}
+		}
+	}
+	return nil
+}
+
+func (p *parser) parseEntry(e entry, file string, depth int) error {
+	tokens := e.tokens
+	if !e.blankOwner && strings.HasPrefix(tokens[0], "$") && !e.quoted[0] {
+		return p.parseDirective(e, file, depth)
+	}
+
+	owner := p.lastOwner
+	if !e.blankOwner {
+		owner = p.absolute(tokens[0])
+		tokens, e.quoted = tokens[1:], e.quoted[1:]
+	}
+	if owner == "" {
+		return fmt.Errorf("missing owner name")
+	}
+
+	ttl := -1
+	recordType := ""
+	for len(tokens) > 0 && recordType == "" {
+		token := tokens[0]
+		if value, ok := parseTTL(token); ok && ttl < 0 {
+			ttl = value
+		} else if isClass(token) {
+			if !strings.EqualFold(token, "IN") {
+				return fmt.Errorf("unsupported class %s", token)
+			}
+		} else {
+			recordType = strings.ToUpper(token)
+		}
+		tokens, e.quoted = tokens[1:], e.quoted[1:]
+	}
+	if recordType == "" {
+		return fmt.Errorf("missing record type")
+	}
+	if len(tokens) == 0 {
+		return fmt.Errorf("missing data for %s record", recordType)
+	}
+	if ttl < 0 {
+		ttl = p.ttl
+		if ttl == 0 {
+			ttl = p.lastTTL
+		}
+	}
+
+	name, err := p.relative(owner)
+	if err != nil {
+		return err
+	}
+	p.lastOwner = owner
+	p.lastTTL = ttl
+	p.records = append(p.records, hetzner_dns.RecordRequest{
+		Type:  recordType,
+		Name:  name,
+		Value: p.value(recordType, tokens, e.quoted),
+		TTL:   ttl,
+	})
+	return nil
+}
+
+func (p *parser) parseDirective(e entry, file string, depth int) error {
+	switch strings.ToUpper(e.tokens[0]) {
+	case "$ORIGIN":
+		if len(e.tokens) != 2 {
+			return fmt.Errorf("$ORIGIN expects one argument")
+		}
+		p.origin = p.absolute(e.tokens[1])
+	case "$TTL":
+		if len(e.tokens) != 2 {
+			return fmt.Errorf("$TTL expects one argument")
+		}
+		ttl, ok := parseTTL(e.tokens[1])
+		if !ok {
+			return fmt.Errorf("invalid TTL %s", e.tokens[1])
+		}
+		p.ttl = ttl
+	case "$INCLUDE":
+		if len(e.tokens) < 2 || len(e.tokens) > 3 {
+			return fmt.Errorf("$INCLUDE expects a file name and an optional origin")
+		}
+		if depth >= MAX_INCLUDE_DEPTH {
+			return fmt.Errorf("too many nested $INCLUDE")
+		}
+		path := e.tokens[1]
+		if !filepath.IsAbs(path) && file != "" {
+			path = filepath.Join(filepath.Dir(file), path)
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return fmt.Errorf("included file %s not found", e.tokens[1])
+			}
+			return err
+		}
+		// the origin and owner of the including file are restored afterwards
+		savedOrigin, savedOwner := p.origin, p.lastOwner
+		if len(e.tokens) == 3 {
+			p.origin = p.absolute(e.tokens[2])
+		}
+		err = p.parse(data, path, depth+1)
+		p.origin, p.lastOwner = savedOrigin, savedOwner
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown directive %s", e.tokens[0])
+	}
+	return nil
+}
+
+// value builds the record value from its data tokens, qualifying relative
+// domain names when the current origin isn't the zone apex.
+func (p *parser) value(recordType string, tokens []string, quoted []bool) string {
+	if recordType == "TXT" || recordType == "SPF" {
+		if len(tokens) == 1 {
+			return tokens[0]
+		}
+		parts := make([]string, len(tokens))
+		for i, token := range tokens {
+			parts[i] = strconv.Quote(token)
+		}
+		return strings.Join(parts, " ")
+	}
+
+	var namePositions []int
+	switch recordType {
+	case "CNAME", "NS", "PTR", "DNAME":
+		namePositions = []int{0}
+	case "MX":
+		namePositions = []int{1}
+	case "SRV":
+		namePositions = []int{3}
+	case "SOA":
+		namePositions = []int{0, 1}
+	}
+	values := make([]string, len(tokens))
+	copy(values, tokens)
+	for _, pos := range namePositions {
+		if pos >= len(values) {
+			continue
+		}
+		if values[pos] == "@" || (p.origin != p.zone && !strings.HasSuffix(values[pos], ".")) {
+			values[pos] = p.absolute(values[pos])
+		}
+	}
+	for i := range values {
+		if quoted[i] {
+			values[i] = strconv.Quote(values[i])
+		}
+	}
+	return strings.Join(values, " ")
+}
+
+// absolute returns the fully qualified form of name.
+func (p *parser) absolute(name string) string {
+	switch {
+	case name == "@":
+		return p.origin
+	case strings.HasSuffix(name, "."):
+		return strings.ToLower(name)
+	default:
+		return strings.ToLower(name) + "." + p.origin
+	}
+}
+
+// relative returns the name relative to the zone apex.
+func (p *parser) relative(name string) (string, error) {
+	if name == p.zone {
+		return "@", nil
+	}
+	if strings.HasSuffix(name, "."+p.zone) {
+		return strings.TrimSuffix(name, "."+p.zone), nil
+	}
+	return "", fmt.Errorf("name %s is outside of zone %s", name, p.zone)
+}
+
+// tokenize splits the zone file into entries, handling comments, quoted
+// strings, escapes and parentheses.
+func tokenize(input string) ([]entry, error) {
+	var entries []entry
+	var current *entry
+	var token strings.Builder
+	inToken, inQuotes := false, false
+	parens, line, parenLine := 0, 1, 0
+	atLineStart := true
+
+	flushToken := func(quoted bool) {
+		if !inToken {
+			return
+		}
+		current.tokens = append(current.tokens, token.String())
+		current.quoted = append(current.quoted, quoted)
+		token.Reset()
+		inToken = false
+	}
+	startToken := func() {
+		if current == nil {
+			current = &entry{line: line}
+		}
+		inToken = true
+	}
+	flushEntry := func() {
+		if current != nil && len(current.tokens) > 0 {
+			entries = append(entries, *current)
+		}
+		current = nil
+	}
+
+	for i := 0; i < len(input); i++ {
+		c := input[i]
+		if inQuotes {
+			switch c {
+			case '\\':
+				if i+1 < len(input) {
+					i++
+					token.WriteByte(input[i])
+				}
+			case '"':
+				inQuotes = false
+				flushToken(true)
+			case '\n':
+				return nil, &ParseError{Line: line, Msg: "unterminated quoted string"}
+			default:
+				token.WriteByte(c)
+			}
+			continue
+		}
+
+		switch c {
+		case ';':
+			for i+1 < len(input) && input[i+1] != '\n' {
+				i++
+			}
+		case '"':
+			flushToken(false)
+			startToken()
+			inQuotes = true
+		case '(':
+			flushToken(false)
+			if current == nil {
+				current = &entry{line: line}
+			}
+			if parens == 0 {
+				parenLine = line
+			}
+			parens++
+		case ')':
+			flushToken(false)
+			if parens == 0 {
+				return nil, &ParseError{Line: line, Msg: "unbalanced parentheses"}
+			}
+			parens--
+		case '\n':
+			flushToken(false)
+			if parens == 0 {
+				flushEntry()
+			}
+			line++
+			atLineStart = true
+			continue
+		case ' ', '\t', '\r':
+			flushToken(false)
+			if atLineStart && current == nil {
+				current = &entry{line: line, blankOwner: true}
+			}
+		case '\\':
+			startToken()
+			if i+1 < len(input) {
+				i++
+				token.WriteByte(input[i])
+			}
+		default:
+			startToken()
+			token.WriteByte(c)
+		}
+		atLineStart = false
+	}
+	if inQuotes {
+		return nil, &ParseError{Line: line, Msg: "unterminated quoted string"}
+	}
+	if parens > 0 {
+		return nil, &ParseError{Line: parenLine, Msg: "unbalanced parentheses"}
+	}
+	flushToken(false)
+	flushEntry()
+	return entries, nil
+}
+
+// parseTTL parses a TTL, in seconds or with BIND units (e.g. "1h30m").
+func parseTTL(s string) (int, bool) {
+	if s == "" {
+		return 0, false
+	}
+	if value, err := strconv.Atoi(s); err == nil {
+		return value, value >= 0
+	}
+	total, number := 0, -1
+	for _, c := range strings.ToLower(s) {
+		if c >= '0' && c <= '9' {
+			if number < 0 {
+				number = 0
+			}
+			number = number*10 + int(c-'0')
+			continue
+		}
+		if number < 0 {
+			return 0, false
+		}
+		switch c {
+		case 's':
+			total += number
+		case 'm':
+			total += number * 60
+		case 'h':
+			total += number * 3600
+		case 'd':
+			total += number * 86400
+		case 'w':
+			total += number * 604800
+		default:
+			return 0, false
+		}
+		number = -1
+	}
+	if number >= 0 {
+		return 0, false
+	}
+	return total, true
+}
+
+func isClass(s string) bool {
+	switch strings.ToUpper(s) {
+	case "IN", "CH", "HS", "CS":
+		return true
+	}
+	return false
+}
+
+func fqdn(name string) string {
+	name = strings.ToLower(name)
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	return name
+}
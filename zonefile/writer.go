@@ -0,0 +1,108 @@
+package zonefile
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+)
+
+// Write serializes records of the zone origin as a zone file. Records are
+// sorted canonically: apex first, then by name (compared label by label from
+// the right), type and value. A $TTL directive is written if ttl is positive.
+func Write(w io.Writer, origin string, ttl int, records []hetzner_dns.Record) error {
+	sorted := append([]hetzner_dns.Record(nil), records...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return recordLess(&sorted[i], &sorted[j])
+	})
+
+	nameWidth := 1
+	for _, record := range sorted {
+		if n := len(ownerName(record.Name)); n > nameWidth {
+			nameWidth = n
+		}
+	}
+
+	if _, err := fmt.Fprintf(w, "$ORIGIN %s\n", fqdn(origin)); err != nil {
+		return err
+	}
+	if ttl > 0 {
+		if _, err := fmt.Fprintf(w, "$TTL %d\n", ttl); err != nil {
+			return err
+		}
+	}
+	for _, record := range sorted {
+		recordTTL := ""
+		if record.TTL > 0 {
+			recordTTL = strconv.Itoa(record.TTL)
+		}
+		if _, err := fmt.Fprintf(w, "%-*s %6s IN %-6s %s\n",
+			nameWidth, ownerName(record.Name), recordTTL, strings.ToUpper(record.Type), formatValue(&record)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Format returns the zone file text for records of the zone origin.
+func Format(origin string, ttl int, records []hetzner_dns.Record) string {
+	var sb strings.Builder
+	_ = Write(&sb, origin, ttl, records)
+	return sb.String()
+}
+
+func formatValue(record *hetzner_dns.Record) string {
+	recordType := strings.ToUpper(record.Type)
+	if (recordType == "TXT" || recordType == "SPF") && !strings.HasPrefix(record.Value, `"`) {
+		return strconv.Quote(record.Value)
+	}
+	return record.Value
+}
+
+func ownerName(name string) string {
+	if name == "" {
+		return "@"
+	}
+	return strings.ToLower(name)
+}
+
+func recordLess(a, b *hetzner_dns.Record) bool {
+	nameA, nameB := ownerName(a.Name), ownerName(b.Name)
+	if nameA != nameB {
+		return nameLess(nameA, nameB)
+	}
+	typeA, typeB := typeOrder(a.Type), typeOrder(b.Type)
+	if typeA != typeB {
+		return typeA < typeB
+	}
+	return a.Value < b.Value
+}
+
+// nameLess compares names label by label from the right, the apex first.
+func nameLess(a, b string) bool {
+	if a == "@" || b == "@" {
+		return a == "@" && b != "@"
+	}
+	labelsA, labelsB := strings.Split(a, "."), strings.Split(b, ".")
+	for i, j := len(labelsA)-1, len(labelsB)-1; i >= 0 && j >= 0; i, j = i-1, j-1 {
+		if labelsA[i] != labelsB[j] {
+			return labelsA[i] < labelsB[j]
+		}
+	}
+	return len(labelsA) < len(labelsB)
+}
+
+// typeOrder sorts SOA and NS records first, then the other types alphabetically.
+func typeOrder(recordType string) string {
+	switch recordType = strings.ToUpper(recordType); recordType {
+	case "SOA":
+		return "0"
+	case "NS":
+		return "1"
+	default:
+		return "2" + recordType
+	}
+}
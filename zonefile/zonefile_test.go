@@ -0,0 +1,126 @@
+package zonefile_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	hetzner_dns "github.com/panta/go-hetzner-dns"
+	"github.com/panta/go-hetzner-dns/zonefile"
+)
+
+const sampleZone = `$ORIGIN example.com.
+$TTL 1h
+@   IN SOA ns1.example.net. hostmaster (
+        2021012801 ; serial
+        86400      ; refresh
+        10800 3600000 3600 )
+    IN NS  ns1.example.net.
+    IN A   192.0.2.1     ; apex address
+www 300 IN CNAME @
+mail    A  192.0.2.25
+@       MX 10 mail
+@       TXT "v=spf1 mx -all"
+long    TXT ( "first part "
+              "second \"part\"" )
+$ORIGIN sub.example.com.
+host    A  192.0.2.30
+alias   CNAME host
+`
+
+func TestParse(t *testing.T) {
+	records, err := zonefile.Parse(strings.NewReader(sampleZone), "example.com")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	expected := []hetzner_dns.RecordRequest{
+		{Name: "@", Type: "SOA", Value: "ns1.example.net. hostmaster 2021012801 86400 10800 3600000 3600", TTL: 3600},
+		{Name: "@", Type: "NS", Value: "ns1.example.net.", TTL: 3600},
+		{Name: "@", Type: "A", Value: "192.0.2.1", TTL: 3600},
+		{Name: "www", Type: "CNAME", Value: "example.com.", TTL: 300},
+		{Name: "mail", Type: "A", Value: "192.0.2.25", TTL: 3600},
+		{Name: "@", Type: "MX", Value: "10 mail", TTL: 3600},
+		{Name: "@", Type: "TXT", Value: "v=spf1 mx -all", TTL: 3600},
+		{Name: "long", Type: "TXT", Value: `"first part " "second \"part\""`, TTL: 3600},
+		{Name: "host.sub", Type: "A", Value: "192.0.2.30", TTL: 3600},
+		{Name: "alias.sub", Type: "CNAME", Value: "host.sub.example.com.", TTL: 3600},
+	}
+	if len(records) != len(expected) {
+		t.Fatalf("Wrong # of records: %d\n%+v", len(records), records)
+	}
+	for i := range expected {
+		if records[i] != expected[i] {
+			t.Errorf("Record #%d: expected %+v, got %+v", i, expected[i], records[i])
+		}
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	for _, input := range []string{
+		"www A 192.0.2.1\nwww.example.org. A 192.0.2.2\n",
+		"www A ( 192.0.2.1\n",
+		"www TXT \"unterminated\n",
+		"$BOGUS foo\n",
+	} {
+		_, err := zonefile.Parse(strings.NewReader(input), "example.com")
+		if _, ok := err.(*zonefile.ParseError); !ok {
+			t.Errorf("Expected ParseError for %q, got %v", input, err)
+		}
+	}
+}
+
+func TestParseFile_Include(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zonefile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "hosts.inc"), []byte("db A 192.0.2.40\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	main := "$TTL 600\n$INCLUDE hosts.inc internal.example.com.\nwww A 192.0.2.1\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "example.com.zone"), []byte(main), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := zonefile.ParseFile(filepath.Join(dir, "example.com.zone"), "example.com.")
+	if err != nil {
+		t.Fatalf("ParseFile failed: %v", err)
+	}
+	if len(records) != 2 || records[0].Name != "db.internal" || records[1].Name != "www" || records[1].TTL != 600 {
+		t.Errorf("Wrong records: %+v", records)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	records := []hetzner_dns.Record{
+		{Name: "www", Type: "CNAME", Value: "@", TTL: 300},
+		{Name: "a.sub", Type: "A", Value: "192.0.2.3"},
+		{Name: "@", Type: "TXT", Value: "v=spf1 -all"},
+		{Name: "@", Type: "NS", Value: "ns1.example.net."},
+		{Name: "b", Type: "A", Value: "192.0.2.2"},
+	}
+	expected := `$ORIGIN example.com.
+$TTL 3600
+@            IN NS     ns1.example.net.
+@            IN TXT    "v=spf1 -all"
+b            IN A      192.0.2.2
+a.sub        IN A      192.0.2.3
+www      300 IN CNAME  @
+`
+	text := zonefile.Format("example.com", 3600, records)
+	if text != expected {
+		t.Errorf("Wrong output:\n%s\nexpected:\n%s", text, expected)
+	}
+
+	parsed, err := zonefile.Parse(strings.NewReader(text), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed) != len(records) {
+		t.Errorf("Round trip lost records: %+v", parsed)
+	}
+}